@@ -0,0 +1,242 @@
+// Command aidispatcherctl is the operator CLI for a running orchestrator. It talks to the
+// admin API with an admin-scoped key:
+//
+//	AIDISPATCHER_URL      orchestrator base URL (default http://localhost:8080)
+//	AIDISPATCHER_API_KEY  API key with the admin scope
+//
+//	aidispatcherctl dlq list    [-job ID] [-reason R] [-since T] [-until T] [-limit N]
+//	aidispatcherctl dlq show    ID
+//	aidispatcherctl dlq replay  [-job ID] [-reason R] [-since T] [-until T] [-all] [-engine E] [-force-fast=true|false] [ID...]
+//	aidispatcherctl dlq purge   [-job ID] [-reason R] [-since T] [-until T] [-all] [-yes] [ID...]
+//
+// T is an RFC3339 time or a duration back from now (e.g. 24h).
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+    "time"
+)
+
+const usage = `usage: aidispatcherctl dlq <list|show|replay|purge> [flags] [ID...]
+run "aidispatcherctl dlq <command> -h" for the flags of a command`
+
+type dlqEntry struct {
+    ID        string          `json:"id"`
+    At        time.Time       `json:"at"`
+    Reason    string          `json:"reason"`
+    Error     string          `json:"error"`
+    JobID     string          `json:"job_id"`
+    PageID    int             `json:"page_id"`
+    Attempt   int             `json:"attempt"`
+    AIEngine  string          `json:"ai_engine"`
+    ForceFast bool            `json:"force_fast"`
+    Payload   json.RawMessage `json:"payload"`
+}
+
+// selector mirrors the orchestrator's replay/purge selection.
+type selector struct {
+    IDs    []string `json:"ids,omitempty"`
+    JobID  string   `json:"job_id,omitempty"`
+    Reason string   `json:"reason,omitempty"`
+    Since  string   `json:"since,omitempty"`
+    Until  string   `json:"until,omitempty"`
+    All    bool     `json:"all,omitempty"`
+}
+
+func (s *selector) flags(fs *flag.FlagSet) {
+    fs.StringVar(&s.JobID, "job", "", "only entries of this job")
+    fs.StringVar(&s.Reason, "reason", "", "only entries with this reason (max_attempts|fatal|low_quality)")
+    fs.StringVar(&s.Since, "since", "", "only entries dead-lettered at or after this time")
+    fs.StringVar(&s.Until, "until", "", "only entries dead-lettered at or before this time")
+}
+
+type client struct {
+    base string
+    key  string
+    http *http.Client
+}
+
+func main() {
+    if len(os.Args) < 3 || os.Args[1] != "dlq" { fail(usage) }
+    c := &client{base: strings.TrimRight(envOr("AIDISPATCHER_URL", "http://localhost:8080"), "/"),
+        key: os.Getenv("AIDISPATCHER_API_KEY"), http: &http.Client{Timeout: 60 * time.Second}}
+    cmd, args := os.Args[2], os.Args[3:]
+    var err error
+    switch cmd {
+    case "list":
+        err = c.list(args)
+    case "show":
+        err = c.show(args)
+    case "replay":
+        err = c.replay(args)
+    case "purge":
+        err = c.purge(args)
+    default:
+        fail(usage)
+    }
+    if err != nil { fail("aidispatcherctl: " + err.Error()) }
+}
+
+func (c *client) list(args []string) error {
+    fs := flag.NewFlagSet("dlq list", flag.ExitOnError)
+    var sel selector
+    sel.flags(fs)
+    limit := fs.Int("limit", 100, "maximum entries to list (0 = all)")
+    asJSON := fs.Bool("json", false, "print the raw JSON response")
+    _ = fs.Parse(args)
+    q := url.Values{}
+    for k, v := range map[string]string{"job_id": sel.JobID, "reason": sel.Reason, "since": sel.Since, "until": sel.Until} {
+        if v != "" { q.Set(k, v) }
+    }
+    q.Set("limit", strconv.Itoa(*limit))
+    var resp struct {
+        Entries []dlqEntry `json:"entries"`
+        Count   int        `json:"count"`
+    }
+    raw, err := c.do(http.MethodGet, "/admin/dlq?"+q.Encode(), nil, &resp)
+    if err != nil { return err }
+    if *asJSON { _, err = os.Stdout.Write(raw); return err }
+    tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(tw, "ID\tAT\tREASON\tJOB\tPAGE\tATTEMPT\tENGINE\tERROR")
+    for _, e := range resp.Entries {
+        fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n", e.ID, e.At.Local().Format(time.DateTime), e.Reason, e.JobID, e.PageID,
+            e.Attempt, e.AIEngine, truncate(e.Error, 80))
+    }
+    if err := tw.Flush(); err != nil { return err }
+    fmt.Printf("%d entries\n", resp.Count)
+    return nil
+}
+
+func (c *client) show(args []string) error {
+    if len(args) != 1 { return fmt.Errorf("usage: aidispatcherctl dlq show ID") }
+    var e dlqEntry
+    if _, err := c.do(http.MethodGet, "/admin/dlq/"+url.PathEscape(args[0]), nil, &e); err != nil { return err }
+    fmt.Printf("id:         %s\nat:         %s\nreason:     %s\njob:        %s\npage:       %d\nattempt:    %d\nengine:     %s\nforce_fast: %t\n",
+        e.ID, e.At.Local().Format(time.RFC3339), e.Reason, e.JobID, e.PageID, e.Attempt, e.AIEngine, e.ForceFast)
+    fmt.Printf("last error: %s\n", orDash(e.Error))
+    if len(e.Payload) > 0 {
+        var buf bytes.Buffer
+        if json.Indent(&buf, e.Payload, "", "  ") == nil { fmt.Printf("payload:\n%s\n", buf.String()) }
+    }
+    return nil
+}
+
+func (c *client) replay(args []string) error {
+    fs := flag.NewFlagSet("dlq replay", flag.ExitOnError)
+    var req struct {
+        selector
+        AIEngine  string `json:"ai_engine,omitempty"`
+        ForceFast *bool  `json:"force_fast,omitempty"`
+    }
+    req.flags(fs)
+    fs.BoolVar(&req.All, "all", false, "replay every entry")
+    fs.StringVar(&req.AIEngine, "engine", "", "process the replayed pages with this AI engine")
+    fs.Func("force-fast", "override force_fast of the replayed pages (true|false)", func(s string) error {
+        b, err := strconv.ParseBool(s)
+        req.ForceFast = &b
+        return err
+    })
+    _ = fs.Parse(args)
+    req.IDs = fs.Args()
+    var resp struct {
+        Replayed int        `json:"replayed"`
+        Entries  []dlqEntry `json:"entries"`
+        Refused  []struct {
+            ID     string `json:"id"`
+            JobID  string `json:"job_id"`
+            PageID int    `json:"page_id"`
+            Reason string `json:"reason"`
+        } `json:"refused"`
+    }
+    if _, err := c.do(http.MethodPost, "/admin/dlq/replay", req, &resp); err != nil { return err }
+    for _, e := range resp.Entries { fmt.Printf("replayed %s (job %s page %d)\n", e.ID, e.JobID, e.PageID) }
+    for _, e := range resp.Refused { fmt.Printf("kept %s (job %s page %d): %s\n", e.ID, e.JobID, e.PageID, e.Reason) }
+    fmt.Printf("%d entries replayed, %d kept\n", resp.Replayed, len(resp.Refused))
+    return nil
+}
+
+func (c *client) purge(args []string) error {
+    fs := flag.NewFlagSet("dlq purge", flag.ExitOnError)
+    var req struct {
+        selector
+        Confirm bool `json:"confirm"`
+    }
+    req.flags(fs)
+    fs.BoolVar(&req.All, "all", false, "purge every entry")
+    yes := fs.Bool("yes", false, "do not ask for confirmation")
+    _ = fs.Parse(args)
+    req.IDs = fs.Args()
+    var dry struct {
+        Matched int `json:"matched"`
+    }
+    if _, err := c.do(http.MethodPost, "/admin/dlq/purge", req, &dry); err != nil { return err }
+    if dry.Matched == 0 { fmt.Println("no matching entries"); return nil }
+    if !*yes {
+        fmt.Printf("delete %d DLQ entries? [y/N] ", dry.Matched)
+        answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+        if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" { fmt.Println("aborted"); return nil }
+    }
+    req.Confirm = true
+    var resp struct {
+        Purged int `json:"purged"`
+    }
+    if _, err := c.do(http.MethodPost, "/admin/dlq/purge", req, &resp); err != nil { return err }
+    fmt.Printf("%d entries purged\n", resp.Purged)
+    return nil
+}
+
+// do sends a request to the admin API and decodes a 2xx JSON response into out.
+func (c *client) do(method, path string, body, out any) ([]byte, error) {
+    var rd io.Reader
+    if body != nil {
+        b, err := json.Marshal(body)
+        if err != nil { return nil, err }
+        rd = bytes.NewReader(b)
+    }
+    req, err := http.NewRequest(method, c.base+path, rd)
+    if err != nil { return nil, err }
+    if c.key != "" { req.Header.Set("Authorization", "Bearer "+c.key) }
+    if body != nil { req.Header.Set("Content-Type", "application/json") }
+    resp, err := c.http.Do(req)
+    if err != nil { return nil, err }
+    defer resp.Body.Close()
+    raw, err := io.ReadAll(resp.Body)
+    if err != nil { return nil, err }
+    if resp.StatusCode/100 != 2 { return raw, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(raw))) }
+    if out != nil {
+        if err := json.Unmarshal(raw, out); err != nil { return raw, fmt.Errorf("decode response: %w", err) }
+    }
+    return raw, nil
+}
+
+func envOr(key, def string) string {
+    if v := os.Getenv(key); v != "" { return v }
+    return def
+}
+
+func truncate(s string, n int) string {
+    s = strings.Join(strings.Fields(s), " ")
+    if r := []rune(s); len(r) > n { return string(r[:n-1]) + "…" }
+    return s
+}
+
+func orDash(s string) string {
+    if s == "" { return "-" }
+    return s
+}
+
+func fail(msg string) {
+    fmt.Fprintln(os.Stderr, msg)
+    os.Exit(2)
+}
@@ -0,0 +1,49 @@
+// Command dispatcher runs AI page workers. It consumes the pages stream and publishes
+// outcomes to the results stream; it serves only /healthz and /metrics.
+package main
+
+import (
+    "net/http"
+    "time"
+
+    "github.com/rs/zerolog/log"
+
+    "github.com/local/aidispatcher/internal/bootstrap"
+    cfgpkg "github.com/local/aidispatcher/internal/config"
+    "github.com/local/aidispatcher/internal/dispatcher"
+)
+
+func main() {
+    cfg := cfgpkg.FromEnv()
+    closeLogs := bootstrap.InitLogging(cfg)
+    defer closeLogs()
+    bootstrap.CheckConfig(cfg)
+
+    rq := bootstrap.OpenQueue(cfg)
+    defer rq.Close()
+
+    mux := http.NewServeMux()
+    bootstrap.RegisterHealth(mux, rq, "dispatcher")
+
+    samplerStop := make(chan struct{})
+    go bootstrap.SampleDepths(rq, 5*time.Second, samplerStop)
+
+    disp, err := dispatcher.New(dispatcher.Config{Concurrency: cfg.Worker.Concurrency}, rq)
+    if err != nil {
+        log.Fatal().Err(err).Msg("failed to init dispatcher")
+    }
+    disp.Start()
+
+    srv := &http.Server{Addr: ":" + bootstrap.Port("DISPATCHER_PORT", "8090"), Handler: mux}
+    bootstrap.Serve(srv)
+
+    // Graceful shutdown: let in-flight pages finish; anything cut off stays pending for the reclaimer
+    ctx, cancel := bootstrap.WaitForSignal(cfg.Worker.ShutdownTimeout)
+    defer cancel()
+    close(samplerStop)
+    if err := disp.Stop(ctx); err != nil {
+        log.Warn().Err(err).Msg("workers did not finish in time")
+    }
+    _ = srv.Shutdown(ctx)
+    log.Info().Msg("dispatcher shutdown complete")
+}
@@ -0,0 +1,41 @@
+// Command orchestrator runs the HTTP API, dashboard, LibreOffice conversion and
+// the consumer of page results. AI workers run separately (cmd/dispatcher).
+package main
+
+import (
+    "net/http"
+    "time"
+
+    "github.com/rs/zerolog/log"
+
+    "github.com/local/aidispatcher/internal/bootstrap"
+    cfgpkg "github.com/local/aidispatcher/internal/config"
+)
+
+func main() {
+    cfg := cfgpkg.FromEnv()
+    closeLogs := bootstrap.InitLogging(cfg)
+    defer closeLogs()
+    bootstrap.CheckConfig(cfg)
+
+    rq := bootstrap.OpenQueue(cfg)
+    defer rq.Close()
+
+    mux := http.NewServeMux()
+    api := bootstrap.NewAPI(cfg, rq, mux)
+    bootstrap.RegisterHealth(mux, rq, "orchestrator")
+
+    samplerStop := make(chan struct{})
+    go bootstrap.SampleDepths(rq, 5*time.Second, samplerStop)
+
+    srv := &http.Server{Addr: ":" + bootstrap.Port("PORT", "8080"), Handler: mux}
+    bootstrap.Serve(srv)
+
+    // Graceful shutdown: stop accepting requests, then let result consumers drain
+    ctx, cancel := bootstrap.WaitForSignal(cfg.Worker.ShutdownTimeout)
+    defer cancel()
+    close(samplerStop)
+    _ = srv.Shutdown(ctx)
+    api.Close(ctx)
+    log.Info().Msg("orchestrator shutdown complete")
+}
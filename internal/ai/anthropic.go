@@ -10,24 +10,64 @@ import (
     "os"
 )
 
-type AnthropicClient struct{ http *http.Client; apiKey string }
+type AnthropicClient struct{ http *http.Client; apiKey string; name string }
 
-func NewAnthropicClient() *AnthropicClient { return &AnthropicClient{http: &http.Client{}, apiKey: os.Getenv("ANTHROPIC_API_KEY")} }
-func (c *AnthropicClient) Name() string { return "anthropic" }
+func NewAnthropicClient() *AnthropicClient { return &AnthropicClient{http: &http.Client{}, apiKey: os.Getenv("ANTHROPIC_API_KEY"), name: "anthropic"} }
+func (c *AnthropicClient) Name() string { return c.name }
+
+func init() {
+    RegisterKind("anthropic", func(cfg ProviderConfig) (Client, error) {
+        c := NewAnthropicClient()
+        // aliased instances (e.g. anthropic_eu) report, and open breakers, under their own name
+        c.name = cfg.Name
+        if k := cfg.Options["API_KEY"]; k != "" { c.apiKey = k }
+        return c, nil
+    })
+}
+
+type anthropicImageSource struct {
+    Type      string `json:"type"`
+    MediaType string `json:"media_type"`
+    Data      string `json:"data"`
+}
+
+type anthropicContentBlock struct {
+    Type   string                `json:"type"`
+    Text   string                `json:"text,omitempty"`
+    Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+type anthropicMessage struct {
+    Role    string                  `json:"role"`
+    Content []anthropicContentBlock `json:"content"`
+}
 
 type anthropicMsgReq struct {
     Model string `json:"model"`
     MaxTokens int `json:"max_tokens"`
-    Messages []struct{ Role string `json:"role"`; Content string `json:"content"` } `json:"messages"`
+    Messages []anthropicMessage `json:"messages"`
 }
 
-type anthropicMsgResp struct { Content []struct{ Text string `json:"text"` } `json:"content"` }
+type anthropicMsgResp struct {
+    Content    []struct{ Text string `json:"text"` } `json:"content"`
+    StopReason string `json:"stop_reason"`
+    Usage   struct {
+        InputTokens  int `json:"input_tokens"`
+        OutputTokens int `json:"output_tokens"`
+    } `json:"usage"`
+}
 
 func (c *AnthropicClient) Do(ctx context.Context, req Request) (Response, error) {
     if c.apiKey == "" { return Response{}, errors.New("missing ANTHROPIC_API_KEY") }
-    prompt := fmt.Sprintf("Extract clean text for page: %s", req.ContentRef)
-    payload := anthropicMsgReq{Model: req.Model, MaxTokens: 1024}
-    payload.Messages = []struct{ Role string `json:"role"`; Content string `json:"content"` }{{Role: "user", Content: prompt}}
+    if len(req.Images) == 0 { return Response{}, ErrNoImage }
+    payload := anthropicMsgReq{Model: req.Model, MaxTokens: outputLimit(ExpectedOutputTokens, req)}
+    // Anthropic recommends placing images before the text instruction
+    msg := anthropicMessage{Role: "user"}
+    for _, img := range req.Images {
+        msg.Content = append(msg.Content, anthropicContentBlock{Type: "image", Source: &anthropicImageSource{Type: "base64", MediaType: img.MIME, Data: img.Base64()}})
+    }
+    msg.Content = append(msg.Content, anthropicContentBlock{Type: "text", Text: req.PromptText()})
+    payload.Messages = []anthropicMessage{msg}
     body, _ := json.Marshal(payload)
     httpReq, _ := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.anthropic.com/v1/messages", bytes.NewReader(body))
     httpReq.Header.Set("x-api-key", c.apiKey)
@@ -36,10 +76,11 @@ func (c *AnthropicClient) Do(ctx context.Context, req Request) (Response, error)
     resp, err := c.http.Do(httpReq)
     if err != nil { return Response{}, err }
     defer resp.Body.Close()
-    if resp.StatusCode == 429 { return Response{}, ErrRateLimited }
-    if resp.StatusCode < 200 || resp.StatusCode >= 300 { return Response{}, fmt.Errorf("anthropic status %d", resp.StatusCode) }
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 { return Response{}, newProviderError(c.Name(), resp, "request-id") }
     var r anthropicMsgResp
-    if err := json.NewDecoder(resp.Body).Decode(&r); err != nil { return Response{}, err }
-    if len(r.Content) == 0 { return Response{}, errors.New("no content") }
-    return Response{Text: r.Content[0].Text}, nil
+    if err := json.NewDecoder(resp.Body).Decode(&r); err != nil { return Response{}, fmt.Errorf("%w: %v", ErrBadResponse, err) }
+    if len(r.Content) == 0 { return Response{}, fmt.Errorf("%w: no content", ErrBadResponse) }
+    out := Response{Text: r.Content[0].Text, TokensIn: r.Usage.InputTokens, TokensOut: r.Usage.OutputTokens}
+    if r.StopReason == "max_tokens" { return out, fmt.Errorf("%s: %w (max_tokens %d)", c.name, ErrTruncated, payload.MaxTokens) }
+    return out, nil
 }
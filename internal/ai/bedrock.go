@@ -0,0 +1,125 @@
+package ai
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/aws/aws-sdk-go-v2/aws"
+    awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+    awscfg "github.com/aws/aws-sdk-go-v2/config"
+    "github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+    "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+    "github.com/aws/smithy-go"
+)
+
+// BedrockClient calls the Bedrock Runtime Converse API, so Anthropic (or other) models are
+// billed through the AWS account. Credentials come from the default AWS chain, as for S3.
+type BedrockClient struct {
+    client    *bedrockruntime.Client
+    name      string
+    maxTokens int
+}
+
+// The bedrock kind. Options ({NAME}_*):
+//   REGION      AWS region of the models (default: AWS_REGION / the shared config)
+//   ENDPOINT    endpoint override, e.g. a local stub server
+//   MAX_TOKENS  completion limit per page image (default ExpectedOutputTokens)
+// Models are Bedrock model or inference profile IDs.
+func init() {
+    RegisterKind("bedrock", func(cfg ProviderConfig) (Client, error) {
+        var opts []func(*awscfg.LoadOptions) error
+        if r := strings.TrimSpace(cfg.Options["REGION"]); r != "" { opts = append(opts, awscfg.WithRegion(r)) }
+        awsConf, err := awscfg.LoadDefaultConfig(context.Background(), opts...)
+        if err != nil { return nil, fmt.Errorf("failed to load AWS config: %w", err) }
+        if awsConf.Region == "" { return nil, errors.New("REGION or AWS_REGION is required") }
+        endpoint := strings.TrimSpace(cfg.Options["ENDPOINT"])
+        c := &BedrockClient{name: cfg.Name, maxTokens: ExpectedOutputTokens}
+        if v := cfg.Options["MAX_TOKENS"]; v != "" {
+            n, err := strconv.Atoi(v)
+            if err != nil || n <= 0 { return nil, errors.New("MAX_TOKENS must be a positive integer") }
+            c.maxTokens = n
+        }
+        c.client = bedrockruntime.NewFromConfig(awsConf, func(o *bedrockruntime.Options) {
+            if endpoint != "" { o.BaseEndpoint = aws.String(endpoint) }
+            // retries and fallbacks are the dispatcher's job; the SDK retrying throttled
+            // calls would only hide 429s from the limiter
+            o.Retryer = aws.NopRetryer{}
+        })
+        return c, nil
+    })
+}
+
+func (c *BedrockClient) Name() string { return c.name }
+
+func (c *BedrockClient) Do(ctx context.Context, req Request) (Response, error) {
+    if len(req.Images) == 0 { return Response{}, ErrNoImage }
+    var content []types.ContentBlock
+    for _, img := range req.Images {
+        format := types.ImageFormat(strings.TrimPrefix(img.MIME, "image/"))
+        content = append(content, &types.ContentBlockMemberImage{Value: types.ImageBlock{
+            Format: format,
+            Source: &types.ImageSourceMemberBytes{Value: img.Data},
+        }})
+    }
+    content = append(content, &types.ContentBlockMemberText{Value: req.PromptText()})
+    out, err := c.client.Converse(ctx, &bedrockruntime.ConverseInput{
+        ModelId:  aws.String(req.Model),
+        Messages: []types.Message{{Role: types.ConversationRoleUser, Content: content}},
+        InferenceConfig: &types.InferenceConfiguration{
+            MaxTokens:   aws.Int32(int32(outputLimit(c.maxTokens, req))),
+            Temperature: aws.Float32(0),
+        },
+    })
+    if err != nil { return Response{}, c.providerError(err) }
+    var r Response
+    if out.Usage != nil {
+        r.TokensIn = int(aws.ToInt32(out.Usage.InputTokens))
+        r.TokensOut = int(aws.ToInt32(out.Usage.OutputTokens))
+    }
+    // as for Gemini, a filtered page says nothing about the model's health: ClassFallback
+    // moves the chain on without counting a breaker failure
+    switch out.StopReason {
+    case types.StopReasonGuardrailIntervened, types.StopReasonContentFiltered:
+        return r, &ProviderError{Provider: c.name, StatusCode: http.StatusOK, Class: ClassFallback, Type: string(out.StopReason), Message: "response blocked"}
+    case types.StopReasonMaxTokens:
+        return r, fmt.Errorf("%s: %w (maxTokens %d)", c.name, ErrTruncated, outputLimit(c.maxTokens, req))
+    }
+    msg, ok := out.Output.(*types.ConverseOutputMemberMessage)
+    if !ok { return r, fmt.Errorf("%w: no message", ErrBadResponse) }
+    var b strings.Builder
+    for _, block := range msg.Value.Content {
+        if t, ok := block.(*types.ContentBlockMemberText); ok { b.WriteString(t.Value) }
+    }
+    r.Text = b.String()
+    return r, nil
+}
+
+// providerError turns an API error into a ProviderError with the status Classify expects.
+// Bedrock reports throttling and quota errors as exceptions; some of them do not arrive
+// as 429 (ModelNotReadyException does, but is a short outage rather than a rate limit).
+// Network and context errors are returned unchanged.
+func (c *BedrockClient) providerError(err error) error {
+    var ae smithy.APIError
+    if !errors.As(err, &ae) { return err }
+    pe := &ProviderError{Provider: c.name, StatusCode: http.StatusBadRequest, Code: ae.ErrorCode(), Message: ae.ErrorMessage()}
+    var re *awshttp.ResponseError
+    if errors.As(err, &re) {
+        if s := re.HTTPStatusCode(); s != 0 { pe.StatusCode = s }
+        pe.RequestID = re.ServiceRequestID()
+    }
+    switch pe.Code {
+    case "ThrottlingException", "ServiceQuotaExceededException", "TooManyRequestsException":
+        pe.StatusCode = http.StatusTooManyRequests
+    case "ModelNotReadyException", "ServiceUnavailableException":
+        pe.StatusCode = http.StatusServiceUnavailable
+    case "InternalServerException":
+        pe.StatusCode = http.StatusInternalServerError
+    case "ModelTimeoutException":
+        pe.StatusCode = http.StatusRequestTimeout
+    }
+    return pe
+}
@@ -0,0 +1,68 @@
+package ai
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "testing"
+)
+
+// newStubBedrock returns a bedrock client whose Converse calls get status and body.
+func newStubBedrock(t *testing.T, status int, errType, body string) Client {
+    t.Helper()
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        if errType != "" { w.Header().Set("X-Amzn-ErrorType", errType) }
+        w.WriteHeader(status)
+        _, _ = w.Write([]byte(body))
+    }))
+    t.Cleanup(srv.Close)
+    dir := t.TempDir()
+    t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+    t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+    t.Setenv("AWS_ACCESS_KEY_ID", "test")
+    t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+    t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+    c, err := kinds["bedrock"](ProviderConfig{Name: "bedrock", Kind: "bedrock", Options: map[string]string{"REGION": "eu-central-1", "ENDPOINT": srv.URL}})
+    if err != nil { t.Fatalf("bedrock client: %v", err) }
+    return c
+}
+
+func converseReply(stopReason string) string {
+    return `{"output":{"message":{"role":"assistant","content":[{"text":"page text"}]}},"stopReason":"` + stopReason +
+        `","usage":{"inputTokens":1200,"outputTokens":300,"totalTokens":1500},"metrics":{"latencyMs":10}}`
+}
+
+func TestBedrockErrors(t *testing.T) {
+    tests := []struct {
+        name    string
+        status  int
+        errType string
+        body    string
+        class   ErrorClass // "" = success
+        is      error
+    }{
+        {name: "answer", status: 200, body: converseReply("end_turn")},
+        {name: "guardrail", status: 200, body: converseReply("guardrail_intervened"), class: ClassFallback},
+        {name: "content filter", status: 200, body: converseReply("content_filtered"), class: ClassFallback},
+        {name: "truncated", status: 200, body: converseReply("max_tokens"), class: ClassTransient, is: ErrTruncated},
+        {name: "throttled", status: 429, errType: "ThrottlingException", body: `{"message":"slow down"}`, class: ClassRateLimited, is: ErrRateLimited},
+        {name: "quota as 400", status: 400, errType: "ServiceQuotaExceededException", body: `{"message":"quota"}`, class: ClassRateLimited},
+        {name: "model not ready", status: 429, errType: "ModelNotReadyException", body: `{"message":"warming up"}`, class: ClassTransient},
+        {name: "model timeout", status: 408, errType: "ModelTimeoutException", body: `{"message":"slow"}`, class: ClassTransient},
+        {name: "bad request", status: 400, errType: "ValidationException", body: `{"message":"bad image"}`, class: ClassFatal},
+    }
+    img := Image{MIME: "image/png", Data: []byte("png")}
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            c := newStubBedrock(t, tt.status, tt.errType, tt.body)
+            resp, err := c.Do(context.Background(), Request{Model: "anthropic.claude", Images: []Image{img}})
+            if got := Classify(err); got != tt.class { t.Fatalf("Do error %v classified %q, want %q", err, got, tt.class) }
+            if tt.is != nil && !errors.Is(err, tt.is) { t.Errorf("error %v is not %v", err, tt.is) }
+            if tt.status == 200 && (resp.TokensIn != 1200 || resp.TokensOut != 300) { t.Errorf("usage = %d/%d, want it reported", resp.TokensIn, resp.TokensOut) }
+            if err == nil && resp.Text != "page text" { t.Errorf("text = %q", resp.Text) }
+        })
+    }
+}
@@ -0,0 +1,161 @@
+package ai
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// ErrorClass decides what the dispatcher does after a failed call.
+type ErrorClass string
+
+const (
+    ClassRateLimited ErrorClass = "rate_limited" // back off this model (breaker opens at once), try another
+    ClassTransient   ErrorClass = "transient"    // retry later or on another model
+    ClassFallback    ErrorClass = "fallback"     // this model declined the page (blocked content); try another, the model is healthy
+    ClassFatal       ErrorClass = "fatal"        // the request itself is wrong; retrying will not help
+)
+
+// ErrBadResponse marks a 2xx reply that could not be used (malformed body, no content).
+var ErrBadResponse = errors.New("bad provider response")
+
+// ErrTruncated marks an answer the provider cut off at the completion limit; the page text
+// is incomplete. It is transient so the chain moves on to the next model.
+var ErrTruncated = errors.New("response truncated at the output token limit")
+
+// ProviderError is a non-2xx reply from a provider API, or a failure a client reports
+// with an explicit Class (a blocked 2xx answer, a local engine that failed).
+type ProviderError struct {
+    Provider   string
+    StatusCode int           // 0 when there was no HTTP reply
+    Class      ErrorClass    // when set, wins over the StatusCode mapping
+    Type       string        // provider error type, e.g. "rate_limit_error", "overloaded_error", "RESOURCE_EXHAUSTED"
+    Code       string        // provider error code, e.g. "rate_limit_exceeded", "insufficient_quota"
+    Message    string
+    RetryAfter time.Duration // from the Retry-After header; 0 if absent
+    RequestID  string
+}
+
+func (e *ProviderError) Error() string {
+    var b strings.Builder
+    b.WriteString(e.Provider)
+    if e.StatusCode != 0 { fmt.Fprintf(&b, " status %d", e.StatusCode) }
+    if kind := e.Code; kind != "" || e.Type != "" {
+        if kind == "" { kind = e.Type }
+        fmt.Fprintf(&b, " (%s)", kind)
+    }
+    if e.Message != "" { b.WriteString(": " + e.Message) }
+    if e.RequestID != "" { b.WriteString(" [request_id=" + e.RequestID + "]") }
+    return b.String()
+}
+
+// Is lets errors.Is(err, ErrRateLimited) keep working for 429 replies.
+func (e *ProviderError) Is(target error) bool { return target == ErrRateLimited && e.rateLimited() }
+
+// rateLimited covers 429 and quota errors reported under another status (Google's
+// RESOURCE_EXHAUSTED).
+func (e *ProviderError) rateLimited() bool {
+    return e.StatusCode == http.StatusTooManyRequests || e.Type == "RESOURCE_EXHAUSTED"
+}
+
+// newProviderError builds a ProviderError from a non-2xx response. OpenAI and Anthropic
+// both wrap details in an "error" object ({"message","type","code"}); code may be a
+// string, a number or null. Google uses {"code","message","status","details"} and puts
+// the retry delay in a RetryInfo detail instead of Retry-After.
+func newProviderError(provider string, resp *http.Response, requestIDHeader string) *ProviderError {
+    e := &ProviderError{Provider: provider, StatusCode: resp.StatusCode, RequestID: resp.Header.Get(requestIDHeader),
+        RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+    var parsed struct {
+        Error struct {
+            Message string `json:"message"`
+            Type    string `json:"type"`
+            Code    any    `json:"code"`
+            Status  string `json:"status"`
+            Details []struct {
+                Type       string `json:"@type"`
+                RetryDelay string `json:"retryDelay"`
+            } `json:"details"`
+        } `json:"error"`
+    }
+    if json.Unmarshal(body, &parsed) == nil && (parsed.Error.Message != "" || parsed.Error.Type != "" || parsed.Error.Status != "") {
+        e.Message, e.Type = parsed.Error.Message, parsed.Error.Type
+        if e.Type == "" { e.Type = parsed.Error.Status }
+        for _, d := range parsed.Error.Details {
+            if d.RetryDelay == "" || e.RetryAfter > 0 { continue }
+            if rd, err := time.ParseDuration(d.RetryDelay); err == nil && rd > 0 { e.RetryAfter = rd }
+        }
+        switch c := parsed.Error.Code.(type) {
+        case string:
+            e.Code = c
+        case float64:
+            // Google repeats the HTTP status here; its status string is the useful part
+            if parsed.Error.Status == "" { e.Code = strconv.Itoa(int(c)) }
+        }
+    } else if s := strings.TrimSpace(string(body)); s != "" {
+        if len(s) > 200 { s = s[:200] }
+        e.Message = s
+    }
+    return e
+}
+
+// parseRetryAfter accepts delay-seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+    v = strings.TrimSpace(v)
+    if v == "" { return 0 }
+    if secs, err := strconv.ParseFloat(v, 64); err == nil {
+        if secs <= 0 { return 0 }
+        return time.Duration(secs * float64(time.Second))
+    }
+    if t, err := http.ParseTime(v); err == nil && t.After(now) { return t.Sub(now) }
+    return 0
+}
+
+// Classify maps an error from Client.Do (or the surrounding call) to an ErrorClass.
+// Errors it does not recognise are fatal, so programming and configuration errors are
+// not retried until the page runs out of attempts.
+func Classify(err error) ErrorClass {
+    if err == nil { return "" }
+    var pe *ProviderError
+    if errors.As(err, &pe) {
+        switch {
+        case pe.Class != "":
+            return pe.Class
+        case pe.rateLimited():
+            return ClassRateLimited
+        case pe.StatusCode == http.StatusRequestTimeout, pe.StatusCode == http.StatusConflict, pe.StatusCode == http.StatusTooEarly,
+            pe.StatusCode >= 500: // includes Anthropic's 529 overloaded_error
+            return ClassTransient
+        default:
+            return ClassFatal
+        }
+    }
+    if errors.Is(err, ErrRateLimited) { return ClassRateLimited }
+    if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || errors.Is(err, ErrBadResponse) ||
+        errors.Is(err, ErrTruncated) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+        return ClassTransient
+    }
+    var ne net.Error
+    if errors.As(err, &ne) { return ClassTransient }
+    return ClassFatal
+}
+
+// Retryable reports whether another attempt (later or on another model) may succeed.
+func Retryable(err error) bool {
+    c := Classify(err)
+    return c == ClassRateLimited || c == ClassTransient || c == ClassFallback
+}
+
+// RetryAfter returns the provider's Retry-After hint carried by err, or 0.
+func RetryAfter(err error) time.Duration {
+    var pe *ProviderError
+    if errors.As(err, &pe) { return pe.RetryAfter }
+    return 0
+}
@@ -0,0 +1,43 @@
+package ai
+
+import (
+    "bytes"
+    "image"
+    _ "image/jpeg"
+    _ "image/png"
+    "unicode/utf8"
+)
+
+// ExpectedOutputTokens is the completion size assumed per page before a call; it matches
+// the max_tokens sent to providers that require one. A dense page of text or a full-page
+// table transcribed as Markdown runs to 2-3k tokens; answers cut off at the limit fail with
+// ErrTruncated.
+const ExpectedOutputTokens = 4096
+
+// outputLimit scales a per-page completion limit to the pages (images) of req, so batched
+// requests are not cut off after the first page. 0 stays 0 (no limit).
+func outputLimit(perPage int, req Request) int {
+    if len(req.Images) > 1 { return perPage * len(req.Images) }
+    return perPage
+}
+
+// EstimateTokens is a conservative guess of the tokens a request will consume (input and
+// output), used to reserve rate budget before the call. Text is counted at ~4 characters
+// per token; images at width*height/750, capped at 1600 per image, which is above what
+// either provider charges for a downscaled page.
+func EstimateTokens(req Request) int {
+    n := utf8.RuneCountInString(req.PromptText())/4 + outputLimit(ExpectedOutputTokens, req)
+    for _, img := range req.Images {
+        n += imageTokens(img)
+    }
+    return n
+}
+
+func imageTokens(img Image) int {
+    const maxPerImage = 1600
+    cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+    if err != nil { return maxPerImage }
+    t := cfg.Width * cfg.Height / 750
+    if t > maxPerImage { return maxPerImage }
+    return t
+}
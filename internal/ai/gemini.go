@@ -0,0 +1,130 @@
+package ai
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// GeminiClient calls the Gemini generateContent API with the page images as inline parts.
+type GeminiClient struct {
+    http      *http.Client
+    apiKey    string
+    name      string
+    baseURL   string // API root without the version, e.g. https://generativelanguage.googleapis.com
+    maxTokens int    // maxOutputTokens; 0 leaves it to the API (thinking models spend part of it)
+}
+
+const geminiBaseURL = "https://generativelanguage.googleapis.com"
+
+func NewGeminiClient() *GeminiClient {
+    return &GeminiClient{http: &http.Client{}, apiKey: os.Getenv("GEMINI_API_KEY"), name: "gemini", baseURL: geminiBaseURL}
+}
+func (c *GeminiClient) Name() string { return c.name }
+
+// The gemini kind. Options ({NAME}_*): API_KEY, BASE_URL (e.g. a local stub server),
+// MAX_TOKENS (maxOutputTokens per page image).
+func init() {
+    RegisterKind("gemini", func(cfg ProviderConfig) (Client, error) {
+        c := NewGeminiClient()
+        c.name = cfg.Name
+        if k := cfg.Options["API_KEY"]; k != "" { c.apiKey = k }
+        if u := strings.TrimRight(strings.TrimSpace(cfg.Options["BASE_URL"]), "/"); u != "" { c.baseURL = u }
+        if v := cfg.Options["MAX_TOKENS"]; v != "" {
+            n, err := strconv.Atoi(v)
+            if err != nil || n < 0 { return nil, errors.New("MAX_TOKENS must be a non-negative integer") }
+            c.maxTokens = n
+        }
+        return c, nil
+    })
+}
+
+type geminiInlineData struct {
+    MimeType string `json:"mime_type"`
+    Data     string `json:"data"`
+}
+
+type geminiPart struct {
+    Text       string            `json:"text,omitempty"`
+    InlineData *geminiInlineData `json:"inline_data,omitempty"`
+}
+
+type geminiContent struct {
+    Role  string       `json:"role,omitempty"`
+    Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+    Temperature     float64 `json:"temperature"`
+    MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+}
+
+type geminiReq struct {
+    Contents         []geminiContent        `json:"contents"`
+    GenerationConfig geminiGenerationConfig `json:"generationConfig"`
+}
+
+type geminiResp struct {
+    Candidates []struct {
+        Content      geminiContent `json:"content"`
+        FinishReason string        `json:"finishReason"`
+    } `json:"candidates"`
+    PromptFeedback struct {
+        BlockReason string `json:"blockReason"`
+    } `json:"promptFeedback"`
+    UsageMetadata struct {
+        PromptTokenCount     int `json:"promptTokenCount"`
+        CandidatesTokenCount int `json:"candidatesTokenCount"`
+        ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+    } `json:"usageMetadata"`
+}
+
+func (c *GeminiClient) Do(ctx context.Context, req Request) (Response, error) {
+    if c.apiKey == "" { return Response{}, errors.New("missing GEMINI_API_KEY") }
+    if len(req.Images) == 0 { return Response{}, ErrNoImage }
+    content := geminiContent{Role: "user"}
+    for _, img := range req.Images {
+        content.Parts = append(content.Parts, geminiPart{InlineData: &geminiInlineData{MimeType: img.MIME, Data: img.Base64()}})
+    }
+    content.Parts = append(content.Parts, geminiPart{Text: req.PromptText()})
+    payload := geminiReq{Contents: []geminiContent{content}, GenerationConfig: geminiGenerationConfig{Temperature: 0, MaxOutputTokens: outputLimit(c.maxTokens, req)}}
+    body, _ := json.Marshal(payload)
+    endpoint := c.baseURL + "/v1beta/models/" + url.PathEscape(req.Model) + ":generateContent"
+    httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+    if err != nil { return Response{}, err }
+    httpReq.Header.Set("x-goog-api-key", c.apiKey)
+    httpReq.Header.Set("Content-Type", "application/json")
+    resp, err := c.http.Do(httpReq)
+    if err != nil { return Response{}, err }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 { return Response{}, newProviderError(c.Name(), resp, "x-request-id") }
+    var r geminiResp
+    if err := json.NewDecoder(resp.Body).Decode(&r); err != nil { return Response{}, fmt.Errorf("%w: %v", ErrBadResponse, err) }
+    out := Response{TokensIn: r.UsageMetadata.PromptTokenCount, TokensOut: r.UsageMetadata.CandidatesTokenCount + r.UsageMetadata.ThoughtsTokenCount}
+    // a blocked page stays blocked for this model, but says nothing about its health:
+    // ClassFallback moves the chain to the next model without counting a breaker failure
+    if r.PromptFeedback.BlockReason != "" {
+        return out, &ProviderError{Provider: c.Name(), StatusCode: resp.StatusCode, Class: ClassFallback, Type: r.PromptFeedback.BlockReason, Message: "prompt blocked"}
+    }
+    if len(r.Candidates) == 0 { return out, fmt.Errorf("%w: no candidates", ErrBadResponse) }
+    cand := r.Candidates[0]
+    switch cand.FinishReason {
+    case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+        return out, &ProviderError{Provider: c.Name(), StatusCode: resp.StatusCode, Class: ClassFallback, Type: cand.FinishReason, Message: "response blocked"}
+    case "MAX_TOKENS":
+        return out, fmt.Errorf("%s: %w (maxOutputTokens %d)", c.Name(), ErrTruncated, payload.GenerationConfig.MaxOutputTokens)
+    }
+    var b strings.Builder
+    for _, p := range cand.Content.Parts {
+        b.WriteString(p.Text)
+    }
+    out.Text = b.String()
+    return out, nil
+}
@@ -0,0 +1,96 @@
+package ai
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+// geminiStub answers every generateContent call with status and body, and keeps
+// the last request for inspection.
+type geminiStub struct {
+    status int
+    body   string
+    path   string
+    key    string
+    req    geminiReq
+}
+
+func (s *geminiStub) client(t *testing.T) Client {
+    t.Helper()
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        s.path, s.key = r.URL.EscapedPath(), r.Header.Get("x-goog-api-key")
+        b, _ := io.ReadAll(r.Body)
+        _ = json.Unmarshal(b, &s.req)
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(s.status)
+        _, _ = io.WriteString(w, s.body)
+    }))
+    t.Cleanup(srv.Close)
+    c, err := kinds["gemini"](ProviderConfig{Name: "gemini-flash", Kind: "gemini",
+        Options: map[string]string{"API_KEY": "k1", "BASE_URL": srv.URL + "/", "MAX_TOKENS": "4096"}})
+    if err != nil { t.Fatal(err) }
+    return c
+}
+
+func geminiCall(c Client, pages int) (Response, error) {
+    req := Request{Model: "gemini-2.5-flash", Prompt: "transcribe"}
+    for i := 0; i < pages; i++ { req.Images = append(req.Images, Image{MIME: "image/png", Data: []byte("png")}) }
+    return c.Do(context.Background(), req)
+}
+
+func TestGeminiAnswer(t *testing.T) {
+    s := &geminiStub{status: 200, body: `{"candidates":[{"content":{"role":"model","parts":[{"text":"first "},{"text":"page"}]},"finishReason":"STOP"}],
+        "usageMetadata":{"promptTokenCount":900,"candidatesTokenCount":120,"thoughtsTokenCount":80}}`}
+    resp, err := geminiCall(s.client(t), 2)
+    if err != nil { t.Fatal(err) }
+    if resp.Text != "first page" || resp.TokensIn != 900 || resp.TokensOut != 200 {
+        t.Errorf("response = %+v; thinking tokens count as output", resp)
+    }
+    if s.path != "/v1beta/models/gemini-2.5-flash:generateContent" || s.key != "k1" { t.Errorf("request to %s with key %q", s.path, s.key) }
+    parts := s.req.Contents[0].Parts
+    if len(parts) != 3 || parts[0].InlineData == nil || parts[2].Text != "transcribe" { t.Errorf("parts = %+v", parts) }
+    if s.req.GenerationConfig.MaxOutputTokens != 2*4096 { t.Errorf("maxOutputTokens = %d for two pages", s.req.GenerationConfig.MaxOutputTokens) }
+}
+
+func TestGeminiErrors(t *testing.T) {
+    t.Run("quota", func(t *testing.T) {
+        // Google reports the wait in a RetryInfo detail, not Retry-After
+        s := &geminiStub{status: 429, body: `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED",
+            "details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"17s"}]}}`}
+        _, err := geminiCall(s.client(t), 1)
+        if Classify(err) != ClassRateLimited || !errors.Is(err, ErrRateLimited) { t.Fatalf("%v classified %s", err, Classify(err)) }
+        if RetryAfter(err) != 17*time.Second { t.Errorf("retry after %v", RetryAfter(err)) }
+        var pe *ProviderError
+        if !errors.As(err, &pe) || pe.Code != "" || pe.Type != "RESOURCE_EXHAUSTED" { t.Errorf("error fields %+v", pe) }
+    })
+    t.Run("overloaded", func(t *testing.T) {
+        s := &geminiStub{status: 503, body: `{"error":{"code":503,"message":"The model is overloaded","status":"UNAVAILABLE"}}`}
+        if _, err := geminiCall(s.client(t), 1); Classify(err) != ClassTransient { t.Errorf("%v classified %s", err, Classify(err)) }
+    })
+    t.Run("bad request", func(t *testing.T) {
+        s := &geminiStub{status: 400, body: `{"error":{"code":400,"message":"Invalid image","status":"INVALID_ARGUMENT"}}`}
+        if _, err := geminiCall(s.client(t), 1); Classify(err) != ClassFatal { t.Errorf("%v classified %s", err, Classify(err)) }
+    })
+    t.Run("prompt blocked", func(t *testing.T) {
+        s := &geminiStub{status: 200, body: `{"promptFeedback":{"blockReason":"PROHIBITED_CONTENT"},"usageMetadata":{"promptTokenCount":900}}`}
+        resp, err := geminiCall(s.client(t), 1)
+        if Classify(err) != ClassFallback || resp.TokensIn != 900 { t.Errorf("%v classified %s, tokens in %d", err, Classify(err), resp.TokensIn) }
+    })
+    t.Run("no candidates", func(t *testing.T) {
+        s := &geminiStub{status: 200, body: `{"candidates":[]}`}
+        if _, err := geminiCall(s.client(t), 1); !errors.Is(err, ErrBadResponse) { t.Errorf("error %v", err) }
+    })
+
+    for reason, want := range map[string]ErrorClass{"SAFETY": ClassFallback, "RECITATION": ClassFallback, "MAX_TOKENS": ClassTransient} {
+        s := &geminiStub{status: 200, body: `{"candidates":[{"content":{"parts":[{"text":"partial"}]},"finishReason":"` + reason + `"}]}`}
+        resp, err := geminiCall(s.client(t), 1)
+        if Classify(err) != want || resp.Text != "" { t.Errorf("finishReason %s: %v classified %s, text %q", reason, err, Classify(err), resp.Text) }
+        if reason == "MAX_TOKENS" && !errors.Is(err, ErrTruncated) { t.Errorf("MAX_TOKENS: %v is not ErrTruncated", err) }
+    }
+}
@@ -13,40 +13,87 @@ import (
 type OpenAIClient struct{
     http *http.Client
     apiKey string
+    name      string            // provider name in errors
+    endpoint  string            // chat completions URL
+    headers   map[string]string // extra request headers
+    detail    string            // image_url detail; "" omits it
+    maxTokens int               // 0 leaves max_tokens to the server
+    keyless   bool              // servers that need no API key (self-hosted)
 }
 
 func NewOpenAIClient() *OpenAIClient {
-    return &OpenAIClient{http: &http.Client{}, apiKey: os.Getenv("OPENAI_API_KEY")}
+    return &OpenAIClient{http: &http.Client{}, apiKey: os.Getenv("OPENAI_API_KEY"), name: "openai",
+        endpoint: "https://api.openai.com/v1/chat/completions", detail: "high"}
+}
+func (c *OpenAIClient) Name() string { return c.name }
+
+func init() {
+    RegisterKind("openai", func(cfg ProviderConfig) (Client, error) {
+        c := NewOpenAIClient()
+        // aliased instances (e.g. openai_eu) report, and open breakers, under their own name
+        c.name = cfg.Name
+        if k := cfg.Options["API_KEY"]; k != "" { c.apiKey = k }
+        return c, nil
+    })
+}
+
+type openAIContentPart struct {
+    Type     string          `json:"type"`
+    Text     string          `json:"text,omitempty"`
+    ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+    URL    string `json:"url"`
+    Detail string `json:"detail,omitempty"`
+}
+
+type openAIMessage struct {
+    Role    string              `json:"role"`
+    Content []openAIContentPart `json:"content"`
 }
-func (c *OpenAIClient) Name() string { return "openai" }
 
 type openAIChatReq struct {
     Model string `json:"model"`
-    Messages []struct{ Role string `json:"role"`; Content []map[string]string `json:"content"` } `json:"messages"`
+    Messages []openAIMessage `json:"messages"`
     Temperature float64 `json:"temperature"`
+    MaxTokens int `json:"max_tokens,omitempty"`
 }
 
 type openAIChatResp struct {
-    Choices []struct{ Message struct{ Content string `json:"content"` } `json:"message"` } `json:"choices"`
+    Choices []struct {
+        Message      struct{ Content string `json:"content"` } `json:"message"`
+        FinishReason string `json:"finish_reason"`
+    } `json:"choices"`
+    Usage   struct {
+        PromptTokens     int `json:"prompt_tokens"`
+        CompletionTokens int `json:"completion_tokens"`
+    } `json:"usage"`
 }
 
 func (c *OpenAIClient) Do(ctx context.Context, req Request) (Response, error) {
-    if c.apiKey == "" { return Response{}, errors.New("missing OPENAI_API_KEY") }
-    prompt := fmt.Sprintf("Extract clean text for page: %s", req.ContentRef)
-    payload := openAIChatReq{Model: req.Model, Temperature: 0}
-    msg := struct{ Role string `json:"role"`; Content []map[string]string `json:"content"` }{Role: "user", Content: []map[string]string{{"type":"text","text": prompt}}}
-    payload.Messages = []struct{ Role string `json:"role"`; Content []map[string]string `json:"content"` }{msg}
+    if c.apiKey == "" && !c.keyless { return Response{}, errors.New("missing OPENAI_API_KEY") }
+    if len(req.Images) == 0 { return Response{}, ErrNoImage }
+    payload := openAIChatReq{Model: req.Model, Temperature: 0, MaxTokens: outputLimit(c.maxTokens, req)}
+    msg := openAIMessage{Role: "user", Content: []openAIContentPart{{Type: "text", Text: req.PromptText()}}}
+    for _, img := range req.Images {
+        msg.Content = append(msg.Content, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: img.DataURL(), Detail: c.detail}})
+    }
+    payload.Messages = []openAIMessage{msg}
     body, _ := json.Marshal(payload)
-    httpReq, _ := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.openai.com/v1/chat/completions", bytes.NewReader(body))
-    httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+    httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+    if err != nil { return Response{}, err }
+    if c.apiKey != "" { httpReq.Header.Set("Authorization", "Bearer "+c.apiKey) }
     httpReq.Header.Set("Content-Type", "application/json")
+    for k, v := range c.headers { httpReq.Header.Set(k, v) }
     resp, err := c.http.Do(httpReq)
     if err != nil { return Response{}, err }
     defer resp.Body.Close()
-    if resp.StatusCode == 429 { return Response{}, ErrRateLimited }
-    if resp.StatusCode < 200 || resp.StatusCode >= 300 { return Response{}, fmt.Errorf("openai status %d", resp.StatusCode) }
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 { return Response{}, newProviderError(c.Name(), resp, "x-request-id") }
     var r openAIChatResp
-    if err := json.NewDecoder(resp.Body).Decode(&r); err != nil { return Response{}, err }
-    if len(r.Choices) == 0 { return Response{}, errors.New("no choices") }
-    return Response{Text: r.Choices[0].Message.Content}, nil
+    if err := json.NewDecoder(resp.Body).Decode(&r); err != nil { return Response{}, fmt.Errorf("%w: %v", ErrBadResponse, err) }
+    if len(r.Choices) == 0 { return Response{}, fmt.Errorf("%w: no choices", ErrBadResponse) }
+    out := Response{Text: r.Choices[0].Message.Content, TokensIn: r.Usage.PromptTokens, TokensOut: r.Usage.CompletionTokens}
+    if r.Choices[0].FinishReason == "length" { return out, fmt.Errorf("%s: %w (max_tokens %d)", c.name, ErrTruncated, payload.MaxTokens) }
+    return out, nil
 }
@@ -0,0 +1,52 @@
+package ai
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+)
+
+// The openai_compatible kind targets self-hosted servers that implement the OpenAI chat
+// completions API with image_url parts (vLLM, llama.cpp server, Ollama, LM Studio).
+// Options ({NAME}_*):
+//   BASE_URL      API root including the version, e.g. http://gpu-01:8000/v1 (required)
+//   API_KEY       bearer token, if the server checks one
+//   HEADERS       extra headers, "Name: value; Name2: value2"
+//   IMAGE_DETAIL  image_url detail (default: omitted; some servers reject it)
+//   MAX_TOKENS    completion limit per page image (default ExpectedOutputTokens; 0 leaves it to the server)
+func init() {
+    RegisterKind("openai_compatible", func(cfg ProviderConfig) (Client, error) {
+        base := strings.TrimRight(strings.TrimSpace(cfg.Options["BASE_URL"]), "/")
+        if base == "" { return nil, errors.New("BASE_URL is required for openai_compatible providers") }
+        maxTokens := ExpectedOutputTokens
+        if v := cfg.Options["MAX_TOKENS"]; v != "" {
+            n, err := strconv.Atoi(v)
+            if err != nil || n < 0 { return nil, errors.New("MAX_TOKENS must be a non-negative integer") }
+            maxTokens = n
+        }
+        headers, err := parseHeaders(cfg.Options["HEADERS"])
+        if err != nil { return nil, err }
+        return NewOpenAICompatClient(cfg.Name, base, cfg.Options["API_KEY"], headers, cfg.Options["IMAGE_DETAIL"], maxTokens), nil
+    })
+}
+
+// NewOpenAICompatClient returns a client for an OpenAI-compatible server at baseURL
+// (".../v1"); apiKey may be empty.
+func NewOpenAICompatClient(name, baseURL, apiKey string, headers map[string]string, detail string, maxTokens int) *OpenAIClient {
+    return &OpenAIClient{http: &http.Client{}, apiKey: apiKey, name: name, endpoint: baseURL + "/chat/completions",
+        headers: headers, detail: detail, maxTokens: maxTokens, keyless: true}
+}
+
+// parseHeaders parses "Name: value; Name2: value2".
+func parseHeaders(s string) (map[string]string, error) {
+    out := map[string]string{}
+    for _, h := range strings.Split(s, ";") {
+        if strings.TrimSpace(h) == "" { continue }
+        k, v, ok := strings.Cut(h, ":")
+        k = strings.TrimSpace(k)
+        if !ok || k == "" { return nil, errors.New("HEADERS entries must look like \"Name: value\"") }
+        out[http.CanonicalHeaderKey(k)] = strings.TrimSpace(v)
+    }
+    return out, nil
+}
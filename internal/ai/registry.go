@@ -0,0 +1,65 @@
+package ai
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+)
+
+// ProviderConfig is what a client implementation gets to build one provider instance.
+type ProviderConfig struct {
+    Name    string            // provider name used in chains and limiter keys
+    Kind    string            // implementation, see RegisterKind
+    Options map[string]string // {NAME}_* settings without the prefix, e.g. API_KEY, BASE_URL
+}
+
+// Factory builds the client of one configured provider.
+type Factory func(cfg ProviderConfig) (Client, error)
+
+var kinds = map[string]Factory{}
+
+// RegisterKind makes a client implementation available to AI_PROVIDERS under kind.
+// Implementations register themselves from init.
+func RegisterKind(kind string, f Factory) { kinds[kind] = f }
+
+// Kinds lists the registered client implementations.
+func Kinds() []string {
+    out := make([]string, 0, len(kinds))
+    for k := range kinds {
+        out = append(out, k)
+    }
+    sort.Strings(out)
+    return out
+}
+
+// Registry holds one client per configured provider.
+type Registry struct {
+    clients map[string]Client
+}
+
+// NewRegistry builds a client for every provider config. Providers that fail to build are
+// left out and reported in the returned error; the rest of the registry is usable.
+func NewRegistry(cfgs []ProviderConfig) (*Registry, error) {
+    r := &Registry{clients: map[string]Client{}}
+    var errs []error
+    for _, cfg := range cfgs {
+        f, ok := kinds[cfg.Kind]
+        if !ok {
+            errs = append(errs, fmt.Errorf("provider %s: unknown kind %q (available: %v)", cfg.Name, cfg.Kind, Kinds()))
+            continue
+        }
+        c, err := f(cfg)
+        if err != nil {
+            errs = append(errs, fmt.Errorf("provider %s: %w", cfg.Name, err))
+            continue
+        }
+        r.clients[cfg.Name] = c
+    }
+    return r, errors.Join(errs...)
+}
+
+// Client returns the client of provider name.
+func (r *Registry) Client(name string) (Client, bool) {
+    c, ok := r.clients[name]
+    return c, ok
+}
@@ -0,0 +1,102 @@
+package ai
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "runtime"
+    "strconv"
+    "strings"
+)
+
+// TesseractClient OCRs page images with a local tesseract binary. It ignores the prompt
+// and reports no token usage, so it is a cheap last resort or an offline engine.
+type TesseractClient struct {
+    name      string
+    binary    string
+    langs     string // e.g. "hrv+eng"
+    psm       int    // page segmentation mode
+    semaphore chan struct{}
+}
+
+// The tesseract kind. Options ({NAME}_*):
+//   BINARY       path of the tesseract binary (default: tesseract on PATH)
+//   LANGS        traineddata languages joined with "+" (default: eng)
+//   PSM          page segmentation mode (default: 3, fully automatic)
+//   MAX_WORKERS  concurrent tesseract processes per worker process (default: CPU count)
+func init() {
+    RegisterKind("tesseract", func(cfg ProviderConfig) (Client, error) {
+        c := &TesseractClient{name: cfg.Name, binary: "tesseract", langs: "eng", psm: 3}
+        if v := strings.TrimSpace(cfg.Options["BINARY"]); v != "" { c.binary = v }
+        if v := strings.TrimSpace(cfg.Options["LANGS"]); v != "" { c.langs = v }
+        if v := cfg.Options["PSM"]; v != "" {
+            n, err := strconv.Atoi(v)
+            if err != nil || n < 0 || n > 13 { return nil, errors.New("PSM must be between 0 and 13") }
+            c.psm = n
+        }
+        workers := runtime.NumCPU()
+        if v := cfg.Options["MAX_WORKERS"]; v != "" {
+            n, err := strconv.Atoi(v)
+            if err != nil || n <= 0 { return nil, errors.New("MAX_WORKERS must be a positive integer") }
+            workers = n
+        }
+        c.semaphore = make(chan struct{}, workers)
+        if err := c.checkInstallation(); err != nil { return nil, err }
+        return c, nil
+    })
+}
+
+func (c *TesseractClient) Name() string { return c.name }
+
+// checkInstallation verifies the binary runs and has the configured languages.
+func (c *TesseractClient) checkInstallation() error {
+    out, err := exec.Command(c.binary, "--list-langs").CombinedOutput()
+    if err != nil { return fmt.Errorf("tesseract not available: %w", err) }
+    have := map[string]bool{}
+    for _, l := range strings.Split(string(out), "\n") {
+        have[strings.TrimSpace(l)] = true
+    }
+    for _, l := range strings.Split(c.langs, "+") {
+        if !have[l] { return fmt.Errorf("tesseract language %q not installed", l) }
+    }
+    return nil
+}
+
+// Do OCRs every image of the request; texts of multiple images are separated by a blank line.
+func (c *TesseractClient) Do(ctx context.Context, req Request) (Response, error) {
+    if len(req.Images) == 0 { return Response{}, ErrNoImage }
+    select {
+    case c.semaphore <- struct{}{}:
+    case <-ctx.Done():
+        return Response{}, ctx.Err()
+    }
+    defer func() { <-c.semaphore }()
+
+    texts := make([]string, 0, len(req.Images))
+    for _, img := range req.Images {
+        t, err := c.ocr(ctx, img)
+        if err != nil { return Response{}, err }
+        texts = append(texts, t)
+    }
+    return Response{Text: strings.Join(texts, "\n\n")}, nil
+}
+
+func (c *TesseractClient) ocr(ctx context.Context, img Image) (string, error) {
+    cmd := exec.CommandContext(ctx, c.binary, "stdin", "stdout", "-l", c.langs, "--psm", strconv.Itoa(c.psm))
+    // one thread per process; parallelism comes from MAX_WORKERS
+    cmd.Env = append(os.Environ(), "OMP_THREAD_LIMIT=1")
+    cmd.Stdin = bytes.NewReader(img.Data)
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout, cmd.Stderr = &stdout, &stderr
+    if err := cmd.Run(); err != nil {
+        if ctx.Err() != nil { return "", ctx.Err() }
+        msg := strings.TrimSpace(stderr.String())
+        if len(msg) > 200 { msg = msg[:200] }
+        // a crash or a killed process may not happen again; the chain and the retries decide
+        return "", &ProviderError{Provider: c.name, Class: ClassTransient, Type: "process_failed", Message: fmt.Sprintf("%v: %s", err, msg)}
+    }
+    return strings.TrimSpace(strings.ReplaceAll(stdout.String(), "\f", "")), nil
+}
@@ -2,20 +2,44 @@ package ai
 
 import (
     "context"
+    "encoding/base64"
     "errors"
     "time"
 )
 
+// DefaultPrompt is used when the request does not carry its own prompt.
+const DefaultPrompt = "Extract all text from this page image. Preserve reading order and paragraphs. Return only the extracted text."
+
+// Image is an encoded page image (PNG or JPEG) sent inline to vision models.
+type Image struct {
+    Data []byte
+    MIME string
+}
+
+// Base64 returns the standard base64 encoding of the image bytes.
+func (i Image) Base64() string { return base64.StdEncoding.EncodeToString(i.Data) }
+
+// DataURL returns the image as a data: URL (OpenAI image_url format).
+func (i Image) DataURL() string { return "data:" + i.MIME + ";base64," + i.Base64() }
+
 // Request represents a generic AI inference request for a page.
 type Request struct {
     JobID      string
     PageID     int
     ContentRef string
     Model      string
+    Prompt     string
+    Images     []Image
     Params     map[string]any
     Timeout    time.Duration
 }
 
+// PromptText returns the request prompt or DefaultPrompt when empty.
+func (r Request) PromptText() string {
+    if r.Prompt != "" { return r.Prompt }
+    return DefaultPrompt
+}
+
 type Response struct {
     Text   string
     TokensIn  int
@@ -30,6 +54,7 @@ type Client interface {
 
 var (
     ErrRateLimited = errors.New("rate_limited")
+    ErrNoImage     = errors.New("no page image in request")
 )
 
 func IsRateLimited(err error) bool { return errors.Is(err, ErrRateLimited) }
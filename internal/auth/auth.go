@@ -0,0 +1,209 @@
+// Package auth authenticates API clients by key and restricts internal routes to loopback.
+//
+// Keys are never stored in clear: the keys file holds their SHA-256. One client per line:
+//
+//	client_id:sha256-hex:scope,scope
+//
+// e.g. "ghost:9f86d08...:submit,read,cancel". '#' starts a comment. The file is re-read
+// when it changes, so keys can be rotated without a restart.
+package auth
+
+import (
+    "bufio"
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "net"
+    "net/http"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/rs/zerolog/log"
+)
+
+// Scope grants access to a group of endpoints.
+type Scope string
+
+const (
+    ScopeSubmit Scope = "submit" // create jobs
+    ScopeRead   Scope = "read"   // progress and results
+    ScopeCancel Scope = "cancel" // cancel jobs
+    ScopeAdmin  Scope = "admin"  // operational endpoints; also reads/cancels jobs of other clients
+)
+
+var knownScopes = map[Scope]bool{ScopeSubmit: true, ScopeRead: true, ScopeCancel: true, ScopeAdmin: true}
+
+// Client is an authenticated API caller.
+type Client struct {
+    ID     string
+    Scopes map[Scope]bool
+}
+
+// Has reports whether the client holds scope (admin implies every scope).
+func (c Client) Has(s Scope) bool { return c.Scopes[s] || c.Scopes[ScopeAdmin] }
+
+// Authenticator resolves API keys to clients.
+type Authenticator struct {
+    enabled bool
+    path    string
+
+    mu       sync.RWMutex
+    modTime  time.Time
+    byHash   map[string]Client
+    internal map[string]Client // in-process keys, see IssueKey
+}
+
+// New loads the keys file. With enabled=false every request is treated as an admin
+// client named "anonymous" (local development only).
+func New(enabled bool, keysFile string) (*Authenticator, error) {
+    a := &Authenticator{enabled: enabled, path: keysFile, byHash: map[string]Client{}, internal: map[string]Client{}}
+    if enabled && keysFile != "" {
+        if _, err := os.Stat(keysFile); os.IsNotExist(err) {
+            log.Warn().Str("file", keysFile).Msg("api keys file missing; all API requests will be rejected until it is created")
+        }
+        if err := a.reload(); err != nil { return nil, err }
+    }
+    return a, nil
+}
+
+// HashKey returns the hex SHA-256 stored in the keys file for key.
+func HashKey(key string) string {
+    sum := sha256.Sum256([]byte(key))
+    return hex.EncodeToString(sum[:])
+}
+
+// IssueKey creates a random key that lives only in this process, for in-process callers
+// such as the dashboard proxy.
+func (a *Authenticator) IssueKey(clientID string, scopes ...Scope) (string, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil { return "", err }
+    key := hex.EncodeToString(b)
+    c := Client{ID: clientID, Scopes: map[Scope]bool{}}
+    for _, s := range scopes { c.Scopes[s] = true }
+    a.mu.Lock()
+    a.internal[HashKey(key)] = c
+    a.mu.Unlock()
+    return key, nil
+}
+
+func (a *Authenticator) reload() error {
+    fi, err := os.Stat(a.path)
+    if os.IsNotExist(err) {
+        // a removed file revokes every key
+        a.mu.Lock()
+        a.byHash = map[string]Client{}
+        a.modTime = time.Time{}
+        a.mu.Unlock()
+        return nil
+    }
+    if err != nil { return fmt.Errorf("api keys file: %w", err) }
+    a.mu.RLock()
+    same := fi.ModTime().Equal(a.modTime)
+    a.mu.RUnlock()
+    if same { return nil }
+
+    f, err := os.Open(a.path)
+    if err != nil { return fmt.Errorf("api keys file: %w", err) }
+    defer f.Close()
+    byHash := map[string]Client{}
+    sc := bufio.NewScanner(f)
+    for n := 1; sc.Scan(); n++ {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") { continue }
+        parts := strings.SplitN(line, ":", 3)
+        if len(parts) != 3 || parts[0] == "" {
+            return fmt.Errorf("api keys file %s:%d: expected client_id:sha256:scopes", a.path, n)
+        }
+        hash := strings.ToLower(strings.TrimSpace(parts[1]))
+        if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+            return fmt.Errorf("api keys file %s:%d: key hash must be 64 hex chars", a.path, n)
+        }
+        c := Client{ID: strings.TrimSpace(parts[0]), Scopes: map[Scope]bool{}}
+        for _, s := range strings.Split(parts[2], ",") {
+            s = strings.TrimSpace(s)
+            if s == "" { continue }
+            if !knownScopes[Scope(s)] {
+                return fmt.Errorf("api keys file %s:%d: unknown scope %q", a.path, n, s)
+            }
+            c.Scopes[Scope(s)] = true
+        }
+        byHash[hash] = c
+    }
+    if err := sc.Err(); err != nil { return fmt.Errorf("api keys file: %w", err) }
+
+    a.mu.Lock()
+    a.byHash = byHash
+    a.modTime = fi.ModTime()
+    a.mu.Unlock()
+    log.Info().Str("file", a.path).Int("clients", len(byHash)).Msg("api keys loaded")
+    return nil
+}
+
+// Authenticate resolves the key in "Authorization: Bearer <key>" or "X-API-Key".
+func (a *Authenticator) Authenticate(r *http.Request) (Client, bool) {
+    if !a.enabled {
+        return Client{ID: "anonymous", Scopes: map[Scope]bool{ScopeAdmin: true}}, true
+    }
+    key := r.Header.Get("X-API-Key")
+    if v := r.Header.Get("Authorization"); key == "" && len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+        key = strings.TrimSpace(v[7:])
+    }
+    if key == "" { return Client{}, false }
+    if a.path != "" {
+        if err := a.reload(); err != nil {
+            // keep serving the last good copy
+            log.Warn().Err(err).Msg("api keys reload failed")
+        }
+    }
+    h := HashKey(key)
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    if c, ok := a.byHash[h]; ok { return c, true }
+    c, ok := a.internal[h]
+    return c, ok
+}
+
+type ctxKey struct{}
+
+// ClientFrom returns the client stored by Require.
+func ClientFrom(ctx context.Context) (Client, bool) {
+    c, ok := ctx.Value(ctxKey{}).(Client)
+    return c, ok
+}
+
+// Require rejects requests without a valid key (401) or without scope (403).
+func (a *Authenticator) Require(scope Scope, next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        c, ok := a.Authenticate(r)
+        if !ok {
+            w.Header().Set("WWW-Authenticate", `Bearer realm="aidispatcher"`)
+            http.Error(w, "missing or invalid API key", http.StatusUnauthorized)
+            return
+        }
+        if !c.Has(scope) {
+            log.Warn().Str("client_id", c.ID).Str("scope", string(scope)).Str("path", r.URL.Path).Msg("api scope denied")
+            http.Error(w, fmt.Sprintf("API key lacks scope %q", scope), http.StatusForbidden)
+            return
+        }
+        next(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, c)))
+    }
+}
+
+// LocalOnly serves only direct loopback requests. Anything carrying proxy headers came
+// from outside even if the proxy itself connects over loopback.
+func LocalOnly(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        host, _, err := net.SplitHostPort(r.RemoteAddr)
+        if err != nil { host = r.RemoteAddr }
+        ip := net.ParseIP(host)
+        if ip == nil || !ip.IsLoopback() || r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("Forwarded") != "" {
+            http.NotFound(w, r)
+            return
+        }
+        next(w, r)
+    }
+}
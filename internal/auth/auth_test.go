@@ -0,0 +1,126 @@
+package auth
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func writeKeys(t *testing.T, path string, mtime time.Time, lines ...string) {
+    t.Helper()
+    if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil { t.Fatal(err) }
+    // explicit mtimes, so a rewrite within the filesystem's timestamp granularity is still seen
+    if err := os.Chtimes(path, mtime, mtime); err != nil { t.Fatal(err) }
+}
+
+// call runs a request with key through Require(scope) and returns the status and the
+// client the handler saw.
+func call(a *Authenticator, scope Scope, key string) (int, string) {
+    var seen string
+    h := a.Require(scope, func(w http.ResponseWriter, r *http.Request) {
+        c, _ := ClientFrom(r.Context())
+        seen = c.ID
+    })
+    r := httptest.NewRequest(http.MethodGet, "/api/job", nil)
+    if key != "" { r.Header.Set("Authorization", "Bearer "+key) }
+    w := httptest.NewRecorder()
+    h(w, r)
+    return w.Code, seen
+}
+
+func TestRequireScopes(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "keys")
+    writeKeys(t, path, time.Now(),
+        "# reader only",
+        "ghost:"+HashKey("ghost-key")+":read",
+        "ops:"+strings.ToUpper(HashKey("ops-key"))+":admin",
+    )
+    a, err := New(true, path)
+    if err != nil { t.Fatal(err) }
+
+    cases := []struct {
+        key    string
+        scope  Scope
+        status int
+        client string
+    }{
+        {"", ScopeRead, http.StatusUnauthorized, ""},
+        {"unknown", ScopeRead, http.StatusUnauthorized, ""},
+        {"ghost-key", ScopeRead, http.StatusOK, "ghost"},
+        {"ghost-key", ScopeSubmit, http.StatusForbidden, ""},
+        {"ghost-key", ScopeAdmin, http.StatusForbidden, ""},
+        {"ops-key", ScopeCancel, http.StatusOK, "ops"}, // admin implies every scope
+    }
+    for _, c := range cases {
+        if status, client := call(a, c.scope, c.key); status != c.status || client != c.client {
+            t.Errorf("key %q scope %s: got %d %q, want %d %q", c.key, c.scope, status, client, c.status, c.client)
+        }
+    }
+
+    r := httptest.NewRequest(http.MethodGet, "/", nil)
+    r.Header.Set("X-API-Key", "ghost-key")
+    if c, ok := a.Authenticate(r); !ok || c.ID != "ghost" { t.Errorf("X-API-Key: %v %v", c, ok) }
+}
+
+func TestKeysFileRotation(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "keys")
+    start := time.Now().Add(-time.Hour)
+    writeKeys(t, path, start, "ghost:"+HashKey("old")+":submit,read")
+    a, err := New(true, path)
+    if err != nil { t.Fatal(err) }
+    if status, _ := call(a, ScopeSubmit, "old"); status != http.StatusOK { t.Fatalf("initial key: %d", status) }
+
+    writeKeys(t, path, start.Add(time.Minute), "ghost:"+HashKey("new")+":read")
+    if status, _ := call(a, ScopeRead, "old"); status != http.StatusUnauthorized { t.Errorf("rotated-out key: %d", status) }
+    if status, _ := call(a, ScopeSubmit, "new"); status != http.StatusForbidden { t.Errorf("new key scopes: %d", status) }
+
+    // a broken edit keeps the last good copy
+    writeKeys(t, path, start.Add(2*time.Minute), "ghost:"+HashKey("new")+":read,superuser")
+    if status, _ := call(a, ScopeRead, "new"); status != http.StatusOK { t.Errorf("after a bad edit: %d", status) }
+
+    // removing the file revokes everything except in-process keys
+    internal, err := a.IssueKey("web", ScopeRead)
+    if err != nil { t.Fatal(err) }
+    if err := os.Remove(path); err != nil { t.Fatal(err) }
+    if status, _ := call(a, ScopeRead, "new"); status != http.StatusUnauthorized { t.Errorf("after removal: %d", status) }
+    if status, client := call(a, ScopeRead, internal); status != http.StatusOK || client != "web" { t.Errorf("issued key: %d %q", status, client) }
+    if status, _ := call(a, ScopeCancel, internal); status != http.StatusForbidden { t.Errorf("issued key beyond its scopes: %d", status) }
+}
+
+func TestKeysFileErrors(t *testing.T) {
+    for name, line := range map[string]string{
+        "missing scopes": "ghost:" + HashKey("k"),
+        "short hash":     "ghost:abc123:read",
+        "unknown scope":  "ghost:" + HashKey("k") + ":read,write",
+        "empty client":   ":" + HashKey("k") + ":read",
+    } {
+        path := filepath.Join(t.TempDir(), "keys")
+        writeKeys(t, path, time.Now(), line)
+        if _, err := New(true, path); err == nil { t.Errorf("%s: accepted %q", name, line) }
+    }
+}
+
+func TestLocalOnly(t *testing.T) {
+    h := LocalOnly(func(w http.ResponseWriter, r *http.Request) {})
+    for _, c := range []struct {
+        remote, header string
+        want           int
+    }{
+        {"127.0.0.1:4000", "", http.StatusOK},
+        {"[::1]:4000", "", http.StatusOK},
+        {"10.1.2.3:4000", "", http.StatusNotFound},
+        {"127.0.0.1:4000", "X-Forwarded-For", http.StatusNotFound},
+        {"127.0.0.1:4000", "Forwarded", http.StatusNotFound},
+    } {
+        r := httptest.NewRequest(http.MethodPost, "/internal/results", nil)
+        r.RemoteAddr = c.remote
+        if c.header != "" { r.Header.Set(c.header, "for=198.51.100.1") }
+        w := httptest.NewRecorder()
+        h(w, r)
+        if w.Code != c.want { t.Errorf("%s %s: %d, want %d", c.remote, c.header, w.Code, c.want) }
+    }
+}
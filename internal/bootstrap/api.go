@@ -0,0 +1,178 @@
+package bootstrap
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "os"
+
+    "github.com/rs/zerolog/log"
+
+    "github.com/local/aidispatcher/internal/auth"
+    cfgpkg "github.com/local/aidispatcher/internal/config"
+    "github.com/local/aidispatcher/internal/converter"
+    "github.com/local/aidispatcher/internal/filetype"
+    "github.com/local/aidispatcher/internal/limiter"
+    "github.com/local/aidispatcher/internal/orchestrator"
+    "github.com/local/aidispatcher/internal/prompt"
+    "github.com/local/aidispatcher/internal/queue"
+    "github.com/local/aidispatcher/internal/secrets"
+    "github.com/local/aidispatcher/internal/statuscheck"
+    "github.com/local/aidispatcher/internal/store"
+    web "github.com/local/aidispatcher/internal/web"
+)
+
+// API is the orchestrator side: HTTP API, dashboard, LibreOffice conversion and result consumers.
+type API struct {
+    Orchestrator *orchestrator.Orchestrator
+    status       *store.RedisStatus
+    pages        *store.PageStore
+    usage        *store.UsageStore
+    conv         *converter.LibreOffice
+    vault        *secrets.Vault
+    breakers     *limiter.Adaptive
+    promptStop   chan struct{}
+}
+
+// NewAPI wires the orchestrator, stores and dashboard onto mux and starts result consumers.
+func NewAPI(cfg cfgpkg.Config, rq *queue.RedisQueue, mux *http.ServeMux) *API {
+    // Status store
+    rs, err := store.NewRedisStatus(cfg.Queue.RedisURL)
+    if err != nil {
+        log.Fatal().Err(err).Msg("failed to init redis status store")
+    }
+
+    // Page store
+    ps, err := store.NewPageStore(cfg.Queue.RedisURL)
+    if err != nil { log.Fatal().Err(err).Msg("failed to init page store") }
+
+    // Monthly usage/cost counters per client and user
+    us, err := store.NewUsageStore(cfg.Queue.RedisURL)
+    if err != nil { log.Fatal().Err(err).Msg("failed to init usage store") }
+
+    // LibreOffice converter
+    librePort := 8100
+    if p := os.Getenv("LIBREOFFICE_PORT"); p != "" {
+        fmt.Sscanf(p, "%d", &librePort)
+    }
+    maxWorkers := 4
+    if w := os.Getenv("LIBREOFFICE_MAX_WORKERS"); w != "" {
+        fmt.Sscanf(w, "%d", &maxWorkers)
+    }
+    conv := converter.NewLibreOffice(librePort, maxWorkers)
+    if err := conv.Initialize(); err != nil {
+        log.Warn().Err(err).Msg("LibreOffice converter initialization failed - Office document conversion will not be available")
+    } else {
+        log.Info().Msg("LibreOffice converter initialized")
+    }
+
+    // Prompt templates (used to validate requests; workers render them)
+    prompts := prompt.NewLibrary(cfg.Prompt.DefaultTemplate)
+    if _, err := prompts.LoadDir(cfg.Prompt.Dir); err != nil {
+        log.Warn().Err(err).Str("dir", cfg.Prompt.Dir).Msg("failed to load prompt templates; using built-ins")
+    }
+    promptStop := make(chan struct{})
+    go prompts.Watch(cfg.Prompt.ReloadInterval, promptStop, func(err error) {
+        log.Warn().Err(err).Str("dir", cfg.Prompt.Dir).Msg("prompt template reload failed")
+    })
+
+    // Document passwords (encrypted, short TTL; jobs only carry a reference)
+    vault, ephemeral, err := secrets.Open(cfg.Queue.RedisURL, cfg.Secrets.Key, cfg.Secrets.Dev, cfg.Secrets.TTL)
+    if err != nil {
+        log.Fatal().Err(err).Msg("failed to init secret store")
+    }
+    if ephemeral {
+        log.Warn().Msg("SECRET_KEY_DEV: using a per-process key (workers in other processes cannot read document passwords)")
+    }
+
+    // API keys; the dashboard proxies use a key that exists only in this process.
+    // Dashboard users are operators, so it may look up any job.
+    authn, err := auth.New(cfg.Auth.Enabled, cfg.Auth.KeysFile)
+    if err != nil {
+        log.Fatal().Err(err).Msg("failed to load API keys")
+    }
+    if !cfg.Auth.Enabled {
+        log.Warn().Msg("API_AUTH=off: public endpoints accept unauthenticated requests")
+    }
+    dashboardKey, err := authn.IssueKey("dashboard", auth.ScopeSubmit, auth.ScopeRead, auth.ScopeAdmin)
+    if err != nil {
+        log.Fatal().Err(err).Msg("failed to issue dashboard API key")
+    }
+
+    // Provider circuit breakers live in Redis; the orchestrator only inspects and resets them
+    breakers, err := limiter.New(limiter.Options{RedisURL: cfg.Queue.RedisURL, BaseBackoff: cfg.Worker.BreakerBaseBackoff, MaxBackoff: cfg.Worker.BreakerMaxBackoff})
+    if err != nil {
+        log.Fatal().Err(err).Msg("failed to init breaker admin")
+    }
+
+    orch := orchestrator.New(orchestrator.Dependencies{
+        Queue:        rq,
+        Status:       orchestrator.NewStatusAdapter(rs),
+        Pages:        ps,
+        Converter:    conv,
+        FileType:     filetype.New(),
+        Prompts:      prompts,
+        Secrets:      vault,
+        Auth:         authn,
+        Breakers:     breakers,
+        Usage:        us,
+        DLQ:          rq,
+        Context:      orchestrator.ContextConfig{CarryOver: cfg.Context.CarryOver, Tokens: cfg.Context.Tokens},
+        ResultFormat: cfg.Result.Format,
+        Selection: orchestrator.SelectionConfig{
+            Mode:         cfg.Selection.Mode,
+            LocalWorkers: cfg.Selection.LocalWorkers,
+            BatchPages:   cfg.Selection.BatchPages,
+            Thresholds: orchestrator.SelectionThresholds{
+                MinTextChars:     cfg.Selection.MinTextChars,
+                MaxGarbageRatio:  cfg.Selection.MaxGarbageRatio,
+                MaxImageCoverage: cfg.Selection.MaxImageCoverage,
+                MaxVectorDensity: cfg.Selection.MaxVectorDensity,
+            },
+        },
+    })
+    // Page outcomes arrive on the results stream, independent of where workers run
+    orch.StartResultConsumers(cfg.Queue.ResultConsumers)
+    orch.RegisterRoutes(mux)
+
+    // Dashboard
+    statusChecker := statuscheck.New(statuscheck.Options{
+        Redis:       rq,
+        S3Bucket:    os.Getenv("AWS_S3_BUCKET"),
+        OpenAIKey:   os.Getenv("OPENAI_API_KEY"),
+        AnthropicKey: os.Getenv("ANTHROPIC_API_KEY"),
+    })
+    dash, err := web.New(web.Options{
+        Status:           statusChecker,
+        RedisURL:         cfg.Queue.RedisURL,
+        UsersFile:        cfg.Web.UsersFile,
+        Username:         cfg.Web.Username,
+        Password:         cfg.Web.Password,
+        SessionTTL:       cfg.Web.SessionTTL,
+        CookieSecure:     cfg.Web.CookieSecure,
+        LoginWindow:      cfg.Web.LoginWindow,
+        LoginMaxAttempts: cfg.Web.LoginMaxAttempts,
+        TrustProxy:       cfg.Web.TrustProxy,
+        APIKey:           dashboardKey,
+    })
+    if err != nil {
+        log.Fatal().Err(err).Msg("failed to init dashboard")
+    }
+    dash.RegisterRoutes(mux)
+
+    return &API{Orchestrator: orch, status: rs, pages: ps, usage: us, conv: conv, vault: vault, breakers: breakers, promptStop: promptStop}
+}
+
+// Close stops result consumers (waiting for in-flight results) and releases resources.
+func (a *API) Close(ctx context.Context) {
+    if err := a.Orchestrator.Stop(ctx); err != nil {
+        log.Warn().Err(err).Msg("result consumers did not stop in time")
+    }
+    close(a.promptStop)
+    a.conv.Shutdown()
+    _ = a.vault.Close()
+    _ = a.breakers.CloseClient()
+    _ = a.pages.Close()
+    _ = a.usage.Close()
+    _ = a.status.Close()
+}
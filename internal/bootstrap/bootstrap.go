@@ -0,0 +1,141 @@
+package bootstrap
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+
+    "github.com/rs/zerolog/log"
+
+    cfgpkg "github.com/local/aidispatcher/internal/config"
+    logpkg "github.com/local/aidispatcher/internal/logger"
+    mpkg "github.com/local/aidispatcher/internal/metrics"
+    "github.com/local/aidispatcher/internal/queue"
+    "github.com/local/aidispatcher/internal/secrets"
+)
+
+// InitLogging configures the global logger from cfg. The returned func flushes and closes it.
+func InitLogging(cfg cfgpkg.Config) func() {
+    _ = logpkg.Init(logpkg.Options{
+        Level: cfg.Logging.Level,
+        Pretty: cfg.Logging.Pretty,
+        File: cfg.Logging.File,
+        MaxSizeMB: cfg.Logging.MaxSizeMB,
+        MaxBackups: cfg.Logging.MaxBackups,
+        MaxAgeDays: cfg.Logging.MaxAgeDays,
+        Compress: cfg.Logging.Compress,
+        SendToAxiom: cfg.Axiom.Send && cfg.Axiom.APIKey != "",
+        AxiomAPIKey: cfg.Axiom.APIKey,
+        AxiomOrgID: cfg.Axiom.OrgID,
+        AxiomDataset: cfg.Axiom.Dataset,
+        AxiomFlush: cfg.Axiom.FlushInterval,
+    })
+    return logpkg.Close
+}
+
+// CheckConfig exits when cfg has settings that would only fail once in use.
+func CheckConfig(cfg cfgpkg.Config) {
+    if err := cfg.Validate(); err != nil { log.Fatal().Err(err).Msg("invalid configuration") }
+    // every process encrypts or decrypts document passwords with the same key
+    if _, _, err := secrets.ParseKey(cfg.Secrets.Key, cfg.Secrets.Dev); err != nil { log.Fatal().Err(err).Msg("invalid configuration") }
+}
+
+// OpenQueue connects to the Redis queue and starts the PEL reclaimer. Exits on failure.
+func OpenQueue(cfg cfgpkg.Config) *queue.RedisQueue {
+    rq, err := queue.NewRedisQueue(cfg.Queue.RedisURL, cfg.Queue.Stream, cfg.Queue.Group, cfg.Queue.PollInterval)
+    if err != nil {
+        log.Fatal().Err(err).Msg("failed to connect to redis")
+    }
+    // Recover entries left pending by crashed workers; safe to run in every process
+    rq.StartReclaimer(queue.ReclaimOptions{
+        Interval:         cfg.Queue.ReclaimInterval,
+        MinIdle:          cfg.Queue.ClaimMinIdle,
+        MaxDeliveries:    int64(cfg.Queue.MaxDeliveries),
+        DeadConsumerIdle: cfg.Queue.DeadConsumerIdle,
+    })
+    return rq
+}
+
+// RegisterHealth adds /healthz (Redis ping + queue depths) and /metrics to mux.
+// role identifies the binary in the health response.
+func RegisterHealth(mux *http.ServeMux, rq *queue.RedisQueue, role string) {
+    mpkg.Init()
+    mux.Handle("/metrics", mpkg.Handler())
+    mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request){
+        ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
+        defer cancel()
+        type resp struct {
+            OK    bool   `json:"ok"`
+            Role  string `json:"role"`
+            Redis string `json:"redis"`
+            queue.Depths
+        }
+        w.Header().Set("Content-Type", "application/json")
+        if err := rq.Ping(ctx); err != nil {
+            w.WriteHeader(http.StatusServiceUnavailable)
+            _ = json.NewEncoder(w).Encode(resp{Role: role, Redis: "down"})
+            return
+        }
+        depths, err := rq.Depths(ctx)
+        if err != nil {
+            w.WriteHeader(http.StatusServiceUnavailable)
+            _ = json.NewEncoder(w).Encode(resp{Role: role, Redis: "error_depths"})
+            return
+        }
+        _ = json.NewEncoder(w).Encode(resp{OK: true, Role: role, Redis: "ok", Depths: depths})
+    })
+}
+
+// SampleDepths publishes queue depths to Prometheus every interval until stop is closed.
+func SampleDepths(rq *queue.RedisQueue, interval time.Duration, stop <-chan struct{}) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-stop:
+            return
+        case <-ticker.C:
+        }
+        ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+        depths, err := rq.Depths(ctx)
+        cancel()
+        if err == nil {
+            mpkg.SetQueueDepth("stream", depths.Stream)
+            mpkg.SetQueueDepth("delayed", depths.Delayed)
+            mpkg.SetQueueDepth("dlq", depths.DLQ)
+            mpkg.SetQueueDepth("pending", depths.Pending)
+            mpkg.SetQueueDepth("results", depths.Results)
+            mpkg.SetQueueDepth("results_pending", depths.ResultsPending)
+        }
+    }
+}
+
+// Serve starts srv in the background; a listen failure is fatal.
+func Serve(srv *http.Server) {
+    go func(){
+        log.Info().Msgf("HTTP server listening on %s", srv.Addr)
+        if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            log.Fatal().Err(err).Msg("http server error")
+        }
+    }()
+}
+
+// WaitForSignal blocks until SIGINT/SIGTERM and returns a context bounded by timeout
+// for the shutdown sequence.
+func WaitForSignal(timeout time.Duration) (context.Context, context.CancelFunc) {
+    stop := make(chan os.Signal, 1)
+    signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+    sig := <-stop
+    log.Info().Str("signal", sig.String()).Msg("shutting down")
+    return context.WithTimeout(context.Background(), timeout)
+}
+
+// Port returns $name or def.
+func Port(name, def string) string {
+    if p := os.Getenv(name); p != "" { return p }
+    return def
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+    "fmt"
     "os"
     "strconv"
     "strings"
@@ -34,20 +35,18 @@ type ProviderModels struct {
     Fast      string
 }
 
-// ProvidersConfig defines engines and models per provider.
+// ProvidersConfig declares the providers and the fallback chains that walk them.
 type ProvidersConfig struct {
-    PrimaryEngine   string // "openai"|"anthropic"
-    SecondaryEngine string // "anthropic"|"openai"
-    OpenAI          ProviderModels
-    Anthropic       ProviderModels
+    PrimaryEngine   string // first provider of the default chains
+    SecondaryEngine string // provider the default chains fail over to
+    List            []ProviderConfig // AI_PROVIDERS, in declaration order
+    Chains          map[string]Chain // "default", "fast" and AI_CHAINS entries
 }
 
 // WorkerConfig defines worker behavior and limits.
 type WorkerConfig struct {
     Concurrency          int
     RequestTimeout       time.Duration
-    OpenAITimeout        time.Duration
-    AnthropicTimeout     time.Duration
     PageTotalTimeout     time.Duration
     JobMaxAttempts       int
     RetryBaseDelay       time.Duration
@@ -56,6 +55,12 @@ type WorkerConfig struct {
     MaxInflightPerModel  int
     BreakerBaseBackoff   time.Duration
     BreakerMaxBackoff    time.Duration
+    BreakerFailures      int           // consecutive failures that open a breaker
+    BreakerFailureRate   float64       // failure share over BreakerWindow that opens it
+    BreakerMinSamples    int           // calls in BreakerWindow before the rate applies
+    BreakerWindow        time.Duration
+    BreakerProbeTimeout  time.Duration // half-open probe permit lifetime
+    ShutdownTimeout      time.Duration // graceful shutdown budget for in-flight pages/results
 }
 
 // QueueConfig defines queue connectivity and names.
@@ -64,8 +69,116 @@ type QueueConfig struct {
     Stream       string
     Group        string
     PollInterval time.Duration
+    // PEL reclaimer
+    ReclaimInterval  time.Duration
+    ClaimMinIdle     time.Duration // should exceed PAGE_TOTAL_TIMEOUT
+    MaxDeliveries    int
+    DeadConsumerIdle time.Duration
+    // orchestrator goroutines consuming the results stream
+    ResultConsumers  int
 }
 
+// RenderConfig controls how pages are rasterized before being sent to vision models.
+type RenderConfig struct {
+    DPI         int
+    Format      string // "png"|"jpeg"
+    MaxPixels   int    // longest side in pixels; DPI is lowered to fit
+    JPEGQuality int
+}
+
+// PromptConfig controls the prompt template library.
+type PromptConfig struct {
+    Dir             string        // directory with "<name>.v<N>.tmpl" overrides
+    DefaultTemplate string
+    ReloadInterval  time.Duration // 0 disables reloading
+}
+
+// SecretsConfig controls the vault holding per-job secrets (document passwords).
+type SecretsConfig struct {
+    Key string        // server key; 32 bytes hex/base64 or a passphrase (hashed)
+    Dev bool          // allow an empty Key: random per-process key, single-process setups only
+    TTL time.Duration // secrets expire even if a job never completes
+}
+
+// WebConfig controls dashboard authentication.
+type WebConfig struct {
+    UsersFile        string // "username:bcrypt-hash" per line; overrides Username/Password
+    Username         string
+    Password         string
+    SessionTTL       time.Duration
+    CookieSecure     bool
+    LoginWindow      time.Duration
+    LoginMaxAttempts int
+    TrustProxy       bool // use X-Forwarded-For for login rate limiting
+}
+
+// AuthConfig controls API key authentication of the public endpoints.
+type AuthConfig struct {
+    Enabled  bool   // API_AUTH=off disables key checks (local development only)
+    KeysFile string // "client_id:sha256:scopes" per line
+}
+
+// SelectionConfig controls which pages go to AI and which are read from the text layer.
+type SelectionConfig struct {
+    Mode             string // auto|ai|mupdf
+    MinTextChars     int
+    MaxGarbageRatio  float64
+    MaxImageCoverage float64
+    MaxVectorDensity float64 // drawn paths per A4-sized page
+    LocalWorkers     int     // documents extracted concurrently by the orchestrator's MuPDF pool
+    BatchPages       int     // consecutive AI pages sent in one provider request (1 = no batching)
+}
+
+// QualityConfig controls the check of AI answers against the page's text layer.
+type QualityConfig struct {
+    Enabled       bool
+    MinSimilarity float64 // share of the text layer's character trigrams the answer must contain
+    MinTextChars  int     // text layers shorter than this are not compared
+}
+
+// ContextConfig controls cross-page context: the end of the previous page in each page's
+// prompt, and stitching of page breaks in the aggregated text.
+type ContextConfig struct {
+    CarryOver bool // default for requests that do not set carry_context
+    Tokens    int  // budget for the previous page's tail
+}
+
+// ResultConfig controls what finished jobs store.
+type ResultConfig struct {
+    Format string // default result_format: text|json|both
+}
+
+// RateBudget is a per-minute request/token allowance; 0 = unlimited.
+type RateBudget struct {
+    RPM int
+    TPM int
+}
+
+// RateLimitConfig controls the token buckets shared by all replicas (Redis rl:* keys).
+type RateLimitConfig struct {
+    Default     RateBudget
+    Models      map[string]RateBudget // "provider:model" -> budget (RATE_LIMITS)
+    MaxWait     time.Duration         // longer waits fail over to the next model instead
+    TokenMargin float64               // added to token estimates, e.g. 0.15 = +15%
+}
+
+// ModelPrice is a model's list price in USD per million tokens.
+type ModelPrice struct {
+    InputPerM  float64
+    OutputPerM float64
+}
+
+// Cost is the USD price of a call that used the given tokens.
+func (p ModelPrice) Cost(tokensIn, tokensOut int) float64 {
+    return (float64(tokensIn)*p.InputPerM + float64(tokensOut)*p.OutputPerM) / 1e6
+}
+
+// defaultModelPrices are list prices of the default models (USD per 1M input/output tokens).
+const defaultModelPrices = "openai:gpt-4.1=2/8,openai:gpt-4o=2.5/10,openai:gpt-4.1-mini=0.4/1.6," +
+    "anthropic:claude-3-5-sonnet=3/15,anthropic:claude-3-opus=15/75,anthropic:claude-3-haiku=0.25/1.25," +
+    "gemini:gemini-2.5-pro=1.25/10,gemini:gemini-2.5-flash=0.3/2.5,gemini:gemini-2.5-flash-lite=0.1/0.4," +
+    "bedrock:anthropic.claude-3-5-sonnet-20240620-v1:0=3/15,bedrock:anthropic.claude-3-opus-20240229-v1:0=15/75,bedrock:anthropic.claude-3-haiku-20240307-v1:0=0.25/1.25"
+
 // Config is the top-level configuration.
 type Config struct {
     Logging   LoggingConfig
@@ -73,6 +186,22 @@ type Config struct {
     Providers ProvidersConfig
     Worker    WorkerConfig
     Queue     QueueConfig
+    Render    RenderConfig
+    Prompt    PromptConfig
+    Secrets   SecretsConfig
+    Web       WebConfig
+    Auth      AuthConfig
+    Selection SelectionConfig
+    Quality   QualityConfig
+    Context   ContextConfig
+    Result    ResultConfig
+    RateLimit RateLimitConfig
+    Prices    map[string]ModelPrice // "provider:model" -> price (MODEL_PRICES)
+}
+
+// Price returns the configured price of provider:model; unknown models cost 0.
+func (c Config) Price(provider, model string) ModelPrice {
+    return c.Prices[strings.ToLower(provider)+":"+strings.ToLower(model)]
 }
 
 // FromEnv loads configuration from environment with sensible defaults.
@@ -100,28 +229,10 @@ func FromEnv() Config {
         FlushInterval: parseDuration(getEnv("AXIOM_FLUSH_INTERVAL", "10s"), 10*time.Second),
     }
 
-    // Providers defaults
-    cfg.Providers = ProvidersConfig{
-        PrimaryEngine:   getEnv("PRIMARY_ENGINE", "openai"),
-        SecondaryEngine: getEnv("SECONDARY_ENGINE", "anthropic"),
-        OpenAI: ProviderModels{
-            Primary:   getEnv("OPENAI_PRIMARY_MODEL", "gpt-4.1"),
-            Secondary: getEnv("OPENAI_SECONDARY_MODEL", "gpt-4o"),
-            Fast:      getEnv("OPENAI_FAST_MODEL", "gpt-4.1-mini"),
-        },
-        Anthropic: ProviderModels{
-            Primary:   getEnv("ANTHROPIC_PRIMARY_MODEL", "claude-3-5-sonnet"),
-            Secondary: getEnv("ANTHROPIC_SECONDARY_MODEL", "claude-3-opus"),
-            Fast:      getEnv("ANTHROPIC_FAST_MODEL", "claude-3-haiku"),
-        },
-    }
-
     // Worker defaults
     cfg.Worker = WorkerConfig{
         Concurrency:        parseInt(getEnv("WORKER_CONCURRENCY", "8"), 8),
         RequestTimeout:     parseDuration(getEnv("REQUEST_TIMEOUT", "60s"), 60*time.Second),
-        OpenAITimeout:      parseDuration(getEnv("OPENAI_TIMEOUT", ""), 0),
-        AnthropicTimeout:   parseDuration(getEnv("ANTHROPIC_TIMEOUT", ""), 0),
         PageTotalTimeout:   parseDuration(getEnv("PAGE_TOTAL_TIMEOUT", "120s"), 120*time.Second),
         JobMaxAttempts:     parseInt(getEnv("JOB_MAX_ATTEMPTS", "3"), 3),
         RetryBaseDelay:     parseDuration(getEnv("RETRY_BASE_DELAY", "2s"), 2*time.Second),
@@ -130,9 +241,21 @@ func FromEnv() Config {
         MaxInflightPerModel: parseInt(getEnv("MAX_INFLIGHT_PER_MODEL", "2"), 2),
         BreakerBaseBackoff:  parseDuration(getEnv("BREAKER_BASE_BACKOFF", "30s"), 30*time.Second),
         BreakerMaxBackoff:   parseDuration(getEnv("BREAKER_MAX_BACKOFF", "5m"), 5*time.Minute),
+        BreakerFailures:     parseInt(getEnv("BREAKER_FAILURES", "5"), 5),
+        BreakerFailureRate:  parseFloat(getEnv("BREAKER_FAILURE_RATE", "0.5"), 0.5),
+        BreakerMinSamples:   parseInt(getEnv("BREAKER_MIN_SAMPLES", "10"), 10),
+        BreakerWindow:       parseDuration(getEnv("BREAKER_WINDOW", "60s"), 60*time.Second),
+        BreakerProbeTimeout: parseDuration(getEnv("BREAKER_PROBE_TIMEOUT", "2m"), 2*time.Minute),
+        ShutdownTimeout:     parseDuration(getEnv("SHUTDOWN_TIMEOUT", "30s"), 30*time.Second),
+    }
+
+    // Providers and fallback chains (provider timeouts default to REQUEST_TIMEOUT)
+    cfg.Providers = ProvidersConfig{
+        PrimaryEngine:   strings.ToLower(getEnv("PRIMARY_ENGINE", "openai")),
+        SecondaryEngine: strings.ToLower(getEnv("SECONDARY_ENGINE", "anthropic")),
     }
-    if cfg.Worker.OpenAITimeout <= 0 { cfg.Worker.OpenAITimeout = cfg.Worker.RequestTimeout }
-    if cfg.Worker.AnthropicTimeout <= 0 { cfg.Worker.AnthropicTimeout = cfg.Worker.RequestTimeout }
+    cfg.Providers.List = loadProviders(cfg.Providers.PrimaryEngine, cfg.Providers.SecondaryEngine, cfg.Worker.RequestTimeout)
+    cfg.Providers.Chains = loadChains(cfg.Providers, getEnv("AI_CHAINS", ""))
 
     // Queue defaults
     cfg.Queue = QueueConfig{
@@ -140,11 +263,125 @@ func FromEnv() Config {
         Stream:       getEnv("QUEUE_STREAM", "jobs:ai:pages"),
         Group:        getEnv("QUEUE_GROUP", "workers:images"),
         PollInterval: parseDuration(getEnv("QUEUE_POLL_INTERVAL", "100ms"), 100*time.Millisecond),
+        ReclaimInterval:  parseDuration(getEnv("QUEUE_RECLAIM_INTERVAL", "30s"), 30*time.Second),
+        ClaimMinIdle:     parseDuration(getEnv("QUEUE_CLAIM_MIN_IDLE", "5m"), 5*time.Minute),
+        MaxDeliveries:    parseInt(getEnv("QUEUE_MAX_DELIVERIES", "5"), 5),
+        DeadConsumerIdle: parseDuration(getEnv("QUEUE_DEAD_CONSUMER_IDLE", "1h"), time.Hour),
+        ResultConsumers:  parseInt(getEnv("RESULT_CONSUMERS", "4"), 4),
+    }
+
+    // Page rendering defaults
+    cfg.Render = RenderConfig{
+        DPI:         parseInt(getEnv("RENDER_DPI", "150"), 150),
+        Format:      strings.ToLower(getEnv("RENDER_FORMAT", "png")),
+        MaxPixels:   parseInt(getEnv("RENDER_MAX_PIXELS", "2000"), 2000),
+        JPEGQuality: parseInt(getEnv("RENDER_JPEG_QUALITY", "85"), 85),
+    }
+    if cfg.Render.Format == "jpg" { cfg.Render.Format = "jpeg" }
+
+    // Prompt template defaults
+    cfg.Prompt = PromptConfig{
+        Dir:             getEnv("PROMPT_DIR", "prompts"),
+        DefaultTemplate: getEnv("PROMPT_DEFAULT_TEMPLATE", "ocr"),
+        ReloadInterval:  parseDuration(getEnv("PROMPT_RELOAD_INTERVAL", "30s"), 30*time.Second),
+    }
+
+    // Secret vault defaults
+    cfg.Secrets = SecretsConfig{
+        Key: getEnv("SECRET_KEY", ""),
+        Dev: parseBool(getEnv("SECRET_KEY_DEV", "0")),
+        TTL: parseDuration(getEnv("SECRET_TTL", "6h"), 6*time.Hour),
+    }
+
+    // Dashboard auth defaults
+    cfg.Web = WebConfig{
+        UsersFile:        getEnv("WEB_USERS_FILE", ""),
+        Username:         getEnv("WEB_USERNAME", ""),
+        Password:         getEnv("WEB_PASSWORD", ""),
+        SessionTTL:       parseDuration(getEnv("WEB_SESSION_TTL", "12h"), 12*time.Hour),
+        CookieSecure:     parseBool(getEnv("WEB_COOKIE_SECURE", "0")),
+        LoginWindow:      parseDuration(getEnv("WEB_LOGIN_WINDOW", "15m"), 15*time.Minute),
+        LoginMaxAttempts: parseInt(getEnv("WEB_LOGIN_MAX_ATTEMPTS", "5"), 5),
+        TrustProxy:       parseBool(getEnv("WEB_TRUST_PROXY", "0")),
+    }
+
+    // API auth defaults
+    cfg.Auth = AuthConfig{
+        Enabled:  getEnv("API_AUTH", "on") != "off",
+        KeysFile: getEnv("API_KEYS_FILE", "config/api_keys"),
+    }
+
+    // Page selection defaults
+    cfg.Selection = SelectionConfig{
+        Mode:             strings.ToLower(getEnv("SELECTION_MODE", "auto")),
+        MinTextChars:     parseInt(getEnv("SELECTION_MIN_TEXT_CHARS", "200"), 200),
+        MaxGarbageRatio:  parseFloat(getEnv("SELECTION_MAX_GARBAGE_RATIO", "0.05"), 0.05),
+        MaxImageCoverage: parseFloat(getEnv("SELECTION_MAX_IMAGE_COVERAGE", "0.3"), 0.3),
+        MaxVectorDensity: parseFloat(getEnv("SELECTION_MAX_VECTOR_DENSITY", "300"), 300),
+        LocalWorkers:     parseInt(getEnv("MUPDF_WORKERS", "2"), 2),
+        BatchPages:       parseInt(getEnv("AI_BATCH_PAGES", "1"), 1),
+    }
+
+    // Quality guard defaults
+    cfg.Quality = QualityConfig{
+        Enabled:       getEnv("QUALITY_GUARD", "on") != "off",
+        MinSimilarity: parseFloat(getEnv("QUALITY_MIN_SIMILARITY", "0.5"), 0.5),
+        MinTextChars:  parseInt(getEnv("QUALITY_MIN_TEXT_CHARS", "200"), 200),
+    }
+
+    // Cross-page context defaults
+    cfg.Context = ContextConfig{
+        CarryOver: parseBool(getEnv("CONTEXT_CARRY", "off")),
+        Tokens:    parseInt(getEnv("CONTEXT_CARRY_TOKENS", "200"), 200),
     }
 
+    cfg.Result = ResultConfig{Format: strings.ToLower(getEnv("RESULT_FORMAT", "text"))}
+
+    // Shared rate budgets
+    cfg.RateLimit = RateLimitConfig{
+        Default:     RateBudget{RPM: parseInt(getEnv("RATE_LIMIT_DEFAULT_RPM", "0"), 0), TPM: parseInt(getEnv("RATE_LIMIT_DEFAULT_TPM", "0"), 0)},
+        Models:      parseRateBudgets(getEnv("RATE_LIMITS", "")),
+        MaxWait:     parseDuration(getEnv("RATE_LIMIT_MAX_WAIT", "3s"), 3*time.Second),
+        TokenMargin: parseFloat(getEnv("RATE_LIMIT_TOKEN_MARGIN", "0.15"), 0.15),
+    }
+
+    // Model prices for usage/cost accounting
+    cfg.Prices = parseModelPrices(getEnv("MODEL_PRICES", defaultModelPrices))
+
     return cfg
 }
 
+// Validate reports settings that would otherwise only fail at first use, e.g. on every
+// rendered page.
+func (c Config) Validate() error {
+    switch c.Render.Format {
+    case "png", "jpeg":
+    default:
+        return fmt.Errorf("RENDER_FORMAT %q: must be png or jpeg", c.Render.Format)
+    }
+    if w := c.PageRetryWindow(); c.Secrets.TTL < w {
+        return fmt.Errorf("SECRET_TTL %s is shorter than the page retry window %s: retried pages of password-protected documents would fail", c.Secrets.TTL, w)
+    }
+    return nil
+}
+
+// retryDelayCap is the longest delay the dispatcher waits before re-enqueueing a page.
+const retryDelayCap = 5 * time.Minute
+
+// PageRetryWindow is how long a page can stay in flight in the worst case: every attempt
+// redelivered up to QUEUE_MAX_DELIVERIES times after QUEUE_CLAIM_MIN_IDLE plus
+// PAGE_TOTAL_TIMEOUT, followed by the longest retry or breaker backoff.
+func (c Config) PageRetryWindow() time.Duration {
+    backoff := retryDelayCap
+    if c.Worker.BreakerMaxBackoff > backoff { backoff = c.Worker.BreakerMaxBackoff }
+    deliveries := c.Queue.MaxDeliveries
+    if deliveries < 1 { deliveries = 1 }
+    attempts := c.Worker.JobMaxAttempts
+    if attempts < 1 { attempts = 1 }
+    perAttempt := time.Duration(deliveries)*(c.Queue.ClaimMinIdle+c.Worker.PageTotalTimeout) + backoff
+    return time.Duration(attempts) * perAttempt
+}
+
 // Helpers
 func getEnv(key, def string) string {
     if v := os.Getenv(key); v != "" {
@@ -181,3 +418,30 @@ func devDefaultPretty() string {
     if env == "dev" || env == "development" || env == "local" { return "true" }
     return "false"
 }
+
+// parseRateBudgets parses "provider:model=rpm/tpm" entries separated by commas, e.g.
+// "openai:gpt-4.1=500/30000,anthropic:claude-3-5-sonnet=50/40000". Malformed entries are skipped.
+func parseRateBudgets(s string) map[string]RateBudget {
+    out := map[string]RateBudget{}
+    for _, entry := range strings.Split(s, ",") {
+        name, limits, ok := strings.Cut(strings.TrimSpace(entry), "=")
+        if !ok || !strings.Contains(name, ":") { continue }
+        rpm, tpm, _ := strings.Cut(limits, "/")
+        out[strings.ToLower(strings.TrimSpace(name))] = RateBudget{RPM: parseInt(strings.TrimSpace(rpm), 0), TPM: parseInt(strings.TrimSpace(tpm), 0)}
+    }
+    return out
+}
+
+// parseModelPrices parses "provider:model=input/output" entries (USD per 1M tokens)
+// separated by commas, e.g. "openai:gpt-4.1=2/8". Malformed entries are skipped.
+func parseModelPrices(s string) map[string]ModelPrice {
+    out := map[string]ModelPrice{}
+    for _, entry := range strings.Split(s, ",") {
+        name, prices, ok := strings.Cut(strings.TrimSpace(entry), "=")
+        if !ok || !strings.Contains(name, ":") { continue }
+        in, outp, ok := strings.Cut(prices, "/")
+        if !ok { continue }
+        out[strings.ToLower(strings.TrimSpace(name))] = ModelPrice{InputPerM: parseFloat(strings.TrimSpace(in), 0), OutputPerM: parseFloat(strings.TrimSpace(outp), 0)}
+    }
+    return out
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+    "os"
+    "strings"
+    "time"
+)
+
+// ProviderConfig declares one provider (AI_PROVIDERS). Its settings come from {NAME}_*
+// variables: {NAME}_KIND, {NAME}_PRIMARY_MODEL, {NAME}_SECONDARY_MODEL, {NAME}_FAST_MODEL,
+// {NAME}_TIMEOUT. All {NAME}_* variables are also passed to the client as options.
+type ProviderConfig struct {
+    Name    string            // used in chains, limiter keys, prices and metrics
+    Kind    string            // client implementation registered in internal/ai; defaults to Name
+    Models  ProviderModels
+    Timeout time.Duration     // per-request timeout; REQUEST_TIMEOUT when unset
+    Options map[string]string // known {NAME}_* variables without the prefix, e.g. API_KEY (providerOptionKeys)
+}
+
+// Conditions under which a chain step is tried after the previous attempt failed.
+// Steps whose model was skipped (breaker open, no free slot) never block the next step.
+const (
+    OnAny         = "any"          // any error, including requests rejected as invalid
+    OnRetryable   = "retryable"    // 429, 5xx, timeouts, network errors and blocked answers (default)
+    OnRateLimited = "rate_limited" // 429 or exhausted rate budget
+    OnTransient   = "transient"    // 5xx, timeouts and network errors
+    OnTimeout     = "timeout"      // the previous call timed out
+)
+
+// ChainStep is one provider:model attempt of a fallback chain.
+type ChainStep struct {
+    Provider string
+    Model    string
+    On       string
+}
+
+// Chain is an ordered list of attempts for a page.
+type Chain []ChainStep
+
+// engineAliases maps legacy ai_engine values to provider names.
+var engineAliases = map[string]string{
+    "juniorengine": "openai",
+    "openaiengine": "openai",
+    "claudeengine": "anthropic",
+}
+
+// builtinModels are default models per client kind.
+var builtinModels = map[string]ProviderModels{
+    "openai":    {Primary: "gpt-4.1", Secondary: "gpt-4o", Fast: "gpt-4.1-mini"},
+    "anthropic": {Primary: "claude-3-5-sonnet", Secondary: "claude-3-opus", Fast: "claude-3-haiku"},
+    "gemini":    {Primary: "gemini-2.5-pro", Secondary: "gemini-2.5-flash", Fast: "gemini-2.5-flash-lite"},
+    "bedrock": {
+        Primary:   "anthropic.claude-3-5-sonnet-20240620-v1:0",
+        Secondary: "anthropic.claude-3-opus-20240229-v1:0",
+        Fast:      "anthropic.claude-3-haiku-20240307-v1:0",
+    },
+    "tesseract": {Primary: "ocr", Fast: "ocr"},
+}
+
+// lastResortKinds are appended to the built-in chains whenever a provider of that kind is
+// declared: local OCR is worse than any vision model but better than MuPDF text.
+var lastResortKinds = map[string]bool{"tesseract": true}
+
+// Provider returns the declaration of provider name.
+func (p ProvidersConfig) Provider(name string) (ProviderConfig, bool) {
+    for _, pc := range p.List {
+        if pc.Name == name { return pc, true }
+    }
+    return ProviderConfig{}, false
+}
+
+// ChainFor picks the chain for a job's ai_engine: a chain named after the engine
+// ("{engine}-fast" for fast jobs), else the engine's provider followed by the other
+// providers of the default chain, else the default (or "fast") chain.
+func (p ProvidersConfig) ChainFor(engine string, fast bool) (string, Chain) {
+    base := "default"
+    if fast { base = "fast" }
+    name := strings.ToLower(strings.TrimSpace(engine))
+    if alias, ok := engineAliases[name]; ok { name = alias }
+    if name == "" || name == "default" { return base, p.Chains[base] }
+    key := name
+    if fast { key = name + "-fast" }
+    if c, ok := p.Chains[key]; ok { return key, c }
+    pc, ok := p.Provider(name)
+    if !ok { return base, p.Chains[base] }
+    var c Chain
+    if fast {
+        c = appendStep(c, pc, pc.Models.Fast, OnAny)
+    } else {
+        c = appendStep(c, pc, pc.Models.Primary, OnAny)
+        c = appendStep(c, pc, pc.Models.Secondary, OnRetryable)
+    }
+    // the other providers validate requests differently, so they get a chance after any error
+    first := true
+    for _, s := range p.Chains[base] {
+        if s.Provider == name { continue }
+        if first { s.On = OnAny; first = false }
+        c = append(c, s)
+    }
+    return key, c
+}
+
+func appendStep(c Chain, pc ProviderConfig, model, on string) Chain {
+    if model == "" { return c }
+    return append(c, ChainStep{Provider: pc.Name, Model: model, On: on})
+}
+
+// providerOptionKeys are the {NAME}_* settings provider kinds read (see ai.RegisterKind).
+var providerOptionKeys = []string{"API_KEY", "BASE_URL", "ENDPOINT", "HEADERS", "IMAGE_DETAIL", "MAX_TOKENS", "REGION",
+    "BINARY", "LANGS", "PSM", "MAX_WORKERS"}
+
+// loadProviders reads AI_PROVIDERS (default: PRIMARY_ENGINE and SECONDARY_ENGINE) and
+// their {NAME}_* settings.
+func loadProviders(primary, secondary string, requestTimeout time.Duration) []ProviderConfig {
+    names := splitList(getEnv("AI_PROVIDERS", primary+","+secondary))
+    var out []ProviderConfig
+    seen := map[string]bool{}
+    for _, name := range names {
+        name = strings.ToLower(name)
+        if seen[name] { continue }
+        seen[name] = true
+        prefix := strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+        kind := strings.ToLower(getEnv(prefix+"KIND", name))
+        def := builtinModels[kind]
+        pc := ProviderConfig{
+            Name: name,
+            Kind: kind,
+            Models: ProviderModels{
+                Primary:   getEnv(prefix+"PRIMARY_MODEL", def.Primary),
+                Secondary: getEnv(prefix+"SECONDARY_MODEL", def.Secondary),
+                Fast:      getEnv(prefix+"FAST_MODEL", def.Fast),
+            },
+            Timeout: parseDuration(getEnv(prefix+"TIMEOUT", ""), requestTimeout),
+            Options: map[string]string{},
+        }
+        // exact keys: a prefix match would hand OPENAI_EU_* settings to a provider named openai
+        for _, k := range providerOptionKeys {
+            if v := os.Getenv(prefix + k); v != "" { pc.Options[k] = v }
+        }
+        out = append(out, pc)
+    }
+    return out
+}
+
+// loadChains builds the default chains from PRIMARY_ENGINE/SECONDARY_ENGINE (plus any
+// declared last-resort provider such as tesseract) and overlays
+// AI_CHAINS: "name=step,step;name=step,...", where a step is "provider:model[@on]" and
+// model may be a literal name or primary|secondary|fast. The first step is always tried;
+// the others default to @retryable.
+func loadChains(p ProvidersConfig, spec string) map[string]Chain {
+    chains := map[string]Chain{}
+    prim, _ := p.Provider(strings.ToLower(p.PrimaryEngine))
+    sec, _ := p.Provider(strings.ToLower(p.SecondaryEngine))
+    // primary provider's models, then the secondary provider even after a fatal error
+    var def, fast Chain
+    def = appendStep(def, prim, prim.Models.Primary, OnAny)
+    def = appendStep(def, prim, prim.Models.Secondary, OnRetryable)
+    if sec.Name != prim.Name {
+        def = appendStep(def, sec, sec.Models.Primary, OnAny)
+        def = appendStep(def, sec, sec.Models.Secondary, OnRetryable)
+    }
+    fast = appendStep(fast, prim, prim.Models.Fast, OnAny)
+    if sec.Name != prim.Name { fast = appendStep(fast, sec, sec.Models.Fast, OnAny) }
+    for _, pc := range p.List {
+        if !lastResortKinds[pc.Kind] || pc.Name == prim.Name || pc.Name == sec.Name { continue }
+        def = appendStep(def, pc, pc.Models.Primary, OnAny)
+        fast = appendStep(fast, pc, pc.Models.Fast, OnAny)
+    }
+    chains["default"], chains["fast"] = def, fast
+
+    for _, entry := range strings.Split(spec, ";") {
+        name, steps, ok := strings.Cut(strings.TrimSpace(entry), "=")
+        name = strings.ToLower(strings.TrimSpace(name))
+        if !ok || name == "" { continue }
+        var c Chain
+        for _, step := range splitList(steps) {
+            ref, on, _ := strings.Cut(step, "@")
+            provider, model, ok := strings.Cut(ref, ":")
+            pc, known := p.Provider(strings.ToLower(strings.TrimSpace(provider)))
+            if !ok || !known { continue }
+            switch model = strings.TrimSpace(model); model {
+            case "primary":
+                model = pc.Models.Primary
+            case "secondary":
+                model = pc.Models.Secondary
+            case "fast":
+                model = pc.Models.Fast
+            }
+            c = appendStep(c, pc, model, parseOn(on))
+        }
+        if len(c) > 0 { chains[name] = c }
+    }
+    return chains
+}
+
+func parseOn(s string) string {
+    switch s = strings.ToLower(strings.TrimSpace(s)); s {
+    case OnAny, OnRateLimited, OnTransient, OnTimeout:
+        return s
+    case "429":
+        return OnRateLimited
+    default:
+        return OnRetryable
+    }
+}
+
+func splitList(s string) []string {
+    var out []string
+    for _, v := range strings.Split(s, ",") {
+        if v = strings.TrimSpace(v); v != "" { out = append(out, v) }
+    }
+    return out
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+    "reflect"
+    "testing"
+    "time"
+)
+
+func testProvider(name, kind string) ProviderConfig {
+    return ProviderConfig{Name: name, Kind: kind, Models: builtinModels[kind]}
+}
+
+func testProviders(primary, secondary string, list ...ProviderConfig) ProvidersConfig {
+    return ProvidersConfig{PrimaryEngine: primary, SecondaryEngine: secondary, List: list}
+}
+
+func TestLoadChains(t *testing.T) {
+    openai, anthropic, tess := testProvider("openai", "openai"), testProvider("anthropic", "anthropic"), testProvider("ocr", "tesseract")
+    tests := []struct {
+        name   string
+        p      ProvidersConfig
+        spec   string
+        want   map[string]Chain // only the chains listed are compared
+        absent []string
+    }{
+        {
+            name: "built-in chains",
+            p:    testProviders("openai", "anthropic", openai, anthropic),
+            want: map[string]Chain{
+                "default": {{"openai", "gpt-4.1", OnAny}, {"openai", "gpt-4o", OnRetryable},
+                    {"anthropic", "claude-3-5-sonnet", OnAny}, {"anthropic", "claude-3-opus", OnRetryable}},
+                "fast": {{"openai", "gpt-4.1-mini", OnAny}, {"anthropic", "claude-3-haiku", OnAny}},
+            },
+        },
+        {
+            name: "same engine twice",
+            p:    testProviders("openai", "openai", openai),
+            want: map[string]Chain{
+                "default": {{"openai", "gpt-4.1", OnAny}, {"openai", "gpt-4o", OnRetryable}},
+                "fast":    {{"openai", "gpt-4.1-mini", OnAny}},
+            },
+        },
+        {
+            name: "last resort provider is appended",
+            p:    testProviders("openai", "anthropic", openai, anthropic, tess),
+            want: map[string]Chain{
+                "fast": {{"openai", "gpt-4.1-mini", OnAny}, {"anthropic", "claude-3-haiku", OnAny}, {"ocr", "ocr", OnAny}},
+            },
+        },
+        {
+            name: "AI_CHAINS entries, aliases and conditions",
+            p:    testProviders("openai", "anthropic", openai, anthropic),
+            spec: "premium=anthropic:secondary,openai:primary@any;cheap=openai:fast,anthropic:claude-3-haiku@429,openai:gpt-4o@bogus",
+            want: map[string]Chain{
+                "premium": {{"anthropic", "claude-3-opus", OnRetryable}, {"openai", "gpt-4.1", OnAny}},
+                "cheap":   {{"openai", "gpt-4.1-mini", OnRetryable}, {"anthropic", "claude-3-haiku", OnRateLimited}, {"openai", "gpt-4o", OnRetryable}},
+            },
+        },
+        {
+            name:   "unknown providers and malformed entries are dropped",
+            p:      testProviders("openai", "anthropic", openai, anthropic),
+            spec:   "ghost=gemini:primary;=openai:primary;broken;mixed=gemini:primary,openai:fast@timeout",
+            want:   map[string]Chain{"mixed": {{"openai", "gpt-4.1-mini", OnTimeout}}},
+            absent: []string{"ghost", "", "broken"},
+        },
+        {
+            name: "AI_CHAINS overrides the default chain",
+            p:    testProviders("openai", "anthropic", openai, anthropic),
+            spec: "DEFAULT=anthropic:primary",
+            want: map[string]Chain{"default": {{"anthropic", "claude-3-5-sonnet", OnRetryable}}},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := loadChains(tt.p, tt.spec)
+            for name, want := range tt.want {
+                if !reflect.DeepEqual(got[name], want) { t.Errorf("chain %q = %v, want %v", name, got[name], want) }
+            }
+            for _, name := range tt.absent {
+                if _, ok := got[name]; ok { t.Errorf("chain %q should not exist", name) }
+            }
+        })
+    }
+}
+
+func TestChainFor(t *testing.T) {
+    p := testProviders("openai", "anthropic", testProvider("openai", "openai"), testProvider("anthropic", "anthropic"), testProvider("gemini", "gemini"))
+    p.Chains = loadChains(p, "gemini-fast=gemini:fast")
+    tests := []struct {
+        engine string
+        fast   bool
+        name   string
+        want   Chain
+    }{
+        {engine: "", name: "default", want: p.Chains["default"]},
+        {engine: "default", fast: true, name: "fast", want: p.Chains["fast"]},
+        {engine: "JuniorEngine", name: "openai", want: Chain{{"openai", "gpt-4.1", OnAny}, {"openai", "gpt-4o", OnRetryable},
+            {"anthropic", "claude-3-5-sonnet", OnAny}, {"anthropic", "claude-3-opus", OnRetryable}}},
+        {engine: "claudeengine", name: "anthropic", want: Chain{{"anthropic", "claude-3-5-sonnet", OnAny}, {"anthropic", "claude-3-opus", OnRetryable},
+            {"openai", "gpt-4.1", OnAny}, {"openai", "gpt-4o", OnRetryable}}},
+        {engine: "anthropic", fast: true, name: "anthropic-fast", want: Chain{{"anthropic", "claude-3-haiku", OnAny}, {"openai", "gpt-4.1-mini", OnAny}}},
+        {engine: "gemini", name: "gemini", want: Chain{{"gemini", "gemini-2.5-pro", OnAny}, {"gemini", "gemini-2.5-flash", OnRetryable},
+            {"openai", "gpt-4.1", OnAny}, {"openai", "gpt-4o", OnRetryable}, {"anthropic", "claude-3-5-sonnet", OnAny}, {"anthropic", "claude-3-opus", OnRetryable}}},
+        {engine: "gemini", fast: true, name: "gemini-fast", want: Chain{{"gemini", "gemini-2.5-flash-lite", OnRetryable}}},
+        {engine: "unknown", name: "default", want: p.Chains["default"]},
+    }
+    for _, tt := range tests {
+        t.Run(tt.engine, func(t *testing.T) {
+            name, got := p.ChainFor(tt.engine, tt.fast)
+            if name != tt.name || !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("ChainFor(%q, %v) = %q %v, want %q %v", tt.engine, tt.fast, name, got, tt.name, tt.want)
+            }
+        })
+    }
+}
+
+func TestLoadProvidersOptions(t *testing.T) {
+    t.Setenv("AI_PROVIDERS", "openai,openai_eu")
+    t.Setenv("OPENAI_API_KEY", "us-key")
+    t.Setenv("OPENAI_BASE_URL", "https://us.example")
+    t.Setenv("OPENAI_EU_KIND", "openai")
+    t.Setenv("OPENAI_EU_API_KEY", "eu-key")
+    t.Setenv("OPENAI_EU_PRIMARY_MODEL", "gpt-eu")
+    t.Setenv("OPENAI_UNKNOWN_SETTING", "x")
+    got := loadProviders("openai", "anthropic", time.Minute)
+    if len(got) != 2 { t.Fatalf("loadProviders returned %d providers, want 2", len(got)) }
+    tests := []struct {
+        pc      ProviderConfig
+        name    string
+        primary string
+        options map[string]string
+    }{
+        {pc: got[0], name: "openai", primary: "gpt-4.1", options: map[string]string{"API_KEY": "us-key", "BASE_URL": "https://us.example"}},
+        {pc: got[1], name: "openai_eu", primary: "gpt-eu", options: map[string]string{"API_KEY": "eu-key"}},
+    }
+    for _, tt := range tests {
+        if tt.pc.Name != tt.name || tt.pc.Kind != "openai" || tt.pc.Models.Primary != tt.primary || tt.pc.Timeout != time.Minute {
+            t.Errorf("provider = %+v, want %s (openai, %s)", tt.pc, tt.name, tt.primary)
+        }
+        if !reflect.DeepEqual(tt.pc.Options, tt.options) { t.Errorf("%s options = %v, want %v", tt.name, tt.pc.Options, tt.options) }
+    }
+}
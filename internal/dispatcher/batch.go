@@ -0,0 +1,153 @@
+package dispatcher
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/local/aidispatcher/internal/ai"
+    mpkg "github.com/local/aidispatcher/internal/metrics"
+    "github.com/local/aidispatcher/internal/prompt"
+    "github.com/local/aidispatcher/internal/queue"
+    "github.com/rs/zerolog/log"
+)
+
+// pagesFromAny reads the "pages" list of a batch payload.
+func pagesFromAny(v any) []int {
+    list, _ := v.([]any)
+    out := make([]int, 0, len(list))
+    for _, p := range list {
+        if n := intFromAny(p); n > 0 { out = append(out, n) }
+    }
+    return out
+}
+
+// processBatch handles a message carrying several pages: one provider request with all
+// page images whose answer is split back into per-page results. Pages the answer does not
+// cover (the call failed, the split failed, the quality guard rejected the page) are
+// re-enqueued as ordinary single-page messages, which retry and fall back to MuPDF as usual.
+// It returns the results and re-enqueued pages for the caller to hand on.
+func (w *Worker) processBatch(ctx context.Context, id int, payload map[string]any, pages []int) *handOff {
+    jobID, _ := payload["job_id"].(string)
+    contentRef, _ := payload["content_ref"].(string)
+    secretRef, _ := payload["secret_ref"].(string)
+    preferEngine, _ := payload["ai_engine"].(string)
+    forceFast := boolFromAny(payload["force_fast"])
+    source, _ := payload["source"].(string)
+    if source == "" { source = "api" }
+    base, _ := splitContentRef(contentRef, pages[0])
+    logger := log.With().Int("worker", id).Str("job_id", jobID).Ints("pages", pages).Logger()
+
+    pr, err := w.buildPrompt(payload, pages[0])
+    images := make([]ai.Image, 0, len(pages))
+    layers := make(map[int]string, len(pages))
+    for i := 0; err == nil && i < len(pages); i++ {
+        var img ai.Image
+        img, layers[pages[i]], err = w.renderPage(ctx, fmt.Sprintf("%s#page=%d", base, pages[i]), pages[i], secretRef)
+        images = append(images, img)
+    }
+    // translations and custom prompts are not transcriptions of the text layer
+    target, _ := payload["target_language"].(string)
+    custom, _ := payload["ai_prompt"].(string)
+    if target != "" || strings.TrimSpace(custom) != "" { layers = map[int]string{} }
+
+    var out pageOutcome
+    var took time.Duration
+    if err == nil {
+        text := prompt.BatchText(pr.Text, pages)
+        if tail := w.previousPageContext(ctx, jobID, contentRef, pages[0], secretRef, intFromAny(payload["context_tokens"])); tail != "" {
+            text = prompt.WithContext(text, tail, pages[0])
+        }
+        started := time.Now()
+        out, err = w.processPage(ctx, jobID, pages[0], contentRef, images, text, "", preferEngine, forceFast)
+        took = time.Since(started)
+    }
+    var accepted []int
+    texts := map[int]string{}
+    scores := map[int]float64{}
+    if err == nil {
+        parts := prompt.SplitPages(out.resp.Text, pages)
+        for _, p := range pages {
+            text, ok := parts[p]
+            if !ok { continue }
+            q := w.checkQuality(jobID, p, out.provider, out.model, text, layers[p])
+            if !q.OK() { continue }
+            accepted = append(accepted, p)
+            texts[p] = text
+            if q.Compared { scores[p] = q.Score }
+        }
+        if len(accepted) < len(pages) {
+            logger.Warn().Str("provider", out.provider).Str("model", out.model).Ints("accepted", accepted).
+                Bool("split", parts != nil).Msg("batch answer incomplete; remaining pages fall back to single-page calls")
+        }
+    } else {
+        logger.Warn().Err(err).Msg("batch request failed; pages fall back to single-page calls")
+    }
+
+    // the batch call's usage is attributed to the pages it produced: input evenly, output by text length
+    weights := make([]int, len(accepted))
+    for i, p := range accepted { weights[i] = len(texts[p]) + 1 }
+    tokensIn := splitTokens(out.resp.TokensIn, nil, len(accepted))
+    tokensOut := splitTokens(out.resp.TokensOut, weights, len(accepted))
+    price := w.conf.Price(out.provider, out.model)
+    h := &handOff{}
+    for i, p := range accepted {
+        res := queue.PageResult{JobID: jobID, PageID: p, Status: queue.ResultDone, Text: texts[p], Provider: out.provider, Model: out.model,
+            PromptTemplate: pr.Name, PromptVersion: pr.Version, PromptHash: pr.Hash,
+            TokensIn: tokensIn[i], TokensOut: tokensOut[i], CostUSD: price.Cost(tokensIn[i], tokensOut[i]), DurationMS: took.Milliseconds(),
+            Replay: boolFromAny(payload["replay"])}
+        if score, ok := scores[p]; ok { res.Quality = &score }
+        h.results = append(h.results, res)
+    }
+
+    done := map[int]bool{}
+    for _, p := range accepted { done[p] = true }
+    var rest []int
+    for _, p := range pages {
+        if !done[p] { rest = append(rest, p) }
+    }
+    for _, p := range rest {
+        single := make(map[string]any, len(payload))
+        for k, v := range payload { single[k] = v }
+        delete(single, "pages")
+        single["page_id"] = p
+        single["content_ref"] = fmt.Sprintf("%s#page=%d", base, p)
+        // same key as a page the orchestrator enqueued on its own
+        single["idempotency_key"] = fmt.Sprintf("doc:%s:page:%d", jobID, p)
+        b, _ := json.Marshal(single)
+        h.enqueue = append(h.enqueue, delayedMessage{payload: b})
+    }
+    h.done = func() {
+        for range accepted {
+            mpkg.IncProcessed("success")
+            mpkg.IncProcessedAttr("success", source, forceFast)
+        }
+        mpkg.AddBatchPages("accepted", len(accepted))
+        mpkg.AddBatchPages("fallback", len(rest))
+        logger.Info().Str("provider", out.provider).Str("model", out.model).Ints("accepted", accepted).Ints("fallback", rest).
+            Int("tokens_in", out.resp.TokensIn).Int("tokens_out", out.resp.TokensOut).Msg("batch processed")
+    }
+    return h
+}
+
+// splitTokens divides total over n pages in proportion to weights (evenly when nil); the
+// parts add up to total.
+func splitTokens(total int, weights []int, n int) []int {
+    out := make([]int, n)
+    if n == 0 { return out }
+    sum := 0
+    for i := range out {
+        if weights == nil { sum++ } else { sum += weights[i] }
+    }
+    left := total
+    for i := range out {
+        w := 1
+        if weights != nil { w = weights[i] }
+        out[i] = total * w / sum
+        left -= out[i]
+    }
+    out[n-1] += left
+    return out
+}
@@ -0,0 +1,37 @@
+package dispatcher
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestSplitTokens(t *testing.T) {
+    tests := []struct {
+        name    string
+        total   int
+        weights []int
+        n       int
+        want    []int
+    }{
+        {name: "no pages", total: 100, n: 0, want: []int{}},
+        {name: "even", total: 90, n: 3, want: []int{30, 30, 30}},
+        {name: "remainder goes to the last page", total: 100, n: 3, want: []int{33, 33, 34}},
+        {name: "weighted", total: 100, weights: []int{1, 3}, n: 2, want: []int{25, 75}},
+        {name: "weighted with remainder", total: 10, weights: []int{1, 1, 1}, n: 3, want: []int{3, 3, 4}},
+        {name: "no usage", total: 0, weights: []int{5, 1}, n: 2, want: []int{0, 0}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := splitTokens(tt.total, tt.weights, tt.n); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("splitTokens(%d, %v, %d) = %v, want %v", tt.total, tt.weights, tt.n, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestPagesFromAny(t *testing.T) {
+    // JSON numbers decode as float64; junk and non-positive pages are dropped
+    got := pagesFromAny([]any{float64(3), "4", float64(0), nil, float64(5)})
+    if want := []int{3, 5}; !reflect.DeepEqual(got, want) { t.Errorf("pagesFromAny = %v, want %v", got, want) }
+    if got := pagesFromAny(nil); len(got) != 0 { t.Errorf("pagesFromAny(nil) = %v", got) }
+}
@@ -0,0 +1,35 @@
+package dispatcher
+
+import (
+    "context"
+    "strings"
+
+    "github.com/local/aidispatcher/internal/prompt"
+    "github.com/rs/zerolog/log"
+)
+
+// previousPageContext returns the end of the page before page, within tokens, for the
+// page's prompt: the stored result when that page is already done, else its PDF text
+// layer. It returns "" for the first page, with tokens <= 0 or when neither is available;
+// the page is then processed without context.
+func (w *Worker) previousPageContext(ctx context.Context, jobID, contentRef string, page int, secretRef string, tokens int) string {
+    if tokens <= 0 || page <= 1 { return "" }
+    ref, page := splitContentRef(contentRef, page)
+    var text string
+    if w.pages != nil {
+        t, err := w.pages.GetPageText(ctx, jobID, page-1)
+        if err != nil { log.Debug().Str("job_id", jobID).Int("page_id", page).Err(err).Msg("previous page text unavailable") }
+        text = t
+    }
+    source := "result"
+    if strings.TrimSpace(text) == "" {
+        source = "text_layer"
+        path, release, err := w.openDocument(ctx, ref, secretRef)
+        if err != nil { return "" }
+        defer release()
+        if text, err = w.renderer.ExtractTextByPage(path, page-1); err != nil { return "" }
+    }
+    tail := prompt.Tail(text, tokens)
+    if tail != "" { log.Debug().Str("job_id", jobID).Int("page_id", page).Str("source", source).Int("chars", len(tail)).Msg("previous page context added") }
+    return tail
+}
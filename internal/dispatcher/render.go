@@ -0,0 +1,198 @@
+package dispatcher
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/local/aidispatcher/internal/ai"
+    "github.com/local/aidispatcher/internal/mupdf"
+    "github.com/local/aidispatcher/internal/storage"
+    "github.com/rs/zerolog/log"
+)
+
+// splitContentRef separates "s3://bucket/key#page=3" into the document ref and page number.
+// When the fragment is missing, fallbackPage is returned.
+func splitContentRef(ref string, fallbackPage int) (string, int) {
+    i := strings.Index(ref, "#")
+    if i < 0 { return ref, fallbackPage }
+    base, frag := ref[:i], ref[i+1:]
+    page := fallbackPage
+    for _, part := range strings.Split(frag, "&") {
+        if v, ok := strings.CutPrefix(part, "page="); ok {
+            if n, err := strconv.Atoi(v); err == nil && n > 0 { page = n }
+        }
+    }
+    return base, page
+}
+
+// docCache keeps downloaded source documents on local disk so that pages of the
+// same document processed by this worker do not re-download the whole file.
+type docCache struct {
+    mu      sync.Mutex
+    entries map[string]*cachedDoc
+    maxIdle time.Duration
+}
+
+type cachedDoc struct {
+    once     sync.Once
+    path     string
+    temp     bool
+    err      error
+    lastUsed time.Time
+    users    int
+}
+
+func newDocCache(maxIdle time.Duration) *docCache {
+    return &docCache{entries: map[string]*cachedDoc{}, maxIdle: maxIdle}
+}
+
+// acquire returns a local path for ref and a release func that must be called when done.
+// password decrypts protected S3 objects; the decrypted copy is what gets cached.
+func (c *docCache) acquire(ctx context.Context, ref, password string) (string, func(), error) {
+    c.mu.Lock()
+    c.sweepLocked()
+    e, ok := c.entries[ref]
+    if !ok {
+        e = &cachedDoc{}
+        c.entries[ref] = e
+    }
+    e.users++
+    e.lastUsed = time.Now()
+    c.mu.Unlock()
+
+    e.once.Do(func() { e.path, e.temp, e.err = fetchDocument(ctx, ref, password) })
+
+    release := func() {
+        c.mu.Lock()
+        e.users--
+        e.lastUsed = time.Now()
+        c.mu.Unlock()
+    }
+    if e.err != nil {
+        // do not cache failures; next attempt retries the download
+        c.mu.Lock()
+        if c.entries[ref] == e { delete(c.entries, ref) }
+        c.mu.Unlock()
+        release()
+        return "", func(){}, e.err
+    }
+    return e.path, release, nil
+}
+
+// sweepLocked removes idle temp downloads. Caller must hold c.mu.
+func (c *docCache) sweepLocked() {
+    now := time.Now()
+    for ref, e := range c.entries {
+        if e.users > 0 || now.Sub(e.lastUsed) < c.maxIdle { continue }
+        if e.temp && e.path != "" { _ = os.Remove(e.path) }
+        delete(c.entries, ref)
+    }
+}
+
+// close removes all cached temp files.
+func (c *docCache) close() {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for ref, e := range c.entries {
+        if e.temp && e.path != "" { _ = os.Remove(e.path) }
+        delete(c.entries, ref)
+    }
+}
+
+// fetchDocument returns a local path for ref, downloading s3:// and http(s):// refs to temp files.
+func fetchDocument(ctx context.Context, ref, password string) (string, bool, error) {
+    switch {
+    case strings.HasPrefix(ref, "file://"):
+        return strings.TrimPrefix(ref, "file://"), false, nil
+    case strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://"):
+        p, err := downloadHTTP(ctx, ref)
+        return p, true, err
+    case strings.HasPrefix(ref, "s3://"):
+        p, err := downloadS3(ctx, ref, password)
+        return p, true, err
+    default:
+        return ref, false, nil
+    }
+}
+
+func downloadHTTP(ctx context.Context, url string) (string, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil { return "", err }
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil { return "", err }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK { return "", fmt.Errorf("http %d", resp.StatusCode) }
+    // same prefix as the orchestrator so CleanupTemps also catches leftovers
+    f, err := os.CreateTemp("", "pdfdl-*.pdf")
+    if err != nil { return "", err }
+    defer f.Close()
+    if _, err := io.Copy(f, resp.Body); err != nil { os.Remove(f.Name()); return "", err }
+    return f.Name(), nil
+}
+
+func downloadS3(ctx context.Context, s3url, password string) (string, error) {
+    path := strings.TrimPrefix(s3url, "s3://")
+    slash := strings.Index(path, "/")
+    if slash <= 0 { return "", fmt.Errorf("invalid s3 url: %s", s3url) }
+    bucket, key := path[:slash], path[slash+1:]
+    cli, err := storage.NewS3Client(ctx, bucket)
+    if err != nil { return "", fmt.Errorf("failed to create S3 client: %w", err) }
+    data, _, err := cli.DownloadFile(ctx, key, password)
+    if err != nil { return "", fmt.Errorf("failed to download from S3: %w", err) }
+    f, err := os.CreateTemp("", "s3pdf-*.pdf")
+    if err != nil { return "", err }
+    defer f.Close()
+    if _, err := f.Write(data); err != nil { os.Remove(f.Name()); return "", err }
+    return f.Name(), nil
+}
+
+// openDocument returns a local path for the document ref and a release func that must be
+// called when done. secretRef, when set, references the document password in the secret store.
+func (w *Worker) openDocument(ctx context.Context, ref, secretRef string) (string, func(), error) {
+    if ref == "" { return "", func(){}, fmt.Errorf("empty content_ref") }
+    password := ""
+    if secretRef != "" {
+        if w.secrets == nil { return "", func(){}, fmt.Errorf("document password required but secret store unavailable") }
+        pw, err := w.secrets.Get(ctx, secretRef)
+        if err != nil { return "", func(){}, fmt.Errorf("document password: %w", err) }
+        password = pw
+    }
+    path, release, err := w.docs.acquire(ctx, ref, password)
+    if err != nil { return "", func(){}, fmt.Errorf("fetch document: %w", err) }
+    return path, release, nil
+}
+
+// renderPage resolves contentRef to a local document and rasterizes the referenced page.
+// With the quality guard on it also returns the page's text layer ("" if it has none).
+func (w *Worker) renderPage(ctx context.Context, contentRef string, pageID int, secretRef string) (ai.Image, string, error) {
+    ref, page := splitContentRef(contentRef, pageID)
+    path, release, err := w.openDocument(ctx, ref, secretRef)
+    if err != nil { return ai.Image{}, "", err }
+    defer release()
+    start := time.Now()
+    rp, err := w.renderer.RenderPage(path, page, mupdf.RenderOptions{
+        DPI:         w.conf.Render.DPI,
+        Format:      w.conf.Render.Format,
+        MaxPixels:   w.conf.Render.MaxPixels,
+        JPEGQuality: w.conf.Render.JPEGQuality,
+    })
+    if err != nil { return ai.Image{}, "", err }
+    log.Debug().Str("content_ref", contentRef).Int("page", page).Int("width", rp.Width).Int("height", rp.Height).
+        Int("bytes", len(rp.Data)).Dur("duration", time.Since(start)).Msg("page rendered for vision model")
+    var text string
+    if w.conf.Quality.Enabled {
+        // without a text layer the guard still catches empty answers and refusals
+        if text, err = w.renderer.ExtractTextByPage(path, page); err != nil {
+            log.Debug().Str("content_ref", contentRef).Int("page", page).Err(err).Msg("text layer unavailable for quality check")
+            text = ""
+        }
+    }
+    return ai.Image{Data: rp.Data, MIME: rp.MIME}, text, nil
+}
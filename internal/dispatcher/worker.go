@@ -5,27 +5,34 @@ import (
     "encoding/json"
     "errors"
     "fmt"
-    "net/http"
     "os"
     "time"
 
     "github.com/local/aidispatcher/internal/ai"
     "github.com/local/aidispatcher/internal/limiter"
+    "github.com/local/aidispatcher/internal/mupdf"
+    "github.com/local/aidispatcher/internal/prompt"
+    "github.com/local/aidispatcher/internal/quality"
+    "github.com/local/aidispatcher/internal/queue"
+    "github.com/local/aidispatcher/internal/secrets"
+    "github.com/local/aidispatcher/internal/store"
     mpkg "github.com/local/aidispatcher/internal/metrics"
     cfgpkg "github.com/local/aidispatcher/internal/config"
     "github.com/rs/zerolog/log"
     "strings"
-    "bytes"
+    "sync"
 )
 
 type Queue interface {
     DequeueAI(ctx context.Context, consumer string, timeout time.Duration) (string, []byte, error)
+    Touch(ctx context.Context, consumer, msgID string) error
     Ack(ctx context.Context, msgID string) error
     IsCancelled(ctx context.Context, jobID string) (bool, error)
     EnqueueDelayed(ctx context.Context, payload []byte, executeAt time.Time) error
-    AddDLQ(ctx context.Context, payload []byte, reason string) error
+    AddDLQ(ctx context.Context, payload []byte, reason, lastErr string) error
     IsIdemDone(ctx context.Context, key string) (bool, error)
     MarkIdemDone(ctx context.Context, key string, ttl time.Duration) error
+    PublishResult(ctx context.Context, payload []byte) error
 }
 
 type Config struct {
@@ -37,32 +44,100 @@ type Worker struct {
     q     Queue
     stop  chan struct{}
     conf  cfgpkg.Config
-    openai ai.Client
-    anthropic ai.Client
+    providers *ai.Registry
     lim   *limiter.Adaptive
+    docs  *docCache
+    renderer *mupdf.GoFitzExtractor
+    prompts  *prompt.Library
+    secrets  *secrets.Vault
+    pages    *store.PageStore // finished page texts, for cross-page context; nil when unavailable
+    consumerPrefix string
+    wg       sync.WaitGroup
 }
 
-func New(cfg Config, q Queue) *Worker {
+// New builds the worker pool. It fails when the shared rate limiter cannot reach Redis:
+// without it no page could be admitted to a provider.
+func New(cfg Config, q Queue) (*Worker, error) {
     if cfg.Concurrency <= 0 { cfg.Concurrency = 2 }
     conf := cfgpkg.FromEnv()
-    lim, _ := limiter.New(limiter.Options{RedisURL: conf.Queue.RedisURL, MaxInflight: conf.Worker.MaxInflightPerModel, BaseBackoff: conf.Worker.BreakerBaseBackoff, MaxBackoff: conf.Worker.BreakerMaxBackoff})
-    return &Worker{cfg: cfg, q: q, stop: make(chan struct{}), conf: conf, openai: ai.NewOpenAIClient(), anthropic: ai.NewAnthropicClient(), lim: lim}
+    budgets := make(map[string]limiter.Budget, len(conf.RateLimit.Models))
+    for k, b := range conf.RateLimit.Models { budgets[k] = limiter.Budget{RPM: b.RPM, TPM: b.TPM} }
+    lim, err := limiter.New(limiter.Options{RedisURL: conf.Queue.RedisURL, MaxInflight: conf.Worker.MaxInflightPerModel, BaseBackoff: conf.Worker.BreakerBaseBackoff, MaxBackoff: conf.Worker.BreakerMaxBackoff,
+        Budgets: budgets, DefaultBudget: limiter.Budget{RPM: conf.RateLimit.Default.RPM, TPM: conf.RateLimit.Default.TPM},
+        Breaker: limiter.BreakerPolicy{ConsecutiveFailures: conf.Worker.BreakerFailures, FailureRate: conf.Worker.BreakerFailureRate,
+            MinSamples: conf.Worker.BreakerMinSamples, Window: conf.Worker.BreakerWindow, ProbeTimeout: conf.Worker.BreakerProbeTimeout},
+        OnTransition: breakerTransition})
+    if err != nil { return nil, fmt.Errorf("rate limiter: %w", err) }
+    pcs := make([]ai.ProviderConfig, 0, len(conf.Providers.List))
+    for _, pc := range conf.Providers.List { pcs = append(pcs, ai.ProviderConfig{Name: pc.Name, Kind: pc.Kind, Options: pc.Options}) }
+    providers, err := ai.NewRegistry(pcs)
+    if err != nil { log.Error().Err(err).Msg("some providers could not be initialised; their chain steps are skipped") }
+    prompts := prompt.NewLibrary(conf.Prompt.DefaultTemplate)
+    if n, err := prompts.LoadDir(conf.Prompt.Dir); err != nil {
+        log.Error().Err(err).Str("dir", conf.Prompt.Dir).Msg("failed to load prompt templates; using built-ins")
+    } else if n > 0 {
+        log.Info().Int("templates", n).Str("dir", conf.Prompt.Dir).Msg("prompt templates loaded")
+    }
+    vault, ephemeral, err := secrets.Open(conf.Queue.RedisURL, conf.Secrets.Key, conf.Secrets.Dev, conf.Secrets.TTL)
+    if err != nil {
+        log.Error().Err(err).Msg("secret store unavailable; password-protected documents will fail")
+        vault = nil
+    } else if ephemeral {
+        log.Warn().Msg("SECRET_KEY_DEV: document passwords only resolve within this process")
+    }
+    pages, err := store.NewPageStore(conf.Queue.RedisURL)
+    if err != nil {
+        log.Error().Err(err).Msg("page store unavailable; cross-page context uses the PDF text layer only")
+        pages = nil
+    }
+    return &Worker{cfg: cfg, q: q, stop: make(chan struct{}), conf: conf, providers: providers, lim: lim,
+        docs: newDocCache(10 * time.Minute), renderer: mupdf.NewGoFitzExtractor(), prompts: prompts, secrets: vault, pages: pages, consumerPrefix: consumerPrefix()}, nil
+}
+
+// consumerPrefix makes consumer names unique per process so that entries left pending by
+// a crashed process are never re-read by a new one under the same name; the queue
+// reclaimer hands them to live consumers instead.
+func consumerPrefix() string {
+    host, _ := os.Hostname()
+    if host == "" { host = "local" }
+    return fmt.Sprintf("%s-%d", host, os.Getpid())
 }
 
 func (w *Worker) Start() {
+    go w.prompts.Watch(w.conf.Prompt.ReloadInterval, w.stop, func(err error) {
+        log.Error().Err(err).Str("dir", w.conf.Prompt.Dir).Msg("prompt template reload failed")
+    })
     for i := 0; i < w.cfg.Concurrency; i++ {
+        w.wg.Add(1)
         go w.loop(i)
     }
 }
 
+// Stop signals workers to exit and waits until in-flight pages finish or ctx expires.
+// Pages cut off by the deadline stay pending and are re-delivered by the reclaimer.
 func (w *Worker) Stop(ctx context.Context) error {
     close(w.stop)
-    return nil
+    done := make(chan struct{})
+    go func() { w.wg.Wait(); close(done) }()
+    select {
+    case <-done:
+        w.docs.close()
+        if w.secrets != nil { _ = w.secrets.Close() }
+        if w.pages != nil { _ = w.pages.Close() }
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
 }
 
 func (w *Worker) loop(id int) {
-    log.Info().Int("worker", id).Msg("dispatcher worker started")
-    port := getenv("PORT", "8080")
+    defer w.wg.Done()
+    consumer := fmt.Sprintf("%s-w-%d", w.consumerPrefix, id)
+    log.Info().Int("worker", id).Str("consumer", consumer).Msg("dispatcher worker started")
+    // outcomes that could not be handed on, by message ID; the message stays pending in this
+    // consumer's PEL until the hand-off is retried from here, never by reading it again
+    parked := map[string]*handOff{}
+    var pause time.Duration
     for {
         select {
         case <-w.stop:
@@ -71,7 +146,19 @@ func (w *Worker) loop(id int) {
         default:
         }
 
-        msgID, data, err := w.q.DequeueAI(context.Background(), fmt.Sprintf("w-%d", id), 2*time.Second)
+        // parked hand-offs go first: re-reading their messages would count deliveries toward
+        // the reclaimer's cap, and new pages would only pile up behind the same Redis problem
+        if len(parked) > 0 {
+            for msgID, h := range parked {
+                // keep the message from looking abandoned while it waits
+                _ = w.q.Touch(context.Background(), consumer, msgID)
+                w.handOn(id, msgID, h, parked, &pause)
+                break
+            }
+            continue
+        }
+
+        msgID, data, err := w.q.DequeueAI(context.Background(), consumer, 2*time.Second)
         if err != nil {
             log.Error().Err(err).Msg("queue dequeue error")
             time.Sleep(500 * time.Millisecond)
@@ -86,11 +173,13 @@ func (w *Worker) loop(id int) {
         pageID := intFromAny(payload["page_id"]) 
         if jobID != "" {
             if cancelled, _ := w.q.IsCancelled(context.Background(), jobID); cancelled {
+                _ = w.q.Ack(context.Background(), msgID)
                 log.Warn().Int("worker", id).Str("job_id", jobID).Int("page_id", pageID).Msg("job cancelled before processing; skipping")
                 continue
             }
         }
         contentRef, _ := payload["content_ref"].(string)
+        secretRef, _ := payload["secret_ref"].(string)
         preferEngine, _ := payload["ai_engine"].(string)
         forceFast := boolFromAny(payload["force_fast"]) 
 
@@ -110,55 +199,183 @@ func (w *Worker) loop(id int) {
             continue
         }
 
-        ok, provider, model, text, perr := w.processPage(overallCtx, jobID, pageID, contentRef, preferEngine, forceFast)
+        // Page groups go out as one request; pages it does not cover come back as single pages
+        if pages := pagesFromAny(payload["pages"]); len(pages) > 1 {
+            h := w.processBatch(overallCtx, id, payload, pages)
+            cancelOverall()
+            batchDone := h.done
+            h.done = func() {
+                _ = w.q.MarkIdemDone(context.Background(), idemKey, 24*time.Hour)
+                _ = w.q.Ack(context.Background(), msgID)
+                batchDone()
+            }
+            w.handOn(id, msgID, h, parked, &pause)
+            continue
+        }
+
+        // Rasterize the page once; every provider attempt reuses the same image and prompt
+        var ok, called bool
+        var out pageOutcome
+        var img ai.Image
+        var layer string
+        var took time.Duration
+        pr, perr := w.buildPrompt(payload, pageID)
+        if perr != nil {
+            log.Error().Int("worker", id).Str("job_id", jobID).Int("page_id", pageID).Err(perr).Msg("prompt render failed")
+        } else if img, layer, perr = w.renderPage(overallCtx, contentRef, pageID, secretRef); perr != nil {
+            log.Error().Int("worker", id).Str("job_id", jobID).Int("page_id", pageID).Str("content_ref", contentRef).
+                Err(perr).Msg("page render failed")
+        } else {
+            // translations and custom prompts are not transcriptions of the text layer
+            if target, _ := payload["target_language"].(string); target != "" { layer = "" }
+            if custom, _ := payload["ai_prompt"].(string); strings.TrimSpace(custom) != "" { layer = "" }
+            called = true
+            text := pr.Text
+            if tail := w.previousPageContext(overallCtx, jobID, contentRef, pageID, secretRef, intFromAny(payload["context_tokens"])); tail != "" {
+                text = prompt.WithContext(text, tail, pageID)
+            }
+            started := time.Now()
+            out, perr = w.processPage(overallCtx, jobID, pageID, contentRef, []ai.Image{img}, text, layer, preferEngine, forceFast)
+            took = time.Since(started)
+            ok = perr == nil
+        }
+        provider, model, resp := out.provider, out.model, out.resp
         source, _ := payload["source"].(string)
         if source == "" { source = "api" }
         if ok {
-            url := fmt.Sprintf("http://127.0.0.1:%s/internal/page_done?job_id=%s&page_id=%d", port, jobID, pageID)
-            body := map[string]any{"text": text, "provider": provider, "model": model}
-            b, _ := json.Marshal(body)
-            _, _ = http.Post(url, "application/json", bytes.NewReader(b))
-            // mark idempotency done (24h)
-            _ = w.q.MarkIdemDone(context.Background(), idemKey, 24*time.Hour)
-            _ = w.q.Ack(context.Background(), msgID)
-            mpkg.IncProcessed("success")
-            mpkg.IncProcessedAttr("success", source, forceFast)
+            res := queue.PageResult{JobID: jobID, PageID: pageID, Status: queue.ResultDone, Text: resp.Text, Provider: provider, Model: model,
+                PromptTemplate: pr.Name, PromptVersion: pr.Version, PromptHash: pr.Hash,
+                TokensIn: resp.TokensIn, TokensOut: resp.TokensOut, CostUSD: w.conf.Price(provider, model).Cost(resp.TokensIn, resp.TokensOut),
+                DurationMS: took.Milliseconds(), Replay: boolFromAny(payload["replay"])}
+            if out.quality.Compared { res.Quality = &out.quality.Score }
             cancelOverall()
-            log.Info().Int("worker", id).Str("job_id", jobID).Int("page_id", pageID).Str("provider", provider).
-                Str("model", model).Int("attempt", attempt).Int("text_len", len(text)).Msg("page processed successfully")
+            w.handOn(id, msgID, &handOff{results: []queue.PageResult{res}, done: func() {
+                // mark idempotency done (24h)
+                _ = w.q.MarkIdemDone(context.Background(), idemKey, 24*time.Hour)
+                _ = w.q.Ack(context.Background(), msgID)
+                mpkg.IncProcessed("success")
+                mpkg.IncProcessedAttr("success", source, forceFast)
+                log.Info().Int("worker", id).Str("job_id", jobID).Int("page_id", pageID).Str("provider", provider).
+                    Str("model", model).Int("attempt", attempt).Str("prompt_template", pr.Name).Int("prompt_version", pr.Version).
+                    Int("text_len", len(resp.Text)).Int("tokens_in", res.TokensIn).Int("tokens_out", res.TokensOut).Float64("cost_usd", res.CostUSD).
+                    Msg("page processed successfully")
+            }}, parked, &pause)
         } else {
             // retry with backoff or DLQ
             attempt := intFromAny(payload["attempt"]) 
             if attempt <= 0 { attempt = 1 }
-            if attempt >= w.conf.Worker.JobMaxAttempts {
-                // push to DLQ
-                _ = w.q.AddDLQ(context.Background(), data, "max_attempts")
-                _ = w.q.Ack(context.Background(), msgID)
+            // a request every provider rejected as invalid fails the same way on every attempt,
+            // and so does a page every model of the chain answered badly
+            fatal := called && ai.Classify(perr) == ai.ClassFatal
+            rejected := called && errors.Is(perr, errLowQuality) && !out.skipped
+            if attempt >= w.conf.Worker.JobMaxAttempts || fatal || rejected {
                 // Inform orchestrator for MuPDF fallback on final failure
-                url := fmt.Sprintf("http://127.0.0.1:%s/internal/page_failed?job_id=%s&page_id=%d", port, jobID, pageID)
-                _, _ = http.Post(url, "text/plain", nil)
-                mpkg.IncProcessed("dlq")
-                mpkg.IncProcessedAttr("dlq", source, forceFast)
-                log.Error().Int("worker", id).Str("job_id", jobID).Int("page_id", pageID).Int("attempt", attempt).
-                    Err(perr).Msg("page failed max attempts; sent to DLQ")
+                res := queue.PageResult{JobID: jobID, PageID: pageID, Status: queue.ResultFailed}
+                if perr != nil { res.Error = perr.Error() }
+                reason := "max_attempts"
+                if fatal { reason = "fatal" }
+                if rejected { reason = "low_quality" }
+                cancelOverall()
+                w.handOn(id, msgID, &handOff{results: []queue.PageResult{res}, done: func() {
+                    // push to DLQ
+                    _ = w.q.AddDLQ(context.Background(), data, reason, res.Error)
+                    _ = w.q.Ack(context.Background(), msgID)
+                    mpkg.IncProcessed("dlq")
+                    mpkg.IncProcessedAttr("dlq", source, forceFast)
+                    log.Error().Int("worker", id).Str("job_id", jobID).Int("page_id", pageID).Int("attempt", attempt).
+                        Str("reason", reason).Err(perr).Msg("page failed; sent to DLQ")
+                }}, parked, &pause)
             } else {
                 // requeue delayed with incremented attempt
                 payload["attempt"] = attempt + 1
                 b, _ := json.Marshal(payload)
-                delay := backoffDelay(w.conf.Worker.RetryBaseDelay, w.conf.Worker.RetryBackoffFactor, attempt)
-                _ = w.q.EnqueueDelayed(context.Background(), b, time.Now().Add(delay))
-                _ = w.q.Ack(context.Background(), msgID)
-                mpkg.IncRetry()
-                mpkg.IncRetryAttr(source, forceFast)
-                log.Warn().Int("worker", id).Str("job_id", jobID).Int("page_id", pageID).Int("attempt", attempt).
-                    Dur("retry_in", delay).Err(perr).Msg("page processing failed; scheduled retry")
+                delay := backoffDelay(w.conf.Worker.RetryBaseDelay, w.conf.Worker.RetryBackoffFactor, attempt, ai.RetryAfter(perr))
+                cancelOverall()
+                w.handOn(id, msgID, &handOff{enqueue: []delayedMessage{{payload: b, at: time.Now().Add(delay)}}, done: func() {
+                    _ = w.q.Ack(context.Background(), msgID)
+                    mpkg.IncRetry()
+                    mpkg.IncRetryAttr(source, forceFast)
+                    log.Warn().Int("worker", id).Str("job_id", jobID).Int("page_id", pageID).Int("attempt", attempt).
+                        Dur("retry_in", delay).Err(perr).Msg("page processing failed; scheduled retry")
+                }}, parked, &pause)
             }
-            cancelOverall()
         }
     }
 }
 
-func getenv(k, d string) string { if v := os.Getenv(k); v != "" { return v }; return d }
+// handOff is what remains to be done for a message after its pages were processed: results
+// to publish, messages to enqueue, then done (mark idempotency, ack, metrics).
+type handOff struct {
+    results []queue.PageResult
+    enqueue []delayedMessage
+    done    func()
+}
+
+type delayedMessage struct {
+    payload []byte
+    at      time.Time // zero = now
+}
+
+// deliver runs the remaining steps of h, dropping each one that succeeded, so a retry after
+// an error never publishes or enqueues twice.
+func (w *Worker) deliver(h *handOff) error {
+    for len(h.results) > 0 {
+        if err := w.publishResult(h.results[0]); err != nil { return fmt.Errorf("publish page %d result: %w", h.results[0].PageID, err) }
+        h.results = h.results[1:]
+    }
+    for len(h.enqueue) > 0 {
+        m := h.enqueue[0]
+        if m.at.IsZero() { m.at = time.Now() }
+        if err := w.q.EnqueueDelayed(context.Background(), m.payload, m.at); err != nil { return fmt.Errorf("enqueue page: %w", err) }
+        h.enqueue = h.enqueue[1:]
+    }
+    if h.done != nil { h.done() }
+    return nil
+}
+
+// maxHandOffPause caps the pause between hand-off retries of a parked message.
+const maxHandOffPause = 30 * time.Second
+
+// handOn delivers h. On failure the message stays unacked and h is parked under its ID: the
+// worker pauses (doubling up to maxHandOffPause) and its loop retries only the hand-off,
+// never the provider call, before it reads anything else.
+func (w *Worker) handOn(id int, msgID string, h *handOff, parked map[string]*handOff, pause *time.Duration) {
+    err := w.deliver(h)
+    if err == nil {
+        delete(parked, msgID)
+        *pause = 0
+        return
+    }
+    parked[msgID] = h
+    switch {
+    case *pause == 0:
+        *pause = 500 * time.Millisecond
+    case *pause < maxHandOffPause:
+        *pause *= 2
+        if *pause > maxHandOffPause { *pause = maxHandOffPause }
+    }
+    log.Error().Int("worker", id).Str("msg_id", msgID).Err(err).Dur("retry_in", *pause).
+        Msg("failed to hand on page outcome; retrying without a new provider call")
+    select {
+    case <-w.stop:
+    case <-time.After(*pause):
+    }
+}
+
+// publishResult sends a page outcome to the orchestrator, retrying briefly on Redis errors.
+func (w *Worker) publishResult(res queue.PageResult) error {
+    b, err := json.Marshal(res)
+    if err != nil { return err }
+    delay := 200 * time.Millisecond
+    for attempt := 1; ; attempt++ {
+        ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+        err = w.q.PublishResult(ctx, b)
+        cancel()
+        if err == nil || attempt >= 3 { return err }
+        time.Sleep(delay)
+        delay *= 2
+    }
+}
 
 func intFromAny(v any) int {
     switch t := v.(type) {
@@ -183,42 +400,78 @@ func boolFromAny(v any) bool {
     }
 }
 
-func backoffDelay(base time.Duration, factor float64, attempt int) time.Duration {
+// backoffDelay is the delay before the next attempt of a page; a provider's Retry-After
+// wins over the computed backoff when it is longer.
+func backoffDelay(base time.Duration, factor float64, attempt int, retryAfter time.Duration) time.Duration {
     if attempt < 1 { attempt = 1 }
     d := float64(base)
     for i := 1; i < attempt; i++ {
         d *= factor
     }
+    if float64(retryAfter) > d { d = float64(retryAfter) }
     max := 5 * time.Minute
     if time.Duration(d) > max { return max }
     return time.Duration(d)
 }
 
-func (w *Worker) processPage(ctx context.Context, jobID string, pageID int, contentRef, preferEngine string, forceFast bool) (bool, string, string, string, error) {
-    // Determine providers and models from config
-    primaryProv := w.conf.Providers.PrimaryEngine
-    secondaryProv := w.conf.Providers.SecondaryEngine
-    if preferEngine != "" { primaryProv = strings.ToLower(preferEngine) }
+// buildPrompt renders the page prompt from the payload: an ai_prompt override wins,
+// otherwise the named (or default) template is used.
+func (w *Worker) buildPrompt(payload map[string]any, pageID int) (prompt.Rendered, error) {
+    language, _ := payload["language"].(string)
+    target, _ := payload["target_language"].(string)
+    vars := prompt.Vars{Page: pageID, TotalPages: intFromAny(payload["total_pages"]), Language: language, TargetLanguage: target}
+    if custom, _ := payload["ai_prompt"].(string); strings.TrimSpace(custom) != "" {
+        return prompt.RenderCustom(custom, vars), nil
+    }
+    name, _ := payload["prompt_template"].(string)
+    return w.prompts.Render(name, intFromAny(payload["prompt_version"]), vars)
+}
+
+// pageOutcome is the answer processPage accepted, or what is known about the failure.
+type pageOutcome struct {
+    provider, model string
+    resp            ai.Response
+    quality         quality.Result
+    skipped         bool // some chain step was skipped (breaker open, no slot, unknown provider)
+}
+
+// processPage walks the page's chain until a model returns an answer the quality guard
+// accepts. layer is the page's text layer, "" when it must not be compared.
+func (w *Worker) processPage(ctx context.Context, jobID string, pageID int, contentRef string, images []ai.Image, promptText, layer, preferEngine string, forceFast bool) (pageOutcome, error) {
+    chainName, chain := w.conf.Providers.ChainFor(preferEngine, forceFast)
 
     // Helper to call provider/model with per-request timeout + metrics
-    call := func(provider, model string) (ai.Response, error) {
+    call := func(client ai.Client, provider, model string) (ai.Response, error) {
         timeout := w.conf.Worker.RequestTimeout
-        if provider == "openai" { timeout = w.conf.Worker.OpenAITimeout }
-        if provider == "anthropic" { timeout = w.conf.Worker.AnthropicTimeout }
-        if timeout <= 0 { timeout = w.conf.Worker.RequestTimeout }
+        if pc, ok := w.conf.Providers.Provider(provider); ok && pc.Timeout > 0 { timeout = pc.Timeout }
 
-        req := ai.Request{JobID: jobID, PageID: pageID, ContentRef: contentRef, Model: model, Timeout: timeout}
+        req := ai.Request{JobID: jobID, PageID: pageID, ContentRef: contentRef, Model: model, Prompt: promptText, Images: images, Timeout: timeout}
+        // Shared RPM/TPM budget: wait briefly for a refill, otherwise let the caller fail over
+        estimate := int(float64(ai.EstimateTokens(req)) * (1 + w.conf.RateLimit.TokenMargin))
+        resv, waited, rerr := w.lim.Wait(ctx, provider, model, estimate, w.conf.RateLimit.MaxWait)
+        switch {
+        case errors.Is(rerr, limiter.ErrNoBudget):
+            mpkg.IncRateLimit(provider, model, "exhausted")
+            log.Info().Str("job_id", jobID).Int("page_id", pageID).Str("provider", provider).Str("model", model).
+                Int("tokens_estimate", estimate).Msg("rate budget exhausted; trying next model")
+            return ai.Response{}, errNoBudget
+        case rerr != nil && ctx.Err() != nil:
+            return ai.Response{}, context.DeadlineExceeded
+        case rerr != nil:
+            // a limiter outage must not stop processing; the provider's own 429s still apply
+            mpkg.IncRateLimit(provider, model, "error")
+            log.Warn().Str("provider", provider).Str("model", model).Err(rerr).Msg("rate budget unavailable; calling without reservation")
+        case waited > 0:
+            mpkg.IncRateLimit(provider, model, "waited")
+            log.Debug().Str("job_id", jobID).Int("page_id", pageID).Str("provider", provider).Str("model", model).
+                Dur("waited", waited).Msg("waited for rate budget")
+        }
         cctx, cancel := context.WithTimeout(ctx, timeout)
         defer cancel()
-        var client ai.Client
-        switch provider {
-        case "openai": client = w.openai
-        case "anthropic": client = w.anthropic
-        default: client = w.openai
-        }
         start := time.Now()
         resp, err := client.Do(cctx, req)
         dur := time.Since(start)
+        if rerr == nil { w.reconcile(resv, resp, err) }
         if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
             mpkg.ObserveProvider(provider, model, "timeout", dur)
             log.Warn().Str("job_id", jobID).Int("page_id", pageID).Str("provider", provider).Str("model", model).
@@ -228,9 +481,12 @@ func (w *Worker) processPage(ctx context.Context, jobID string, pageID int, cont
         // classify
         result := "success"
         if err != nil {
-            if ai.IsRateLimited(err) { result = "rate_limited" } else if isTransient(err) { result = "transient" } else { result = "fatal" }
+            result = string(ai.Classify(err))
         }
         mpkg.ObserveProvider(provider, model, result, dur)
+        if resp.TokensIn+resp.TokensOut > 0 {
+            mpkg.AddUsage(provider, model, resp.TokensIn, resp.TokensOut, w.conf.Price(provider, model).Cost(resp.TokensIn, resp.TokensOut))
+        }
         if err != nil {
             log.Warn().Str("job_id", jobID).Int("page_id", pageID).Str("provider", provider).Str("model", model).
                 Dur("duration", dur).Err(err).Msg("provider call failed")
@@ -241,145 +497,110 @@ func (w *Worker) processPage(ctx context.Context, jobID string, pageID int, cont
         return resp, err
     }
 
-    // Fast-path: only fast models if requested
-    var lastErr error
-    if forceFast {
-        fModel := w.fastModel(primaryProv)
-        if fModel != "" && !w.lim.IsOpen(ctx, primaryProv, fModel) {
-            relF, okF := w.lim.Allow(primaryProv, fModel)
-            if okF {
-                if resp, err := call(primaryProv, fModel); err == nil {
-                    relF(); w.lim.Close(ctx, primaryProv, fModel); return true, primaryProv, fModel, resp.Text, nil
-                }
-                relF()
-            }
-        }
-        sfModel := w.fastModel(secondaryProv)
-        if sfModel != "" && !w.lim.IsOpen(ctx, secondaryProv, sfModel) {
-            relSF, okSF := w.lim.Allow(secondaryProv, sfModel)
-            if okSF {
-                if resp2, err2 := call(secondaryProv, sfModel); err2 == nil {
-                    relSF(); w.lim.Close(ctx, secondaryProv, sfModel); return true, secondaryProv, sfModel, resp2.Text, nil
-                } else {
-                    lastErr = err2
-                }
-                relSF()
-            }
-        }
-        if lastErr == nil { lastErr = fmt.Errorf("fast models unavailable for job %s page %d", jobID, pageID) }
-        return false, "", "", "", lastErr
-    }
-
-    // Try primary provider primary model
-    var err error
-    var resp ai.Response
-    pModel := w.primaryModel(primaryProv)
-    sModel := w.secondaryModel(primaryProv)
-
-    if !w.lim.IsOpen(ctx, primaryProv, pModel) {
-        rel, ok := w.lim.Allow(primaryProv, pModel)
-        if ok {
-            resp, err = call(primaryProv, pModel)
-            rel()
-            if err == nil { w.lim.Close(ctx, primaryProv, pModel); mpkg.BreakerClosed(primaryProv, pModel); return true, primaryProv, pModel, resp.Text, nil }
-            lastErr = err
-            if ai.IsRateLimited(err) || isTransient(err) {
-                w.lim.Open(ctx, primaryProv, pModel)
-                mpkg.BreakerOpened(primaryProv, pModel)
-                log.Warn().Str("job_id", jobID).Int("page_id", pageID).Str("provider", primaryProv).
-                    Str("model", pModel).Err(err).Msg("primary model hit limiter; opening circuit")
-            }
-        }
-    }
-
-    // On 429 or transient → try secondary model of same provider
-    if (ai.IsRateLimited(err) || isTransient(err)) && sModel != "" {
-        if !w.lim.IsOpen(ctx, primaryProv, sModel) {
-            rel2, ok2 := w.lim.Allow(primaryProv, sModel)
-            if ok2 {
-                if resp2, err2 := call(primaryProv, sModel); err2 == nil {
-                    rel2(); w.lim.Close(ctx, primaryProv, sModel); mpkg.BreakerClosed(primaryProv, sModel); return true, primaryProv, sModel, resp2.Text, nil
-                } else {
-                    lastErr = err2
-                }
-                rel2()
-            }
+    // try calls provider/model when its breaker and the local in-flight cap allow it, and
+    // reports the outcome to the breaker. called=false means the model was skipped.
+    try := func(provider, model string) (resp ai.Response, called bool, err error) {
+        client, ok := w.providers.Client(provider)
+        if !ok { return ai.Response{}, false, nil }
+        permit, ok := w.lim.Acquire(ctx, provider, model)
+        if !ok { return ai.Response{}, false, nil }
+        rel, ok := w.lim.Allow(provider, model)
+        if !ok { w.lim.Release(context.Background(), permit); return ai.Response{}, false, nil }
+        resp, err = call(client, provider, model)
+        rel()
+        switch {
+        case err == nil:
+            w.lim.Success(context.Background(), permit)
+        case errors.Is(err, errNoBudget), ai.Classify(err) == ai.ClassFallback:
+            // no budget, or the model answered but declined this page: its health is unchanged
+            w.lim.Release(context.Background(), permit)
+        case ai.Retryable(err):
+            w.lim.Failure(context.Background(), permit, ai.Classify(err) == ai.ClassRateLimited, ai.RetryAfter(err))
+        default:
+            // the request itself was rejected; that says nothing about the model's health
+            w.lim.Release(context.Background(), permit)
         }
+        return resp, true, err
     }
 
-    // Try secondary provider primary model (and its secondary on transient/429)
-    spModel := w.primaryModel(secondaryProv)
-    if !w.lim.IsOpen(ctx, secondaryProv, spModel) {
-        rel3, ok3 := w.lim.Allow(secondaryProv, spModel)
-        if ok3 {
-            if resp3, err2 := call(secondaryProv, spModel); err2 == nil {
-                rel3(); w.lim.Close(ctx, secondaryProv, spModel); mpkg.BreakerClosed(secondaryProv, spModel); return true, secondaryProv, spModel, resp3.Text, nil
-            } else {
-                lastErr = err2
-            }
-            rel3()
-            if ai.IsRateLimited(err) || isTransient(err) {
-                ssModel := w.secondaryModel(secondaryProv)
-                if ssModel != "" && !w.lim.IsOpen(ctx, secondaryProv, ssModel) {
-                    rel4, ok4 := w.lim.Allow(secondaryProv, ssModel)
-                    if ok4 {
-                        if resp4, err4 := call(secondaryProv, ssModel); err4 == nil {
-                            rel4(); w.lim.Close(ctx, secondaryProv, ssModel); mpkg.BreakerClosed(secondaryProv, ssModel); return true, secondaryProv, ssModel, resp4.Text, nil
-                        } else {
-                            lastErr = err4
-                        }
-                        rel4()
-                    }
-                }
-            }
+    // Walk the chain; a step runs when its condition matches the last error (or nothing
+    // was called yet). Skipped models (breaker open, no local slot) never stop the walk.
+    var out pageOutcome
+    var lastErr error
+    for _, step := range chain {
+        if lastErr != nil && !stepApplies(step.On, lastErr) { continue }
+        resp, called, err := try(step.Provider, step.Model)
+        if !called { out.skipped = true; continue }
+        if err == nil {
+            q := w.checkQuality(jobID, pageID, step.Provider, step.Model, resp.Text, layer)
+            if q.OK() { return pageOutcome{provider: step.Provider, model: step.Model, resp: resp, quality: q}, nil }
+            out.quality = q
+            err = fmt.Errorf("%w: %s answer from %s:%s", errLowQuality, q.Reason, step.Provider, step.Model)
         }
+        lastErr = err
     }
-    if lastErr == nil { lastErr = err }
-    if lastErr == nil { lastErr = fmt.Errorf("no provider succeeded for job %s page %d", jobID, pageID) }
-    return false, "", "", "", lastErr
+    // every model was skipped: retry the page later rather than treating it as failed
+    if lastErr == nil { lastErr = fmt.Errorf("%w: no model of chain %q available for job %s page %d", ai.ErrRateLimited, chainName, jobID, pageID) }
+    return out, lastErr
 }
 
-func (w *Worker) primaryModel(provider string) string {
-    switch provider {
-    case "openai": return w.conf.Providers.OpenAI.Primary
-    case "anthropic": return w.conf.Providers.Anthropic.Primary
-    default: return w.conf.Providers.OpenAI.Primary
+// checkQuality runs the quality guard on an answer; with the guard off every answer passes.
+func (w *Worker) checkQuality(jobID string, pageID int, provider, model, text, layer string) quality.Result {
+    if !w.conf.Quality.Enabled { return quality.Result{} }
+    q := quality.Check(text, layer, quality.Options{MinSimilarity: w.conf.Quality.MinSimilarity,
+        MinTextChars: w.conf.Quality.MinTextChars, MaxGarbageRatio: w.conf.Selection.MaxGarbageRatio})
+    if q.Compared { mpkg.ObserveQuality(provider, model, q.Score) }
+    if !q.OK() {
+        mpkg.IncQualityRejection(provider, model, q.Reason)
+        log.Warn().Str("job_id", jobID).Int("page_id", pageID).Str("provider", provider).Str("model", model).
+            Str("reason", q.Reason).Float64("quality", q.Score).Int("text_len", len(text)).Msg("answer rejected by quality guard; trying next model")
     }
+    return q
 }
 
-func (w *Worker) secondaryModel(provider string) string {
-    switch provider {
-    case "openai": return w.conf.Providers.OpenAI.Secondary
-    case "anthropic": return w.conf.Providers.Anthropic.Secondary
-    default: return ""
+// stepApplies reports whether a chain step with condition on follows a failed call.
+func stepApplies(on string, err error) bool {
+    switch on {
+    case cfgpkg.OnAny:
+        return true
+    case cfgpkg.OnRateLimited:
+        return ai.Classify(err) == ai.ClassRateLimited
+    case cfgpkg.OnTransient:
+        return ai.Classify(err) == ai.ClassTransient
+    case cfgpkg.OnTimeout:
+        return errors.Is(err, context.DeadlineExceeded)
+    default:
+        return ai.Retryable(err)
     }
 }
 
-func (w *Worker) fastModel(provider string) string {
-    switch provider {
-    case "openai": return w.conf.Providers.OpenAI.Fast
-    case "anthropic": return w.conf.Providers.Anthropic.Fast
-    default: return ""
-    }
+// breakerTransition exports breaker state changes made by this process.
+func breakerTransition(provider, model, state string) {
+    mpkg.BreakerTransition(provider, model, state)
+    ev := log.Info()
+    if state == limiter.StateOpen { ev = log.Warn() }
+    ev.Str("provider", provider).Str("model", model).Str("state", state).Msg("circuit breaker state changed")
 }
 
-// isTransient: timeouts and 5xx treated as transient; 4xx (except 429) as fatal.
-func isTransient(err error) bool {
-    if err == nil { return false }
-    if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) { return true }
-    es := err.Error()
-    // quick parse of status code from provider error strings
-    if strings.Contains(es, "status ") {
-        // treat any 5xx as transient
-        for code := 500; code <= 599; code++ {
-            if strings.Contains(es, fmt.Sprintf("%d", code)) { return true }
-        }
-        // treat 4xx (except 429) as fatal
-        for code := 400; code <= 499; code++ {
-            if code == 429 { continue }
-            if strings.Contains(es, fmt.Sprintf("%d", code)) { return false }
-        }
+// errLowQuality wraps ai.ErrBadResponse so the chain fails over as after an unusable
+// reply; the model answered, so its breaker is not touched.
+var errLowQuality = fmt.Errorf("%w: rejected by quality guard", ai.ErrBadResponse)
+
+// errNoBudget counts as rate limited so processPage fails over exactly as on a 429, but
+// it never opens the breaker: the provider itself was not asked.
+var errNoBudget = fmt.Errorf("%w: shared rate budget exhausted", ai.ErrRateLimited)
+
+// reconcile replaces the token estimate with the usage the provider reported. Without a
+// usage report a rejected request is refunded, while a timed-out one keeps its reservation
+// since the provider may still have processed it.
+func (w *Worker) reconcile(resv limiter.Reservation, resp ai.Response, callErr error) {
+    actual := resp.TokensIn + resp.TokensOut
+    if actual == 0 {
+        if callErr == nil || errors.Is(callErr, context.DeadlineExceeded) || errors.Is(callErr, context.Canceled) { return }
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+    defer cancel()
+    if err := w.lim.Reconcile(ctx, resv, actual); err != nil {
+        log.Warn().Err(err).Str("provider", resv.Provider).Str("model", resv.Model).Msg("rate budget reconcile failed")
     }
-    // default safe side: transient
-    return true
 }
@@ -0,0 +1,88 @@
+package dispatcher
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+
+    cfgpkg "github.com/local/aidispatcher/internal/config"
+    "github.com/local/aidispatcher/internal/prompt"
+)
+
+// scriptedQueue hands out one message and fails the first publishFailures result publishes.
+type scriptedQueue struct {
+    mu              sync.Mutex
+    msg             []byte
+    reads, touches  int
+    readsAtAck      int
+    published       int
+    publishFailures int
+    acked           chan string
+}
+
+func (q *scriptedQueue) DequeueAI(ctx context.Context, consumer string, timeout time.Duration) (string, []byte, error) {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    q.reads++
+    if q.reads == 1 { return "1-0", q.msg, nil }
+    time.Sleep(5 * time.Millisecond)
+    return "", nil, nil
+}
+
+func (q *scriptedQueue) Touch(ctx context.Context, consumer, msgID string) error {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    q.touches++
+    return nil
+}
+
+func (q *scriptedQueue) PublishResult(ctx context.Context, payload []byte) error {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    if q.publishFailures > 0 {
+        q.publishFailures--
+        return errors.New("connection refused")
+    }
+    q.published++
+    return nil
+}
+
+func (q *scriptedQueue) Ack(ctx context.Context, msgID string) error {
+    q.mu.Lock()
+    q.readsAtAck = q.reads
+    q.mu.Unlock()
+    q.acked <- msgID
+    return nil
+}
+
+func (q *scriptedQueue) IsCancelled(context.Context, string) (bool, error) { return false, nil }
+func (q *scriptedQueue) EnqueueDelayed(context.Context, []byte, time.Time) error { return nil }
+func (q *scriptedQueue) AddDLQ(context.Context, []byte, string, string) error { return nil }
+func (q *scriptedQueue) IsIdemDone(context.Context, string) (bool, error) { return false, nil }
+func (q *scriptedQueue) MarkIdemDone(context.Context, string, time.Duration) error { return nil }
+
+func TestParkedHandOffIsRetriedWithoutRereading(t *testing.T) {
+    // an unknown prompt template fails the page without a provider call; with one attempt
+    // allowed, the worker hands on a failed result, and the first publish round fails
+    q := &scriptedQueue{msg: []byte(`{"job_id":"job","page_id":1,"attempt":1,"prompt_template":"missing"}`), publishFailures: 3, acked: make(chan string, 1)}
+    conf := cfgpkg.Config{}
+    conf.Worker.JobMaxAttempts = 1
+    conf.Worker.PageTotalTimeout = time.Minute
+    w := &Worker{q: q, stop: make(chan struct{}), conf: conf, prompts: prompt.NewLibrary("default"), consumerPrefix: "test"}
+    w.wg.Add(1)
+    go w.loop(0)
+    defer func() { close(w.stop); w.wg.Wait() }()
+
+    select {
+    case id := <-q.acked:
+        if id != "1-0" { t.Fatalf("acked %q", id) }
+    case <-time.After(10 * time.Second):
+        t.Fatal("parked hand-off was never delivered")
+    }
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    if q.readsAtAck != 1 { t.Errorf("queue read %d times before the hand-off went through, want 1", q.readsAtAck) }
+    if q.touches != 1 || q.published != 1 { t.Errorf("touches = %d, published = %d; want 1 each", q.touches, q.published) }
+}
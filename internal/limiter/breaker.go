@@ -0,0 +1,220 @@
+package limiter
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    redis "github.com/redis/go-redis/v9"
+)
+
+// Breaker states.
+const (
+    StateClosed   = "closed"    // calls flow; failures are counted
+    StateOpen     = "open"      // calls are refused until retry_at
+    StateHalfOpen = "half_open" // one probe call decides between closed and open
+)
+
+// BreakerPolicy decides when a closed breaker opens and how long it stays open.
+type BreakerPolicy struct {
+    ConsecutiveFailures int           // open after this many failures in a row (0 disables)
+    FailureRate         float64       // open when failures/calls in Window reach this (0 disables)
+    MinSamples          int           // calls needed in Window before FailureRate applies
+    Window              time.Duration
+    ProbeTimeout        time.Duration // a probe permit is handed to another worker after this
+}
+
+// Permit allows one call to provider:model. A probe permit is the only call let through
+// while the breaker is half-open; its outcome must be reported.
+type Permit struct {
+    Provider string
+    Model    string
+    Probe    bool
+}
+
+// BreakerState is a snapshot of one breaker for the admin endpoint.
+type BreakerState struct {
+    Provider   string     `json:"provider"`
+    Model      string     `json:"model"`
+    State      string     `json:"state"`
+    RetryAt    *time.Time `json:"retry_at,omitempty"`
+    ProbeUntil *time.Time `json:"probe_until,omitempty"`
+    Opens      int        `json:"opens"`    // consecutive opens, drives the backoff
+    Failures   int        `json:"failures"` // consecutive failures while closed
+}
+
+// Breaker keys: cb:{provider}:{model} holds the state, cbw:{provider}:{model} the
+// outcome counters of the current window.
+func (a *Adaptive) breakerKeys(provider, model string) []string {
+    pm := strings.ToLower(provider) + ":" + strings.ToLower(model)
+    return []string{"cb:" + pm, "cbw:" + pm}
+}
+
+// breakerIdleTTL drops state of models that have not been called for a day.
+const breakerIdleTTL = 24 * time.Hour
+
+// acquireScript lets calls through a closed breaker, refuses them while open and hands
+// out a single probe permit once the cooldown has passed (or the previous probe expired).
+// KEYS[1]=state hash; ARGV[1]=probe timeout ms. Returns {allowed, probe, became_half_open}.
+var acquireScript = redis.NewScript(`
+if redis.call('TYPE', KEYS[1]).ok == 'string' then
+  -- breaker written by the old "open until" format
+  redis.call('DEL', KEYS[1], KEYS[1] .. ':attempts')
+end
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local v = redis.call('HMGET', KEYS[1], 'state', 'retry_at', 'probe_until')
+local state = v[1] or 'closed'
+if state == 'closed' then return {1, 0, 0} end
+if state == 'open' then
+  if now < tonumber(v[2] or '0') then return {0, 0, 0} end
+  redis.call('HSET', KEYS[1], 'state', 'half_open', 'probe_until', now + tonumber(ARGV[1]))
+  return {1, 1, 1}
+end
+if now < tonumber(v[3] or '0') then return {0, 0, 0} end
+redis.call('HSET', KEYS[1], 'probe_until', now + tonumber(ARGV[1]))
+return {1, 1, 0}
+`)
+
+// outcomeScript applies the result of a permitted call.
+// KEYS[1]=state hash, KEYS[2]=window hash
+// ARGV: 1=success|failure|release, 2=probe, 3=open immediately, 4=consecutive threshold,
+// 5=failure rate, 6=min samples, 7=window ms, 8=base backoff ms, 9=max backoff ms, 10=idle ttl s,
+// 11=minimum cooldown ms (the provider's Retry-After)
+// Returns {new state or "", backoff ms}.
+var outcomeScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HGET', KEYS[1], 'state') or 'closed'
+local probe = ARGV[2] == '1'
+local function open()
+  local opens = redis.call('HINCRBY', KEYS[1], 'opens', 1)
+  local backoff = math.min(tonumber(ARGV[9]), math.max(tonumber(ARGV[8]) * 2 ^ (opens - 1), tonumber(ARGV[11])))
+  redis.call('HSET', KEYS[1], 'state', 'open', 'retry_at', now + backoff, 'fails', 0)
+  redis.call('HDEL', KEYS[1], 'probe_until')
+  redis.call('EXPIRE', KEYS[1], ARGV[10])
+  redis.call('DEL', KEYS[2])
+  return {'open', backoff}
+end
+local function count(field)
+  local n = redis.call('HINCRBY', KEYS[2], field, 1)
+  if redis.call('PTTL', KEYS[2]) < 0 then redis.call('PEXPIRE', KEYS[2], ARGV[7]) end
+  return n
+end
+if ARGV[1] == 'release' then
+  if probe and state == 'half_open' then redis.call('HDEL', KEYS[1], 'probe_until') end
+  return {'', 0}
+end
+if ARGV[1] == 'success' then
+  if state == 'half_open' and probe then
+    redis.call('DEL', KEYS[1], KEYS[2])
+    return {'closed', 0}
+  end
+  if state == 'closed' then
+    redis.call('HSET', KEYS[1], 'fails', 0)
+    redis.call('EXPIRE', KEYS[1], ARGV[10])
+    count('ok')
+  end
+  return {'', 0}
+end
+-- failure
+if state == 'half_open' then
+  if probe then return open() end
+  return {'', 0}
+end
+if state ~= 'closed' then return {'', 0} end
+local fails = redis.call('HINCRBY', KEYS[1], 'fails', 1)
+redis.call('EXPIRE', KEYS[1], ARGV[10])
+local bad = count('fail')
+local good = tonumber(redis.call('HGET', KEYS[2], 'ok') or '0')
+local threshold, rate, samples = tonumber(ARGV[4]), tonumber(ARGV[5]), tonumber(ARGV[6])
+if ARGV[3] == '1' or (threshold > 0 and fails >= threshold)
+   or (rate > 0 and bad + good >= samples and bad / (bad + good) >= rate) then
+  return open()
+end
+return {'', 0}
+`)
+
+// Acquire asks the breaker of provider:model for permission to call it. While the breaker
+// is open nothing passes; once the cooldown is over exactly one worker (across all
+// replicas) gets a probe permit. If Redis is unreachable calls are allowed.
+func (a *Adaptive) Acquire(ctx context.Context, provider, model string) (Permit, bool) {
+    p := Permit{Provider: provider, Model: model}
+    res, err := acquireScript.Run(ctx, a.rdb, a.breakerKeys(provider, model)[:1], a.policy.ProbeTimeout.Milliseconds()).Int64Slice()
+    if err != nil || len(res) != 3 { return p, true }
+    if res[2] == 1 { a.transition(provider, model, StateHalfOpen) }
+    p.Probe = res[1] == 1
+    return p, res[0] == 1
+}
+
+// Success records a successful call; a successful probe closes the breaker.
+func (a *Adaptive) Success(ctx context.Context, p Permit) { a.outcome(ctx, p, "success", false, 0) }
+
+// Failure records a failed call. A failed probe reopens the breaker with a longer
+// cooldown; a closed breaker opens once the policy thresholds are reached, or right away
+// with immediate (e.g. on a 429). The cooldown is at least retryAfter, up to the maximum backoff.
+func (a *Adaptive) Failure(ctx context.Context, p Permit, immediate bool, retryAfter time.Duration) {
+    a.outcome(ctx, p, "failure", immediate, retryAfter)
+}
+
+// Release returns a permit whose call said nothing about the model's health (it was not
+// made, or the request itself was invalid), so a probe can be handed out again.
+func (a *Adaptive) Release(ctx context.Context, p Permit) {
+    if p.Probe { a.outcome(ctx, p, "release", false, 0) }
+}
+
+func (a *Adaptive) outcome(ctx context.Context, p Permit, kind string, immediate bool, minCooldown time.Duration) {
+    pol := a.policy
+    res, err := outcomeScript.Run(ctx, a.rdb, a.breakerKeys(p.Provider, p.Model), kind, boolArg(p.Probe), boolArg(immediate),
+        pol.ConsecutiveFailures, pol.FailureRate, pol.MinSamples, pol.Window.Milliseconds(),
+        a.baseBackoff.Milliseconds(), a.maxBackoff.Milliseconds(), int(breakerIdleTTL.Seconds()), minCooldown.Milliseconds()).Slice()
+    if err != nil || len(res) != 2 { return }
+    if to, _ := res[0].(string); to != "" { a.transition(p.Provider, p.Model, to) }
+}
+
+func (a *Adaptive) transition(provider, model, to string) {
+    if a.onTransition != nil { a.onTransition(provider, model, to) }
+}
+
+func boolArg(b bool) int {
+    if b { return 1 }
+    return 0
+}
+
+// Breakers lists every breaker that has state in Redis; models without an entry are closed.
+func (a *Adaptive) Breakers(ctx context.Context) ([]BreakerState, error) {
+    var out []BreakerState
+    iter := a.rdb.Scan(ctx, 0, "cb:*", 200).Iterator()
+    for iter.Next(ctx) {
+        key := iter.Val()
+        provider, model, ok := strings.Cut(strings.TrimPrefix(key, "cb:"), ":")
+        if !ok || strings.HasSuffix(key, ":attempts") { continue }
+        if t, err := a.rdb.Type(ctx, key).Result(); err != nil || t != "hash" { continue }
+        v, err := a.rdb.HGetAll(ctx, key).Result()
+        if err != nil { return nil, err }
+        if len(v) == 0 { continue }
+        st := BreakerState{Provider: provider, Model: model, State: v["state"], RetryAt: msTime(v["retry_at"]), ProbeUntil: msTime(v["probe_until"])}
+        if st.State == "" { st.State = StateClosed }
+        st.Opens, _ = strconv.Atoi(v["opens"])
+        st.Failures, _ = strconv.Atoi(v["fails"])
+        out = append(out, st)
+    }
+    return out, iter.Err()
+}
+
+// ResetBreaker closes the breaker of provider:model and clears its counters.
+func (a *Adaptive) ResetBreaker(ctx context.Context, provider, model string) error {
+    if provider == "" || model == "" { return fmt.Errorf("provider and model are required") }
+    if err := a.rdb.Del(ctx, a.breakerKeys(provider, model)...).Err(); err != nil { return err }
+    a.transition(provider, model, StateClosed)
+    return nil
+}
+
+func msTime(s string) *time.Time {
+    ms, err := strconv.ParseInt(s, 10, 64)
+    if err != nil || ms <= 0 { return nil }
+    t := time.UnixMilli(ms).UTC()
+    return &t
+}
@@ -0,0 +1,127 @@
+package limiter
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+// cooldown is the breaker backoff used by the tests; steps wait it out with "sleep".
+const cooldown = 20 * time.Millisecond
+
+func TestBreaker(t *testing.T) {
+    type step struct {
+        do      string // acquire|success|failure|429|release|sleep
+        allowed bool   // acquire: call permitted
+        probe   bool   // acquire: permit is the probe
+    }
+    tests := []struct {
+        name   string
+        policy BreakerPolicy
+        steps  []step
+        state  string // breaker state at the end
+        opens  int
+    }{
+        {
+            name:   "consecutive failures open",
+            policy: BreakerPolicy{ConsecutiveFailures: 3},
+            steps: []step{{do: "acquire", allowed: true}, {do: "failure"}, {do: "failure"}, {do: "acquire", allowed: true},
+                {do: "failure"}, {do: "acquire", allowed: false}},
+            state: StateOpen, opens: 1,
+        },
+        {
+            name:   "success resets the streak",
+            policy: BreakerPolicy{ConsecutiveFailures: 2},
+            steps:  []step{{do: "failure"}, {do: "success"}, {do: "failure"}, {do: "acquire", allowed: true}},
+            state:  StateClosed,
+        },
+        {
+            name:   "rate limit opens at once",
+            policy: BreakerPolicy{ConsecutiveFailures: 5},
+            steps:  []step{{do: "429"}, {do: "acquire", allowed: false}},
+            state:  StateOpen, opens: 1,
+        },
+        {
+            name:   "failure rate opens after min samples",
+            policy: BreakerPolicy{FailureRate: 0.5, MinSamples: 4},
+            steps:  []step{{do: "success"}, {do: "failure"}, {do: "success"}, {do: "acquire", allowed: true}, {do: "failure"}},
+            state:  StateOpen, opens: 1,
+        },
+        {
+            name:   "one probe after the cooldown",
+            policy: BreakerPolicy{ConsecutiveFailures: 1},
+            steps: []step{{do: "failure"}, {do: "sleep"}, {do: "acquire", allowed: true, probe: true},
+                {do: "acquire", allowed: false}},
+            state: StateHalfOpen, opens: 1,
+        },
+        {
+            name:   "successful probe closes",
+            policy: BreakerPolicy{ConsecutiveFailures: 1},
+            steps: []step{{do: "failure"}, {do: "sleep"}, {do: "acquire", allowed: true, probe: true}, {do: "success"},
+                {do: "acquire", allowed: true}},
+            state: StateClosed,
+        },
+        {
+            name:   "failed probe reopens with a longer cooldown",
+            policy: BreakerPolicy{ConsecutiveFailures: 1},
+            steps: []step{{do: "failure"}, {do: "sleep"}, {do: "acquire", allowed: true, probe: true}, {do: "failure"},
+                {do: "acquire", allowed: false}, {do: "sleep"}, {do: "acquire", allowed: false}},
+            state: StateOpen, opens: 2,
+        },
+        {
+            name:   "released probe is handed out again",
+            policy: BreakerPolicy{ConsecutiveFailures: 1},
+            steps: []step{{do: "failure"}, {do: "sleep"}, {do: "acquire", allowed: true, probe: true}, {do: "release"},
+                {do: "acquire", allowed: true, probe: true}},
+            state: StateHalfOpen, opens: 1,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx := context.Background()
+            tt.policy.ProbeTimeout = time.Minute
+            a := newTestLimiter(t, Options{BaseBackoff: cooldown, MaxBackoff: time.Minute, Breaker: tt.policy})
+            p := Permit{Provider: "openai", Model: "gpt"}
+            for i, s := range tt.steps {
+                switch s.do {
+                case "acquire":
+                    got, ok := a.Acquire(ctx, "openai", "gpt")
+                    if ok != s.allowed || got.Probe != s.probe {
+                        t.Fatalf("step %d: Acquire = allowed %v probe %v, want %v %v", i, ok, got.Probe, s.allowed, s.probe)
+                    }
+                    if ok { p = got }
+                case "success":
+                    a.Success(ctx, p)
+                case "failure":
+                    a.Failure(ctx, p, false, 0)
+                case "429":
+                    a.Failure(ctx, p, true, 0)
+                case "release":
+                    a.Release(ctx, p)
+                case "sleep":
+                    time.Sleep(cooldown + 5*time.Millisecond)
+                }
+            }
+            st := BreakerState{State: StateClosed}
+            list, err := a.Breakers(ctx)
+            if err != nil { t.Fatalf("Breakers: %v", err) }
+            for _, b := range list {
+                if b.Provider == "openai" && b.Model == "gpt" { st = b }
+            }
+            if st.State != tt.state || st.Opens != tt.opens {
+                t.Errorf("breaker = %s (opens %d), want %s (opens %d)", st.State, st.Opens, tt.state, tt.opens)
+            }
+        })
+    }
+}
+
+func TestBreakerCooldownHonoursRetryAfter(t *testing.T) {
+    ctx := context.Background()
+    a := newTestLimiter(t, Options{BaseBackoff: cooldown, MaxBackoff: time.Minute, Breaker: BreakerPolicy{ConsecutiveFailures: 5}})
+    a.Failure(ctx, Permit{Provider: "openai", Model: "gpt"}, true, 10*time.Second)
+    list, err := a.Breakers(ctx)
+    if err != nil || len(list) != 1 || list[0].RetryAt == nil { t.Fatalf("Breakers = %+v, %v", list, err) }
+    if d := time.Until(*list[0].RetryAt); d < 9*time.Second || d > 11*time.Second {
+        t.Errorf("cooldown %s, want about the 10s Retry-After", d)
+    }
+}
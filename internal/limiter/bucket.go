@@ -0,0 +1,139 @@
+package limiter
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    redis "github.com/redis/go-redis/v9"
+)
+
+// Budget is the per-minute allowance of one provider:model shared by every replica.
+// Zero means unlimited for that dimension.
+type Budget struct {
+    RPM int // requests per minute
+    TPM int // tokens per minute (input + output)
+}
+
+func (b Budget) unlimited() bool { return b.RPM <= 0 && b.TPM <= 0 }
+
+// Reservation is capacity taken from the shared buckets for one provider call.
+type Reservation struct {
+    Provider string
+    Model    string
+    Tokens   int
+    budget   Budget
+}
+
+// ErrNoBudget is returned by Wait when the buckets do not refill within the allowed wait.
+var ErrNoBudget = errors.New("rate budget exhausted")
+
+// bucketTTL outlives a full refill from the deepest debt (-capacity), after which an
+// absent key and a full bucket are the same thing.
+const bucketTTL = 3 * time.Minute
+
+// reserveScript takes 1 request and ARGV[3] tokens from the rpm/tpm buckets, both or
+// neither. Buckets hold up to one minute of budget and refill continuously.
+// KEYS[1]=rpm bucket, KEYS[2]=tpm bucket; ARGV[1]=rpm, ARGV[2]=tpm, ARGV[3]=tokens, ARGV[4]=ttl seconds
+// Returns {1, 0} when reserved, {0, wait_ms} otherwise.
+var reserveScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local function level(key, cap)
+  if cap <= 0 then return nil end
+  local v = redis.call('HMGET', key, 'tokens', 'ts')
+  local tokens, ts = tonumber(v[1]), tonumber(v[2])
+  if tokens == nil or ts == nil then return cap end
+  return math.min(cap, tokens + (now - ts) * cap / 60)
+end
+local rpm, tpm, need = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+if tpm > 0 and need > tpm then need = tpm end
+local r, k = level(KEYS[1], rpm), level(KEYS[2], tpm)
+local wait = 0
+if r and r < 1 then wait = math.max(wait, (1 - r) * 60 / rpm) end
+if k and k < need then wait = math.max(wait, (need - k) * 60 / tpm) end
+if wait > 0 then return {0, math.ceil(wait * 1000)} end
+if r then
+  redis.call('HSET', KEYS[1], 'tokens', r - 1, 'ts', now)
+  redis.call('EXPIRE', KEYS[1], ARGV[4])
+end
+if k then
+  redis.call('HSET', KEYS[2], 'tokens', k - need, 'ts', now)
+  redis.call('EXPIRE', KEYS[2], ARGV[4])
+end
+return {1, 0}
+`)
+
+// reconcileScript returns ARGV[2] tokens to the tpm bucket (negative takes more). The
+// bucket may go into debt down to -capacity, which delays the next reservations.
+// KEYS[1]=tpm bucket; ARGV[1]=tpm, ARGV[2]=delta, ARGV[3]=ttl seconds
+var reconcileScript = redis.NewScript(`
+local cap, delta = tonumber(ARGV[1]), tonumber(ARGV[2])
+local v = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens, ts = tonumber(v[1]), tonumber(v[2])
+if cap <= 0 or tokens == nil or ts == nil then return 0 end
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+tokens = math.min(cap, tokens + (now - ts) * cap / 60)
+tokens = math.max(-cap, math.min(cap, tokens + delta))
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+func (a *Adaptive) bucketKey(provider, model, kind string) string {
+    return fmt.Sprintf("rl:%s:%s:%s", strings.ToLower(provider), strings.ToLower(model), kind)
+}
+
+// Budget returns the configured allowance for provider:model.
+func (a *Adaptive) Budget(provider, model string) Budget {
+    if b, ok := a.budgets[strings.ToLower(provider)+":"+strings.ToLower(model)]; ok { return b }
+    return a.defaultBudget
+}
+
+// Reserve atomically takes one request and tokens from the shared buckets of
+// provider:model. When there is not enough budget it reserves nothing and returns how
+// long until there will be.
+func (a *Adaptive) Reserve(ctx context.Context, provider, model string, tokens int) (Reservation, time.Duration, error) {
+    b := a.Budget(provider, model)
+    res := Reservation{Provider: provider, Model: model, Tokens: tokens, budget: b}
+    if b.unlimited() { return res, 0, nil }
+    if b.TPM > 0 && tokens > b.TPM { res.Tokens = b.TPM }
+    keys := []string{a.bucketKey(provider, model, "rpm"), a.bucketKey(provider, model, "tpm")}
+    out, err := reserveScript.Run(ctx, a.rdb, keys, b.RPM, b.TPM, res.Tokens, int(bucketTTL.Seconds())).Int64Slice()
+    if err != nil { return Reservation{}, 0, err }
+    if len(out) != 2 { return Reservation{}, 0, fmt.Errorf("unexpected reserve reply: %v", out) }
+    if out[0] == 1 { return res, 0, nil }
+    return Reservation{}, time.Duration(out[1]) * time.Millisecond, nil
+}
+
+// Wait reserves like Reserve but sleeps while the buckets refill, as long as the total
+// wait stays within maxWait. Beyond that it returns ErrNoBudget without waiting, so the
+// caller can fail over to another model instead. The duration returned is the time spent waiting.
+func (a *Adaptive) Wait(ctx context.Context, provider, model string, tokens int, maxWait time.Duration) (Reservation, time.Duration, error) {
+    var waited time.Duration
+    for {
+        res, wait, err := a.Reserve(ctx, provider, model, tokens)
+        if err != nil || wait == 0 { return res, waited, err }
+        if waited+wait > maxWait { return Reservation{}, waited, ErrNoBudget }
+        t := time.NewTimer(wait)
+        select {
+        case <-ctx.Done():
+            t.Stop()
+            return Reservation{}, waited, ctx.Err()
+        case <-t.C:
+        }
+        waited += wait
+    }
+}
+
+// Reconcile corrects a reservation with the tokens the provider actually reported.
+func (a *Adaptive) Reconcile(ctx context.Context, res Reservation, actualTokens int) error {
+    if res.budget.TPM <= 0 { return nil }
+    delta := res.Tokens - actualTokens
+    if delta == 0 { return nil }
+    k := a.bucketKey(res.Provider, res.Model, "tpm")
+    return reconcileScript.Run(ctx, a.rdb, []string{k}, res.budget.TPM, delta, int(bucketTTL.Seconds())).Err()
+}
@@ -0,0 +1,79 @@
+package limiter
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/alicebob/miniredis/v2"
+)
+
+func newTestLimiter(t *testing.T, opts Options) *Adaptive {
+    t.Helper()
+    mr := miniredis.RunT(t)
+    opts.RedisURL = "redis://" + mr.Addr()
+    a, err := New(opts)
+    if err != nil { t.Fatalf("New: %v", err) }
+    t.Cleanup(func() { a.CloseClient() })
+    return a
+}
+
+func TestReserve(t *testing.T) {
+    tests := []struct {
+        name     string
+        budget   Budget
+        tokens   []int // one reservation each, in order
+        reserved []bool
+    }{
+        {name: "unlimited", budget: Budget{}, tokens: []int{1e6, 1e6, 1e6}, reserved: []bool{true, true, true}},
+        {name: "rpm", budget: Budget{RPM: 2}, tokens: []int{1, 1, 1}, reserved: []bool{true, true, false}},
+        {name: "tpm", budget: Budget{TPM: 1000}, tokens: []int{600, 300, 200}, reserved: []bool{true, true, false}},
+        {name: "both or neither", budget: Budget{RPM: 1, TPM: 1000}, tokens: []int{100, 100}, reserved: []bool{true, false}},
+        {name: "oversized request takes the whole bucket", budget: Budget{TPM: 1000}, tokens: []int{5000, 1}, reserved: []bool{true, false}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            a := newTestLimiter(t, Options{DefaultBudget: tt.budget})
+            for i, n := range tt.tokens {
+                _, wait, err := a.Reserve(context.Background(), "openai", "gpt", n)
+                if err != nil { t.Fatalf("reserve %d: %v", i, err) }
+                if got := wait == 0; got != tt.reserved[i] { t.Errorf("reserve %d: reserved=%v (wait %s), want %v", i, got, wait, tt.reserved[i]) }
+            }
+        })
+    }
+}
+
+func TestReconcile(t *testing.T) {
+    tests := []struct {
+        name     string
+        actual   int // tokens the provider reported for a 600 token reservation
+        next     int
+        reserved bool
+    }{
+        {name: "unused tokens go back", actual: 100, next: 800, reserved: true},
+        {name: "exact estimate", actual: 600, next: 500, reserved: false},
+        {name: "overrun goes into debt", actual: 1600, next: 1, reserved: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx := context.Background()
+            a := newTestLimiter(t, Options{Budgets: map[string]Budget{"OpenAI:GPT": {TPM: 1000}}})
+            res, wait, err := a.Reserve(ctx, "openai", "gpt", 600)
+            if err != nil || wait != 0 { t.Fatalf("first reserve: wait %s, err %v", wait, err) }
+            if err := a.Reconcile(ctx, res, tt.actual); err != nil { t.Fatalf("Reconcile: %v", err) }
+            _, wait, err = a.Reserve(ctx, "openai", "gpt", tt.next)
+            if err != nil { t.Fatalf("second reserve: %v", err) }
+            if got := wait == 0; got != tt.reserved { t.Errorf("reserved=%v (wait %s), want %v", got, wait, tt.reserved) }
+        })
+    }
+}
+
+func TestWaitGivesUp(t *testing.T) {
+    a := newTestLimiter(t, Options{DefaultBudget: Budget{RPM: 1}})
+    ctx := context.Background()
+    if _, _, err := a.Wait(ctx, "openai", "gpt", 1, time.Second); err != nil { t.Fatalf("first Wait: %v", err) }
+    // the next request refills in a minute, far beyond the allowed wait
+    if _, waited, err := a.Wait(ctx, "openai", "gpt", 1, time.Second); err != ErrNoBudget || waited != 0 {
+        t.Errorf("second Wait = %s, %v; want 0, ErrNoBudget", waited, err)
+    }
+}
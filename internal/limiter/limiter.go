@@ -2,7 +2,6 @@ package limiter
 
 import (
     "context"
-    "fmt"
     "strings"
     "sync"
     "time"
@@ -17,6 +16,10 @@ type Adaptive struct {
     maxBackoff  time.Duration
     mu         sync.Mutex
     sem        map[string]chan struct{}
+    budgets       map[string]Budget // "provider:model", lower case
+    defaultBudget Budget
+    policy        BreakerPolicy
+    onTransition  func(provider, model, state string)
 }
 
 type Options struct {
@@ -24,49 +27,28 @@ type Options struct {
     MaxInflight int
     BaseBackoff time.Duration
     MaxBackoff  time.Duration
+    // shared RPM/TPM buckets; models without an entry use DefaultBudget
+    Budgets       map[string]Budget
+    DefaultBudget Budget
+    Breaker       BreakerPolicy
+    // OnTransition is called when this process moves a breaker to another state
+    OnTransition func(provider, model, state string)
 }
 
 func New(opts Options) (*Adaptive, error) {
     if opts.MaxInflight <= 0 { opts.MaxInflight = 2 }
     if opts.BaseBackoff <= 0 { opts.BaseBackoff = 30 * time.Second }
     if opts.MaxBackoff <= 0 { opts.MaxBackoff = 5 * time.Minute }
+    if opts.Breaker.Window <= 0 { opts.Breaker.Window = time.Minute }
+    if opts.Breaker.ProbeTimeout <= 0 { opts.Breaker.ProbeTimeout = 2 * time.Minute }
     ro, err := redis.ParseURL(opts.RedisURL)
     if err != nil { return nil, err }
     c := redis.NewClient(ro)
     if err := c.Ping(context.Background()).Err(); err != nil { return nil, err }
-    return &Adaptive{rdb: c, maxInflight: opts.MaxInflight, baseBackoff: opts.BaseBackoff, maxBackoff: opts.MaxBackoff, sem: map[string]chan struct{}{}}, nil
-}
-
-func (a *Adaptive) key(provider, model string) string {
-    return fmt.Sprintf("cb:%s:%s", strings.ToLower(provider), strings.ToLower(model))
-}
-
-// IsOpen returns true if breaker is open (cooldown active).
-func (a *Adaptive) IsOpen(ctx context.Context, provider, model string) bool {
-    k := a.key(provider, model)
-    ts, err := a.rdb.Get(ctx, k).Int64()
-    if err != nil { return false }
-    return time.Now().Unix() < ts
-}
-
-// Open sets/extends the cooldown with exponential backoff per attempt.
-func (a *Adaptive) Open(ctx context.Context, provider, model string) {
-    k := a.key(provider, model)
-    cntKey := k + ":attempts"
-    // increment attempts and compute backoff
-    attempts, _ := a.rdb.Incr(ctx, cntKey).Result()
-    if attempts < 1 { attempts = 1 }
-    // backoff doubles up to maxBackoff
-    d := a.baseBackoff * (1 << (attempts - 1))
-    if d > a.maxBackoff { d = a.maxBackoff }
-    until := time.Now().Add(d).Unix()
-    _ = a.rdb.Set(ctx, k, until, d).Err()
-}
-
-// Close resets the breaker for provider/model.
-func (a *Adaptive) Close(ctx context.Context, provider, model string) {
-    k := a.key(provider, model)
-    _ = a.rdb.Del(ctx, k, k+":attempts").Err()
+    budgets := make(map[string]Budget, len(opts.Budgets))
+    for k, b := range opts.Budgets { budgets[strings.ToLower(k)] = b }
+    return &Adaptive{rdb: c, maxInflight: opts.MaxInflight, baseBackoff: opts.BaseBackoff, maxBackoff: opts.MaxBackoff, sem: map[string]chan struct{}{},
+        budgets: budgets, defaultBudget: opts.DefaultBudget, policy: opts.Breaker, onTransition: opts.OnTransition}, nil
 }
 
 // Allow tries to reserve a local in-process slot for provider:model.
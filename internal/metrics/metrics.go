@@ -67,11 +67,20 @@ var (
         prometheus.CounterOpts{
             Namespace: "aidispatcher",
             Name:      "breaker_events_total",
-            Help:      "Circuit breaker events by provider, model and action",
+            Help:      "Circuit breaker transitions by provider, model and action (open, half_open, closed)",
         },
         []string{"provider", "model", "action"},
     )
 
+    breakerState = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Namespace: "aidispatcher",
+            Name:      "breaker_state",
+            Help:      "Last breaker state seen by this process: 0 closed, 1 half-open, 2 open",
+        },
+        []string{"provider", "model"},
+    )
+
     queueDepth = prometheus.NewGaugeVec(
         prometheus.GaugeOpts{
             Namespace: "aidispatcher",
@@ -80,11 +89,103 @@ var (
         },
         []string{"type"},
     )
+
+    reclaimedTotal = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "aidispatcher",
+            Name:      "queue_reclaimed_total",
+            Help:      "Abandoned stream entries reclaimed, by outcome (redelivered, dlq)",
+        },
+        []string{"outcome"},
+    )
+
+    pagesRouted = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "aidispatcher",
+            Name:      "pages_routed_total",
+            Help:      "Pages assigned by page selection, by route (ai, mupdf) and reason",
+        },
+        []string{"route", "reason"},
+    )
+
+    rateLimitEvents = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "aidispatcher",
+            Name:      "ratelimit_events_total",
+            Help:      "Shared rate budget events by provider, model and event (waited, exhausted, error)",
+        },
+        []string{"provider", "model", "event"},
+    )
+
+    tokensTotal = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "aidispatcher",
+            Name:      "tokens_total",
+            Help:      "Tokens reported by providers, by provider, model and direction (input, output)",
+        },
+        []string{"provider", "model", "direction"},
+    )
+
+    costTotal = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "aidispatcher",
+            Name:      "cost_usd_total",
+            Help:      "Provider cost in USD at MODEL_PRICES, by provider and model",
+        },
+        []string{"provider", "model"},
+    )
+
+    clientTokens = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "aidispatcher",
+            Name:      "client_tokens_total",
+            Help:      "Tokens of recorded pages, by API client and direction (input, output)",
+        },
+        []string{"client_id", "direction"},
+    )
+
+    clientCost = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "aidispatcher",
+            Name:      "client_cost_usd_total",
+            Help:      "Cost in USD of recorded pages, by API client",
+        },
+        []string{"client_id"},
+    )
+
+    qualityScore = prometheus.NewHistogramVec(
+        prometheus.HistogramOpts{
+            Namespace: "aidispatcher",
+            Name:      "quality_score",
+            Help:      "Similarity of AI answers to the page text layer, by provider and model",
+            Buckets:   []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 0.95, 1},
+        },
+        []string{"provider", "model"},
+    )
+
+    qualityRejections = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "aidispatcher",
+            Name:      "quality_rejections_total",
+            Help:      "AI answers rejected by the quality guard, by provider, model and reason (empty, refusal, low_similarity)",
+        },
+        []string{"provider", "model", "reason"},
+    )
+
+    batchPages = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "aidispatcher",
+            Name:      "batch_pages_total",
+            Help:      "Pages of multi-page requests by outcome (accepted, fallback to a single-page call)",
+        },
+        []string{"outcome"},
+    )
 )
 
 // Init registers collectors.
 func Init() {
-    prometheus.MustRegister(providerReqs, providerLatency, pagesProcessed, retriesTotal, breakerEvents, queueDepth, pagesProcessedAttr, retriesAttr)
+    prometheus.MustRegister(providerReqs, providerLatency, pagesProcessed, retriesTotal, breakerEvents, breakerState, queueDepth, pagesProcessedAttr, retriesAttr, reclaimedTotal, pagesRouted, rateLimitEvents,
+        tokensTotal, costTotal, clientTokens, clientCost, qualityScore, qualityRejections, batchPages)
 }
 
 // Handler returns the http.Handler for /metrics
@@ -97,10 +198,47 @@ func ObserveProvider(provider, model, result string, dur time.Duration) {
 
 func IncProcessed(result string) { pagesProcessed.WithLabelValues(result).Inc() }
 func IncRetry()                  { retriesTotal.Inc() }
-func BreakerOpened(provider, model string) { breakerEvents.WithLabelValues(provider, model, "opened").Inc() }
-func BreakerClosed(provider, model string) { breakerEvents.WithLabelValues(provider, model, "closed").Inc() }
+
+// BreakerTransition records a breaker moving to state (closed, half_open, open).
+func BreakerTransition(provider, model, state string) {
+    breakerEvents.WithLabelValues(provider, model, state).Inc()
+    v := 0.0
+    switch state {
+    case "half_open":
+        v = 1
+    case "open":
+        v = 2
+    }
+    breakerState.WithLabelValues(provider, model).Set(v)
+}
 
 func SetQueueDepth(kind string, v int64) { queueDepth.WithLabelValues(kind).Set(float64(v)) }
+func IncReclaimed(outcome string)         { reclaimedTotal.WithLabelValues(outcome).Inc() }
+func AddPagesRouted(route, reason string, n int) { pagesRouted.WithLabelValues(route, reason).Add(float64(n)) }
+func IncRateLimit(provider, model, event string) { rateLimitEvents.WithLabelValues(provider, model, event).Inc() }
+
+// AddUsage records the tokens and cost of one provider call.
+func AddUsage(provider, model string, tokensIn, tokensOut int, costUSD float64) {
+    tokensTotal.WithLabelValues(provider, model, "input").Add(float64(tokensIn))
+    tokensTotal.WithLabelValues(provider, model, "output").Add(float64(tokensOut))
+    costTotal.WithLabelValues(provider, model).Add(costUSD)
+}
+
+// AddClientUsage attributes the usage of a recorded page to the API client that submitted it.
+func AddClientUsage(clientID string, tokensIn, tokensOut int, costUSD float64) {
+    clientTokens.WithLabelValues(clientID, "input").Add(float64(tokensIn))
+    clientTokens.WithLabelValues(clientID, "output").Add(float64(tokensOut))
+    clientCost.WithLabelValues(clientID).Add(costUSD)
+}
+
+// ObserveQuality records an answer's similarity to the text layer.
+func ObserveQuality(provider, model string, score float64) { qualityScore.WithLabelValues(provider, model).Observe(score) }
+
+// IncQualityRejection counts an answer the quality guard rejected.
+func IncQualityRejection(provider, model, reason string) { qualityRejections.WithLabelValues(provider, model, reason).Inc() }
+
+// AddBatchPages counts pages of a multi-page request by outcome.
+func AddBatchPages(outcome string, n int) { batchPages.WithLabelValues(outcome).Add(float64(n)) }
 
 func IncProcessedAttr(result, source string, fast bool) {
     pagesProcessedAttr.WithLabelValues(result, source, boolToStr(fast)).Inc()
@@ -0,0 +1,127 @@
+package mupdf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// PageStats describes what a page is made of; used to decide whether its text layer
+// is good enough or the page has to be read by a vision model.
+type PageStats struct {
+	Page          int     // 1-based
+	Text          string  // raw text layer
+	TextChars     int     // non-whitespace characters in the text layer
+	GarbageRatio  float64 // share of TextChars that are replacement, private-use or control glyphs
+	ImageCoverage float64 // share of the page area covered by raster images, 0..1
+	VectorPaths   int     // drawn paths (lines, shapes), glyph outlines excluded
+	WidthPt       float64
+	HeightPt      float64
+}
+
+// a4AreaPt is the area of an A4 page in points, used to normalise vector density.
+const a4AreaPt = 595.0 * 842.0
+
+// VectorDensity is VectorPaths scaled to an A4-sized page.
+func (s PageStats) VectorDensity() float64 {
+	area := s.WidthPt * s.HeightPt
+	if area <= 0 {
+		return float64(s.VectorPaths)
+	}
+	return float64(s.VectorPaths) * a4AreaPt / area
+}
+
+// AnalyzeDocument opens pdfPath and analyses every page.
+func (g *GoFitzExtractor) AnalyzeDocument(pdfPath string) ([]PageStats, error) {
+	doc, err := fitz.New(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF: %w", err)
+	}
+	defer doc.Close()
+
+	stats := make([]PageStats, 0, doc.NumPage())
+	for i := 1; i <= doc.NumPage(); i++ {
+		s, err := AnalyzeDocumentPage(doc, i)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+var (
+	imgStyleRe = regexp.MustCompile(`<img[^>]*style="([^"]*)"`)
+	widthRe    = regexp.MustCompile(`width:\s*([0-9.]+)pt`)
+	heightRe   = regexp.MustCompile(`height:\s*([0-9.]+)pt`)
+	// glyph outlines and clip paths are not drawings
+	svgDefsRe = regexp.MustCompile(`(?s)<symbol.*?</symbol>|<clipPath.*?</clipPath>`)
+)
+
+// AnalyzeDocumentPage analyses one page (1-based) of an open document.
+//
+// The text layer comes from MuPDF's structured text. Image coverage is read from the
+// image boxes in MuPDF's HTML output and vector paths are counted in its SVG output,
+// since go-fitz exposes no display list.
+func AnalyzeDocumentPage(doc *fitz.Document, pageNum int) (PageStats, error) {
+	idx := pageNum - 1
+	if idx < 0 || idx >= doc.NumPage() {
+		return PageStats{}, fmt.Errorf("page %d out of range (document has %d pages)", pageNum, doc.NumPage())
+	}
+	s := PageStats{Page: pageNum}
+
+	if b, err := doc.Bound(idx); err == nil {
+		s.WidthPt, s.HeightPt = float64(b.Dx()), float64(b.Dy())
+	}
+
+	text, err := doc.Text(idx)
+	if err != nil {
+		return s, fmt.Errorf("text page %d: %w", pageNum, err)
+	}
+	s.Text = text
+	garbage := 0
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		s.TextChars++
+		if r == unicode.ReplacementChar || unicode.Is(unicode.Co, r) || unicode.IsControl(r) {
+			garbage++
+		}
+	}
+	if s.TextChars > 0 {
+		s.GarbageRatio = float64(garbage) / float64(s.TextChars)
+	}
+
+	if html, err := doc.HTML(idx, false); err == nil {
+		area := s.WidthPt * s.HeightPt
+		var covered float64
+		for _, m := range imgStyleRe.FindAllStringSubmatch(html, -1) {
+			covered += styleLength(widthRe, m[1]) * styleLength(heightRe, m[1])
+		}
+		if area > 0 {
+			s.ImageCoverage = covered / area
+			if s.ImageCoverage > 1 {
+				s.ImageCoverage = 1
+			}
+		}
+	}
+
+	if svg, err := doc.SVG(idx); err == nil {
+		s.VectorPaths = strings.Count(svgDefsRe.ReplaceAllString(svg, ""), "<path")
+	}
+	return s, nil
+}
+
+func styleLength(re *regexp.Regexp, style string) float64 {
+	m := re.FindStringSubmatch(style)
+	if m == nil {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(m[1], 64)
+	return v
+}
@@ -0,0 +1,132 @@
+package mupdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/rs/zerolog/log"
+)
+
+// RenderOptions controls page rasterization
+type RenderOptions struct {
+	DPI         int    // target resolution (default 150)
+	Format      string // "png" or "jpeg" (default "png")
+	MaxPixels   int    // cap for the longest side in pixels; 0 disables the cap
+	JPEGQuality int    // 1-100, only used for jpeg (default 85)
+}
+
+// RenderedPage is an encoded page image ready to be sent to a vision model
+type RenderedPage struct {
+	Data   []byte
+	MIME   string
+	Width  int
+	Height int
+	DPI    float64
+}
+
+// RenderPage rasterizes a single page (1-based) of a PDF and encodes it as PNG or JPEG
+func (g *GoFitzExtractor) RenderPage(pdfPath string, pageNum int, opts RenderOptions) (*RenderedPage, error) {
+	doc, err := fitz.New(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF: %w", err)
+	}
+	defer doc.Close()
+
+	return RenderDocumentPage(doc, pageNum, opts)
+}
+
+// RenderDocumentPage rasterizes a page from an already opened document
+func RenderDocumentPage(doc *fitz.Document, pageNum int, opts RenderOptions) (*RenderedPage, error) {
+	if opts.DPI <= 0 {
+		opts.DPI = 150
+	}
+	if opts.JPEGQuality <= 0 || opts.JPEGQuality > 100 {
+		opts.JPEGQuality = 85
+	}
+	var format string
+	switch strings.ToLower(opts.Format) {
+	case "", "png":
+		format = "png"
+	case "jpg", "jpeg":
+		format = "jpeg"
+	default:
+		return nil, fmt.Errorf("unsupported render format %q", opts.Format)
+	}
+
+	// go-fitz uses 0-based indexing
+	pageIndex := pageNum - 1
+	if pageIndex < 0 || pageIndex >= doc.NumPage() {
+		return nil, fmt.Errorf("page %d out of range (document has %d pages)", pageNum, doc.NumPage())
+	}
+
+	// Page bounds are reported at 72 DPI; lower the DPI so the longest side fits MaxPixels
+	dpi := float64(opts.DPI)
+	if opts.MaxPixels > 0 {
+		if bound, err := doc.Bound(pageIndex); err == nil {
+			longest := bound.Dx()
+			if bound.Dy() > longest {
+				longest = bound.Dy()
+			}
+			if longest > 0 {
+				px := float64(longest) * dpi / 72.0
+				if px > float64(opts.MaxPixels) {
+					dpi = dpi * float64(opts.MaxPixels) / px
+				}
+			}
+		}
+	}
+
+	img, err := doc.ImageDPI(pageIndex, dpi)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render page %d: %w", pageNum, err)
+	}
+
+	var buf bytes.Buffer
+	mime := "image/png"
+	if format == "jpeg" {
+		mime = "image/jpeg"
+		err = jpeg.Encode(&buf, flatten(img), &jpeg.Options{Quality: opts.JPEGQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode page %d as %s: %w", pageNum, format, err)
+	}
+
+	log.Debug().
+		Int("page", pageNum).
+		Float64("dpi", dpi).
+		Int("width", img.Bounds().Dx()).
+		Int("height", img.Bounds().Dy()).
+		Int("bytes", buf.Len()).
+		Str("format", format).
+		Msg("Rendered page image")
+
+	return &RenderedPage{
+		Data:   buf.Bytes(),
+		MIME:   mime,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+		DPI:    dpi,
+	}, nil
+}
+
+// flatten drops the alpha channel (JPEG has none) by compositing onto white
+func flatten(src *image.RGBA) image.Image {
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	for i := 0; i+3 < len(src.Pix); i += 4 {
+		a := uint32(src.Pix[i+3])
+		for c := 0; c < 3; c++ {
+			// premultiplied RGBA: out = src + white*(1-a)
+			dst.Pix[i+c] = uint8(uint32(src.Pix[i+c]) + (255*(255-a))/255)
+		}
+		dst.Pix[i+3] = 255
+	}
+	return dst
+}
@@ -0,0 +1,53 @@
+package orchestrator
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+
+    "github.com/local/aidispatcher/internal/limiter"
+    "github.com/rs/zerolog/log"
+)
+
+// BreakerAdmin exposes the shared provider circuit breakers to operators.
+type BreakerAdmin interface {
+    Breakers(ctx context.Context) ([]limiter.BreakerState, error)
+    ResetBreaker(ctx context.Context, provider, model string) error
+}
+
+type breakerResetReq struct {
+    Provider string `json:"provider"`
+    Model    string `json:"model"`
+}
+
+// handleBreakers lists breaker states (GET) or force-closes one breaker (POST
+// {"provider","model"}), e.g. after a provider incident is resolved.
+func (o *Orchestrator) handleBreakers(w http.ResponseWriter, r *http.Request) {
+    if o.deps.Breakers == nil { http.Error(w, "breakers unavailable", http.StatusServiceUnavailable); return }
+    switch r.Method {
+    case http.MethodGet:
+        states, err := o.deps.Breakers.Breakers(r.Context())
+        if err != nil {
+            log.Error().Err(err).Msg("list breakers failed")
+            http.Error(w, "error retrieving breakers", http.StatusInternalServerError)
+            return
+        }
+        if states == nil { states = []limiter.BreakerState{} }
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(map[string]any{"breakers": states})
+    case http.MethodPost:
+        var req breakerResetReq
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "invalid json", http.StatusBadRequest); return }
+        if req.Provider == "" || req.Model == "" { http.Error(w, "missing provider/model", http.StatusBadRequest); return }
+        if err := o.deps.Breakers.ResetBreaker(r.Context(), req.Provider, req.Model); err != nil {
+            log.Error().Err(err).Str("provider", req.Provider).Str("model", req.Model).Msg("reset breaker failed")
+            http.Error(w, "reset failed", http.StatusInternalServerError)
+            return
+        }
+        log.Warn().Str("client_id", requestClientID(r)).Str("provider", req.Provider).Str("model", req.Model).Msg("breaker reset by admin")
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(map[string]any{"success": true, "provider": req.Provider, "model": req.Model, "state": limiter.StateClosed})
+    default:
+        w.WriteHeader(http.StatusMethodNotAllowed)
+    }
+}
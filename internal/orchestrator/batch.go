@@ -0,0 +1,38 @@
+package orchestrator
+
+import "fmt"
+
+// maxBatchPages caps page groups; vision models get less reliable with many images per request.
+const maxBatchPages = 4
+
+// batchSize returns the pages per AI request: the request's batch_pages, else the
+// configured default, at most maxBatchPages.
+func (o *Orchestrator) batchSize(requested int) int {
+    n := requested
+    if n <= 0 { n = o.deps.Selection.BatchPages }
+    if n > maxBatchPages { n = maxBatchPages }
+    if n < 1 { n = 1 }
+    return n
+}
+
+// groupPages splits pages into runs of consecutive page numbers of at most size pages, so
+// a group always covers a contiguous part of the document.
+func groupPages(pages []int, size int) [][]int {
+    var out [][]int
+    for _, p := range pages {
+        last := len(out) - 1
+        if last >= 0 && len(out[last]) < size && out[last][len(out[last])-1] == p-1 {
+            out[last] = append(out[last], p)
+            continue
+        }
+        out = append(out, []int{p})
+    }
+    return out
+}
+
+// idempotencyKey identifies a queue message; single pages keep the original key format so
+// a page the worker splits off a group is deduplicated against the same key.
+func idempotencyKey(jobID string, pages []int) string {
+    if len(pages) == 1 { return fmt.Sprintf("doc:%s:page:%d", jobID, pages[0]) }
+    return fmt.Sprintf("doc:%s:pages:%d-%d", jobID, pages[0], pages[len(pages)-1])
+}
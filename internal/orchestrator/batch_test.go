@@ -0,0 +1,52 @@
+package orchestrator
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestGroupPages(t *testing.T) {
+    tests := []struct {
+        name  string
+        pages []int
+        size  int
+        want  [][]int
+    }{
+        {name: "no pages", pages: nil, size: 3},
+        {name: "single pages", pages: []int{1, 2, 3}, size: 1, want: [][]int{{1}, {2}, {3}}},
+        {name: "full and partial groups", pages: []int{1, 2, 3, 4, 5}, size: 2, want: [][]int{{1, 2}, {3, 4}, {5}}},
+        {name: "gap starts a new group", pages: []int{1, 2, 4, 5, 6, 9}, size: 4, want: [][]int{{1, 2}, {4, 5, 6}, {9}}},
+        {name: "unsorted pages are not joined", pages: []int{3, 2, 1}, size: 4, want: [][]int{{3}, {2}, {1}}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := groupPages(tt.pages, tt.size); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("groupPages(%v, %d) = %v, want %v", tt.pages, tt.size, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestBatchSize(t *testing.T) {
+    tests := []struct {
+        requested, configured, want int
+    }{
+        {requested: 0, configured: 0, want: 1},
+        {requested: 0, configured: 3, want: 3},
+        {requested: 2, configured: 3, want: 2},
+        {requested: 10, configured: 3, want: maxBatchPages},
+        {requested: -1, configured: 10, want: maxBatchPages},
+    }
+    for _, tt := range tests {
+        o := &Orchestrator{}
+        o.deps.Selection.BatchPages = tt.configured
+        if got := o.batchSize(tt.requested); got != tt.want {
+            t.Errorf("batchSize(%d) with default %d = %d, want %d", tt.requested, tt.configured, got, tt.want)
+        }
+    }
+}
+
+func TestIdempotencyKey(t *testing.T) {
+    if got := idempotencyKey("j1", []int{4}); got != "doc:j1:page:4" { t.Errorf("single page key = %q", got) }
+    if got := idempotencyKey("j1", []int{4, 5, 6}); got != "doc:j1:pages:4-6" { t.Errorf("group key = %q", got) }
+}
@@ -0,0 +1,24 @@
+package orchestrator
+
+import (
+    "net/http"
+
+    "github.com/local/aidispatcher/internal/auth"
+)
+
+// requestClientID is the API client that made r; it is stamped into job metadata as "client_id".
+func requestClientID(r *http.Request) string {
+    if c, ok := auth.ClientFrom(r.Context()); ok { return c.ID }
+    return ""
+}
+
+// canAccess reports whether the caller may read or cancel the job. Clients see their own
+// jobs; admin keys see all. Jobs without an owner (created before API keys) are open to
+// any client holding the endpoint's scope.
+func canAccess(r *http.Request, st Status) bool {
+    c, ok := auth.ClientFrom(r.Context())
+    if !ok { return true }
+    if c.Has(auth.ScopeAdmin) { return true }
+    owner, _ := st.Metadata["client_id"].(string)
+    return owner == "" || owner == c.ID
+}
@@ -0,0 +1,27 @@
+package orchestrator
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/local/aidispatcher/internal/auth"
+)
+
+func TestCanAccess(t *testing.T) {
+    a, _ := auth.New(true, "")
+    ghost, _ := a.IssueKey("ghost", auth.ScopeRead)
+    admin, _ := a.IssueKey("ops", auth.ScopeAdmin)
+    owned := func(owner string) Status { return Status{Metadata: map[string]interface{}{"client_id": owner}} }
+
+    check := func(key string, st Status) (allowed bool) {
+        r := httptest.NewRequest(http.MethodGet, "/api/status/j1", nil)
+        r.Header.Set("X-API-Key", key)
+        a.Require(auth.ScopeRead, func(w http.ResponseWriter, r *http.Request) { allowed = canAccess(r, st) })(httptest.NewRecorder(), r)
+        return allowed
+    }
+    if !check(ghost, owned("ghost")) { t.Error("owner denied its own job") }
+    if check(ghost, owned("other")) { t.Error("client read another client's job") }
+    if !check(ghost, Status{}) { t.Error("job without an owner denied") }
+    if !check(admin, owned("other")) { t.Error("admin denied") }
+}
@@ -0,0 +1,26 @@
+package orchestrator
+
+// ContextConfig is the default cross-page context of jobs that do not choose themselves.
+type ContextConfig struct {
+    CarryOver bool
+    Tokens    int // budget for the previous page's tail in each page prompt
+}
+
+const (
+    defaultContextTokens = 200
+    // maxContextTokens keeps the context small next to the page image it accompanies.
+    maxContextTokens = 1000
+)
+
+// contextTokens resolves a request's carry_context/context_tokens against the defaults;
+// 0 means the job runs without cross-page context.
+func (o *Orchestrator) contextTokens(carry *bool, tokens int) int {
+    on := o.deps.Context.CarryOver
+    if carry != nil { on = *carry }
+    if !on { return 0 }
+    n := tokens
+    if n <= 0 { n = o.deps.Context.Tokens }
+    if n <= 0 { n = defaultContextTokens }
+    if n > maxContextTokens { n = maxContextTokens }
+    return n
+}
@@ -0,0 +1,239 @@
+package orchestrator
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/local/aidispatcher/internal/queue"
+    "github.com/rs/zerolog/log"
+)
+
+// DLQAdmin exposes the pages dead-letter queue to operators.
+type DLQAdmin interface {
+    ListDLQ(ctx context.Context, f queue.DLQFilter) ([]queue.DLQEntry, error)
+    GetDLQ(ctx context.Context, id string) (queue.DLQEntry, bool, error)
+    ReplayDLQ(ctx context.Context, f queue.DLQFilter, o queue.ReplayOptions) ([]queue.DLQEntry, error)
+    PurgeDLQ(ctx context.Context, f queue.DLQFilter) (int, error)
+}
+
+// defaultDLQListLimit bounds GET /admin/dlq without ?limit.
+const defaultDLQListLimit = 100
+
+// dlqSelector picks the entries a replay or purge applies to.
+type dlqSelector struct {
+    IDs    []string `json:"ids"`
+    JobID  string   `json:"job_id"`
+    Reason string   `json:"reason"`
+    Since  string   `json:"since"` // RFC3339 or a duration back from now, e.g. "24h"
+    Until  string   `json:"until"`
+    All    bool     `json:"all"` // required to select every entry
+}
+
+type dlqReplayReq struct {
+    dlqSelector
+    AIEngine  string `json:"ai_engine"`
+    ForceFast *bool  `json:"force_fast"`
+}
+
+type dlqPurgeReq struct {
+    dlqSelector
+    Confirm bool `json:"confirm"` // without it the purge only reports what it would delete
+}
+
+var errNoSelection = errors.New("select entries with ids, job_id, reason, since/until, or set all")
+
+func (s dlqSelector) filter(now time.Time) (queue.DLQFilter, error) {
+    f := queue.DLQFilter{IDs: s.IDs, JobID: s.JobID, Reason: s.Reason}
+    var err error
+    if f.Since, err = parseTimeArg(s.Since, now); err != nil { return f, fmt.Errorf("since: %w", err) }
+    if f.Until, err = parseTimeArg(s.Until, now); err != nil { return f, fmt.Errorf("until: %w", err) }
+    if f.Empty() && !s.All { return f, errNoSelection }
+    return f, nil
+}
+
+// parseTimeArg reads an RFC3339 time or a duration before now; "" is the zero time.
+func parseTimeArg(s string, now time.Time) (time.Time, error) {
+    if s == "" { return time.Time{}, nil }
+    if t, err := time.Parse(time.RFC3339, s); err == nil { return t, nil }
+    d, err := time.ParseDuration(s)
+    if err != nil || d < 0 { return time.Time{}, fmt.Errorf("%q is neither RFC3339 nor a duration like 24h", s) }
+    return now.Add(-d), nil
+}
+
+// handleDLQList lists DLQ entries (GET ?job_id=&reason=&since=&until=&limit=).
+func (o *Orchestrator) handleDLQList(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet { w.WriteHeader(http.StatusMethodNotAllowed); return }
+    if o.deps.DLQ == nil { http.Error(w, "dlq unavailable", http.StatusServiceUnavailable); return }
+    q := r.URL.Query()
+    sel := dlqSelector{JobID: q.Get("job_id"), Reason: q.Get("reason"), Since: q.Get("since"), Until: q.Get("until"), All: true}
+    f, err := sel.filter(time.Now())
+    if err != nil { http.Error(w, err.Error(), http.StatusBadRequest); return }
+    f.Limit = defaultDLQListLimit
+    if v := q.Get("limit"); v != "" {
+        if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 { http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest); return }
+    }
+    entries, err := o.deps.DLQ.ListDLQ(r.Context(), f)
+    if err != nil {
+        log.Error().Err(err).Msg("list dlq failed")
+        http.Error(w, "error retrieving dlq", http.StatusInternalServerError)
+        return
+    }
+    if entries == nil { entries = []queue.DLQEntry{} }
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(map[string]any{"entries": entries, "count": len(entries)})
+}
+
+// handleDLQ serves GET /admin/dlq/{id} (one entry with its queue message),
+// POST /admin/dlq/replay and POST /admin/dlq/purge.
+func (o *Orchestrator) handleDLQ(w http.ResponseWriter, r *http.Request) {
+    if o.deps.DLQ == nil { http.Error(w, "dlq unavailable", http.StatusServiceUnavailable); return }
+    switch id := strings.TrimPrefix(r.URL.Path, "/admin/dlq/"); id {
+    case "replay":
+        o.handleDLQReplay(w, r)
+    case "purge":
+        o.handleDLQPurge(w, r)
+    case "":
+        o.handleDLQList(w, r)
+    default:
+        if r.Method != http.MethodGet { w.WriteHeader(http.StatusMethodNotAllowed); return }
+        e, ok, err := o.deps.DLQ.GetDLQ(r.Context(), id)
+        if err != nil {
+            log.Error().Err(err).Str("id", id).Msg("get dlq entry failed")
+            http.Error(w, "error retrieving dlq entry", http.StatusInternalServerError)
+            return
+        }
+        if !ok { http.Error(w, "entry not found", http.StatusNotFound); return }
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(e)
+    }
+}
+
+func (o *Orchestrator) handleDLQReplay(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost { w.WriteHeader(http.StatusMethodNotAllowed); return }
+    var req dlqReplayReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "invalid json", http.StatusBadRequest); return }
+    f, err := req.filter(time.Now())
+    if err != nil { http.Error(w, err.Error(), http.StatusBadRequest); return }
+    entries, err := o.deps.DLQ.ListDLQ(r.Context(), f)
+    if err != nil {
+        log.Error().Err(err).Msg("list dlq failed")
+        http.Error(w, "error retrieving dlq", http.StatusInternalServerError)
+        return
+    }
+    ids, refused := o.replayable(r.Context(), entries)
+    replayed := []queue.DLQEntry{}
+    if len(ids) > 0 {
+        replayed, err = o.deps.DLQ.ReplayDLQ(r.Context(), queue.DLQFilter{IDs: ids}, queue.ReplayOptions{AIEngine: req.AIEngine, ForceFast: req.ForceFast})
+        if err != nil {
+            // entries replayed before the error stay replayed; report them with the error
+            log.Error().Err(err).Int("replayed", len(replayed)).Msg("dlq replay failed")
+            w.Header().Set("Content-Type", "application/json")
+            w.WriteHeader(http.StatusInternalServerError)
+            _ = json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "replayed": len(replayed), "entries": replayed, "refused": refused})
+            return
+        }
+        if replayed == nil { replayed = []queue.DLQEntry{} }
+    }
+    log.Warn().Str("client_id", requestClientID(r)).Int("replayed", len(replayed)).Int("refused", len(refused)).Str("job_id", req.JobID).Str("ai_engine", req.AIEngine).
+        Msg("dlq entries replayed by admin")
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(map[string]any{"replayed": len(replayed), "entries": replayed, "refused": refused})
+}
+
+// dlqRefusal is a DLQ entry a replay left in place.
+type dlqRefusal struct {
+    ID     string `json:"id"`
+    JobID  string `json:"job_id"`
+    PageID int    `json:"page_id"`
+    Reason string `json:"reason"`
+}
+
+// replayable splits entries into the IDs that can be replayed and those that cannot. A
+// page of a cancelled job would only be dropped by the worker, so it stays in the DLQ; so
+// does a page of a password-protected job whose password is gone (the job finished or the
+// secret expired): it can neither be read again nor have its job result re-encrypted.
+func (o *Orchestrator) replayable(ctx context.Context, entries []queue.DLQEntry) ([]string, []dlqRefusal) {
+    ids := make([]string, 0, len(entries))
+    refused := []dlqRefusal{}
+    reasons := map[string]string{} // per job
+    for _, e := range entries {
+        reason, seen := reasons[e.JobID]
+        if !seen {
+            reason = o.replayRefusal(ctx, e.JobID)
+            reasons[e.JobID] = reason
+        }
+        if reason != "" {
+            refused = append(refused, dlqRefusal{ID: e.ID, JobID: e.JobID, PageID: e.PageID, Reason: reason})
+            continue
+        }
+        ids = append(ids, e.ID)
+    }
+    return ids, refused
+}
+
+// replayRefusal says why pages of jobID cannot be replayed, "" when they can.
+func (o *Orchestrator) replayRefusal(ctx context.Context, jobID string) string {
+    cancelled, err := o.deps.Queue.IsCancelled(ctx, jobID)
+    if err != nil { return "cancel state unavailable: " + err.Error() }
+    if cancelled { return "job cancelled" }
+    st, ok, err := o.deps.Status.Get(ctx, jobID)
+    if err != nil || !ok { return "" }
+    if st.Status == "cancelled" { return "job cancelled" }
+    if _, err := o.jobPassword(ctx, st); err != nil { return "document password unavailable: " + err.Error() }
+    return ""
+}
+
+func (o *Orchestrator) handleDLQPurge(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost { w.WriteHeader(http.StatusMethodNotAllowed); return }
+    var req dlqPurgeReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "invalid json", http.StatusBadRequest); return }
+    f, err := req.filter(time.Now())
+    if err != nil { http.Error(w, err.Error(), http.StatusBadRequest); return }
+    w.Header().Set("Content-Type", "application/json")
+    if !req.Confirm {
+        entries, err := o.deps.DLQ.ListDLQ(r.Context(), f)
+        if err != nil {
+            log.Error().Err(err).Msg("list dlq failed")
+            http.Error(w, "error retrieving dlq", http.StatusInternalServerError)
+            return
+        }
+        _ = json.NewEncoder(w).Encode(map[string]any{"dry_run": true, "matched": len(entries)})
+        return
+    }
+    n, err := o.deps.DLQ.PurgeDLQ(r.Context(), f)
+    if err != nil {
+        log.Error().Err(err).Msg("dlq purge failed")
+        http.Error(w, "purge failed", http.StatusInternalServerError)
+        return
+    }
+    log.Warn().Str("client_id", requestClientID(r)).Int("purged", n).Str("job_id", req.JobID).Msg("dlq entries purged by admin")
+    _ = json.NewEncoder(w).Encode(map[string]any{"purged": n})
+}
+
+// applyReplayedPage handles the result of a page replayed from the DLQ after the page had
+// been completed with MuPDF text: recordPageDone already stored the new text, so the page
+// is charged and, when its job already finished, the job result is stored again.
+func (o *Orchestrator) applyReplayedPage(ctx context.Context, res queue.PageResult, prog PageProgress) error {
+    o.recordUsage(ctx, res)
+    st, ok, err := o.deps.Status.Get(ctx, res.JobID)
+    if err != nil { return fmt.Errorf("get status: %w", err) }
+    if !ok || st.Status != "success" {
+        log.Info().Str("job_id", res.JobID).Int("page_id", res.PageID).Msg("replayed page stored")
+        return nil
+    }
+    // the stored result was encrypted with the job's password; without it, keep that result
+    if _, err := o.jobPassword(ctx, st); err != nil {
+        log.Warn().Err(err).Str("job_id", res.JobID).Int("page_id", res.PageID).Msg("replayed page stored; job result not refreshed")
+        return nil
+    }
+    log.Info().Str("job_id", res.JobID).Int("page_id", res.PageID).Str("provider", res.Provider).Str("model", res.Model).
+        Msg("replayed page stored; refreshing job result")
+    // the job already has its terminal status, so there is no claim to take or release
+    return o.finalizeJob(ctx, res.JobID, prog)
+}
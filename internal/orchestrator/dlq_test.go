@@ -0,0 +1,54 @@
+package orchestrator
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/local/aidispatcher/internal/queue"
+)
+
+func TestReplayRefusesCancelledJobs(t *testing.T) {
+    ctx := context.Background()
+    e := newTestEnv(t, nil)
+    for _, job := range []string{"live", "cancelled", "stale-cancel"} {
+        e.startJob(t, job, 2, nil)
+        b := []byte(fmt.Sprintf(`{"job_id":%q,"page_id":1,"attempt":3}`, job))
+        if err := e.q.AddDLQ(ctx, b, "max_attempts", "timeout"); err != nil { t.Fatal(err) }
+    }
+    // one job is cancelled through the API, the other only shows it in its status
+    // (its cancel-set entry is gone)
+    rec := httptest.NewRecorder()
+    e.o.handleCancelJob(rec, httptest.NewRequest(http.MethodPost, "/webhook/cancel_job", strings.NewReader(`{"job_id":"cancelled"}`)))
+    if rec.Code != http.StatusOK { t.Fatalf("cancel: %d %s", rec.Code, rec.Body) }
+    st := e.jobStatus(t, "stale-cancel")
+    st.Status = "cancelled"
+    if err := e.status.Set(ctx, "stale-cancel", st); err != nil { t.Fatal(err) }
+
+    rec = httptest.NewRecorder()
+    e.o.handleDLQReplay(rec, httptest.NewRequest(http.MethodPost, "/admin/dlq/replay", strings.NewReader(`{"all":true}`)))
+    if rec.Code != http.StatusOK { t.Fatalf("replay: %d %s", rec.Code, rec.Body) }
+    var resp struct {
+        Replayed int              `json:"replayed"`
+        Refused  []dlqRefusal     `json:"refused"`
+        Entries  []queue.DLQEntry `json:"entries"`
+    }
+    if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil { t.Fatal(err) }
+    if resp.Replayed != 1 || resp.Entries[0].JobID != "live" { t.Errorf("replayed %d: %+v, want only the live job", resp.Replayed, resp.Entries) }
+    refused := map[string]string{}
+    for _, r := range resp.Refused { refused[r.JobID] = r.Reason }
+    if refused["cancelled"] != "job cancelled" || refused["stale-cancel"] != "job cancelled" || len(refused) != 2 {
+        t.Errorf("refused = %v", refused)
+    }
+
+    // the refused pages are still in the DLQ, and only the live one is queued again
+    left, err := e.q.ListDLQ(ctx, queue.DLQFilter{})
+    if err != nil || len(left) != 2 { t.Fatalf("DLQ after replay = %+v, %v", left, err) }
+    if _, data, _ := e.q.DequeueAI(ctx, "w1", time.Millisecond); !strings.Contains(string(data), `"live"`) { t.Errorf("queued %s, want the live page", data) }
+    if _, data, _ := e.q.DequeueAI(ctx, "w2", time.Millisecond); data != nil { t.Errorf("a refused page was queued: %s", data) }
+}
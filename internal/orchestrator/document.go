@@ -0,0 +1,108 @@
+package orchestrator
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/local/aidispatcher/internal/result"
+    "github.com/local/aidispatcher/internal/store"
+    "github.com/rs/zerolog/log"
+)
+
+// aiFailedWarning starts the warning of pages whose AI result was replaced by MuPDF text.
+const aiFailedWarning = "AI processing failed"
+
+// resultFormat resolves a request's result_format against the configured default.
+func (o *Orchestrator) resultFormat(requested string) string {
+    if requested != "" { return requested }
+    if def := o.deps.ResultFormat; def != "" && result.ValidFormat(def) == nil { return def }
+    return result.FormatText
+}
+
+// buildResultDocument assembles the result document of a job from its page records
+// (recs[i] is page i+1) and the aggregated text.
+func buildResultDocument(jobID string, st Status, recs []store.PageRecord, text string, usage store.Usage, finished time.Time) result.Document {
+    doc := result.Document{Schema: result.Schema, Version: result.Version, JobID: jobID, Mode: "ai", Text: text,
+        StartedAt: st.Start, FinishedAt: finished.UTC(), Pages: make([]result.Page, 0, len(recs))}
+    if mode, _ := st.Metadata["mode"].(string); mode != "" { doc.Mode = mode }
+    doc.Prompt, _ = st.Metadata["prompt"].(string)
+    doc.Source = result.Source{TotalPages: len(recs), FileName: uploadFileName(jobID, st.Metadata)}
+    doc.Source.FilePath, _ = st.Metadata["file_path"].(string)
+    if st.Start != nil { doc.DurationMS = finished.Sub(*st.Start).Milliseconds() }
+    doc.Summary.Selection = countsFromAny(st.Metadata["selection"])
+    for i, rec := range recs {
+        p := result.Page{Page: i + 1, Source: rec.Source, Provider: rec.Provider, Model: rec.Model,
+            PromptTemplate: rec.PromptTemplate, PromptVersion: rec.PromptVersion, PromptHash: rec.PromptHash,
+            TokensIn: rec.TokensIn, TokensOut: rec.TokensOut, CostUSD: rec.CostUSD, Quality: rec.Quality,
+            DurationMS: rec.DurationMS, Text: rec.Text}
+        if !rec.DoneAt.IsZero() {
+            t := rec.DoneAt.UTC()
+            p.CompletedAt = &t
+        }
+        if rec.Warning != "" { p.Warnings = append(p.Warnings, rec.Warning) }
+        switch rec.Source {
+        case result.SourceAI:
+            doc.Summary.AIPages++
+        case result.SourceMuPDF:
+            doc.Summary.MuPDFPages++
+            if strings.HasPrefix(rec.Warning, aiFailedWarning) { doc.Summary.FallbackPages++ }
+        case "":
+            doc.Summary.MissingPages++
+            p.Warnings = append(p.Warnings, "no result stored for this page")
+        }
+        if rec.Source != "" && strings.TrimSpace(rec.Text) == "" { p.Warnings = append(p.Warnings, "no text extracted") }
+        doc.Pages = append(doc.Pages, p)
+    }
+    if usage.Pages > 0 { doc.Usage = &result.Usage{TokensIn: usage.TokensIn, TokensOut: usage.TokensOut, CostUSD: usage.CostUSD} }
+    if n := doc.Summary.FallbackPages; n > 0 { doc.Warnings = append(doc.Warnings, fmt.Sprintf("%d page(s) use MuPDF text because AI processing failed", n)) }
+    if n := doc.Summary.MissingPages; n > 0 { doc.Warnings = append(doc.Warnings, fmt.Sprintf("%d page(s) have no result", n)) }
+    return doc
+}
+
+// storeResultDocument stores doc locally for upload jobs, else to S3 next to the plain text
+// result, or in its place when primary, and records the location in the job metadata.
+func storeResultDocument(ctx context.Context, meta map[string]any, doc result.Document, upload bool, originalRef string, primary bool, password string) error {
+    data, err := json.Marshal(doc)
+    if err != nil { return fmt.Errorf("encode result document: %w", err) }
+    if upload {
+        p, err := SaveResultDocumentToLocal(ctx, doc.JobID, data)
+        if err != nil { return err }
+        meta["result_json_local_path"] = p
+        log.Info().Str("job_id", doc.JobID).Str("result_path", p).Msg("result document stored locally")
+        return nil
+    }
+    s3url, err := SaveResultDocumentToS3(ctx, originalRef, doc.JobID, data, len(doc.Text), primary, password)
+    if err != nil { return err }
+    meta["result_json_s3_url"] = s3url
+    if primary { meta["result_s3_url"] = s3url }
+    log.Info().Str("job_id", doc.JobID).Str("result_json_s3_url", s3url).Msg("result document stored to S3")
+    return nil
+}
+
+// uploadFileName is the original name of an uploaded file; API jobs have none.
+func uploadFileName(jobID string, meta map[string]any) string {
+    if name, _ := meta["file_name"].(string); name != "" { return name }
+    local, _ := meta["file_local"].(string)
+    if local == "" { return "" }
+    return strings.TrimPrefix(filepath.Base(local), jobID+"_")
+}
+
+// countsFromAny reads a reason -> pages map from job metadata, which is map[string]any
+// after a round trip through the status store.
+func countsFromAny(v any) map[string]int {
+    switch m := v.(type) {
+    case map[string]int:
+        return m
+    case map[string]any:
+        out := make(map[string]int, len(m))
+        for k, n := range m {
+            if f, ok := n.(float64); ok { out[k] = int(f) }
+        }
+        return out
+    }
+    return nil
+}
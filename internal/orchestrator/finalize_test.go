@@ -0,0 +1,143 @@
+package orchestrator
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/alicebob/miniredis/v2"
+    "github.com/local/aidispatcher/internal/queue"
+    "github.com/local/aidispatcher/internal/store"
+)
+
+// testEnv is an orchestrator on the Redis-backed stores of a miniredis server. Upload jobs
+// store their results in a temporary RESULT_DIR.
+type testEnv struct {
+    o      *Orchestrator
+    mr     *miniredis.Miniredis
+    q      *queue.RedisQueue
+    status StatusStore
+}
+
+func newTestEnv(t *testing.T, wrap func(StatusStore) StatusStore) *testEnv {
+    t.Helper()
+    t.Setenv("RESULT_DIR", t.TempDir())
+    mr := miniredis.RunT(t)
+    url := "redis://" + mr.Addr()
+    q, err := queue.NewRedisQueue(url, "jobs:ai:pages", "workers", time.Hour)
+    if err != nil { t.Fatalf("NewRedisQueue: %v", err) }
+    rs, err := store.NewRedisStatus(url)
+    if err != nil { t.Fatalf("NewRedisStatus: %v", err) }
+    pages, err := store.NewPageStore(url)
+    if err != nil { t.Fatalf("NewPageStore: %v", err) }
+    t.Cleanup(func() { q.Close(); rs.Close(); pages.Close() })
+    status := NewStatusAdapter(rs)
+    if wrap != nil { status = wrap(status) }
+    o := New(Dependencies{Queue: q, Status: status, Pages: pages, DLQ: q})
+    return &testEnv{o: o, mr: mr, q: q, status: status}
+}
+
+// startJob registers an uploaded job of total pages that waits for its AI pages.
+func (e *testEnv) startJob(t *testing.T, jobID string, total int, meta map[string]any) {
+    t.Helper()
+    ctx := context.Background()
+    m := map[string]any{"source": "upload"}
+    for k, v := range meta { m[k] = v }
+    if err := e.status.Set(ctx, jobID, Status{Status: "processing", Message: "queued", Metadata: m}); err != nil { t.Fatalf("Set: %v", err) }
+    if err := e.status.InitPages(ctx, jobID, total); err != nil { t.Fatalf("InitPages: %v", err) }
+}
+
+func (e *testEnv) jobStatus(t *testing.T, jobID string) Status {
+    t.Helper()
+    st, ok, err := e.status.Get(context.Background(), jobID)
+    if err != nil || !ok { t.Fatalf("status of %s: %v, %v", jobID, ok, err) }
+    return st
+}
+
+// flakyStatus fails the next failSets status writes and failPages page completions, like a
+// Redis blip during finalize or page accounting.
+type flakyStatus struct {
+    StatusStore
+    failSets  int
+    failPages int
+}
+
+func (f *flakyStatus) CompletePage(ctx context.Context, jobID string, page int, failed bool, message string) (PageProgress, error) {
+    if f.failPages > 0 {
+        f.failPages--
+        return PageProgress{}, errors.New("connection reset")
+    }
+    return f.StatusStore.CompletePage(ctx, jobID, page, failed, message)
+}
+
+func (f *flakyStatus) Set(ctx context.Context, jobID string, st Status) error {
+    if f.failSets > 0 {
+        f.failSets--
+        return errors.New("connection reset")
+    }
+    return f.StatusStore.Set(ctx, jobID, st)
+}
+
+func TestFinalizeFailureIsRetried(t *testing.T) {
+    ctx := context.Background()
+    flaky := &flakyStatus{}
+    e := newTestEnv(t, func(s StatusStore) StatusStore { flaky.StatusStore = s; return flaky })
+    e.startJob(t, "job", 2, nil)
+    first := queue.PageResult{JobID: "job", PageID: 1, Status: queue.ResultDone, Text: "first page"}
+    last := queue.PageResult{JobID: "job", PageID: 2, Status: queue.ResultDone, Text: "second page"}
+    if err := e.o.recordPageDone(ctx, first); err != nil { t.Fatalf("page 1: %v", err) }
+
+    flaky.failSets = 1
+    if err := e.o.recordPageDone(ctx, last); err == nil { t.Fatalf("last page: finalize failure was not reported") }
+    if st := e.jobStatus(t, "job"); st.Status != "processing" { t.Fatalf("status after the failed finalize = %q", st.Status) }
+    // the failed finalizer gave its claim up; take it and hand it back for the retry
+    if ok, _ := e.status.ClaimFinalize(ctx, "job"); !ok { t.Fatalf("claim was not released after the failed finalize") }
+    if err := e.status.ReleaseFinalize(ctx, "job"); err != nil { t.Fatalf("ReleaseFinalize: %v", err) }
+
+    // the result consumer retries the same result, which now finalizes the job
+    if err := e.o.recordPageDone(ctx, last); err != nil { t.Fatalf("redelivered last page: %v", err) }
+    st := e.jobStatus(t, "job")
+    if st.Status != "success" || st.Metadata["result_local_path"] == nil { t.Fatalf("job after the retry = %s %v", st.Status, st.Metadata) }
+    // and only once
+    if err := e.o.recordPageDone(ctx, last); err != nil { t.Fatalf("duplicate: %v", err) }
+    if ok, _ := e.status.ClaimFinalize(ctx, "job"); ok { t.Errorf("a finished job could still be claimed") }
+}
+
+func TestFailedPageRetriesFinalize(t *testing.T) {
+    ctx := context.Background()
+    flaky := &flakyStatus{}
+    e := newTestEnv(t, func(s StatusStore) StatusStore { flaky.StatusStore = s; return flaky })
+    e.startJob(t, "job", 1, map[string]any{"file_local": "/nonexistent.pdf"})
+    failed := queue.PageResult{JobID: "job", PageID: 1, Status: queue.ResultFailed, Error: "max attempts"}
+    flaky.failSets = 1
+    if err := e.o.recordPageFailed(ctx, failed); err == nil { t.Fatalf("finalize failure was not reported") }
+    if err := e.o.recordPageFailed(ctx, failed); err != nil { t.Fatalf("redelivered failure: %v", err) }
+    if st := e.jobStatus(t, "job"); st.Status != "success" { t.Errorf("status = %q, want success", st.Status) }
+}
+
+func TestDeadLetteredPageFinalizesJob(t *testing.T) {
+    ctx := context.Background()
+    e := newTestEnv(t, nil)
+    e.startJob(t, "job", 2, map[string]any{"file_local": "/nonexistent.pdf"})
+    if err := e.o.recordPageDone(ctx, queue.PageResult{JobID: "job", PageID: 1, Status: queue.ResultDone, Text: "first page"}); err != nil {
+        t.Fatalf("page 1: %v", err)
+    }
+    // page 2 is read by a worker that keeps crashing before it acks
+    now := time.Now()
+    e.mr.SetTime(now)
+    if err := e.q.EnqueueAI(ctx, []byte(`{"job_id":"job","page_id":2,"attempt":1}`)); err != nil { t.Fatal(err) }
+    if _, data, err := e.q.DequeueAI(ctx, "w1", time.Millisecond); data == nil { t.Fatalf("DequeueAI: %v", err) }
+    e.mr.SetTime(now.Add(time.Hour))
+    e.q.StartReclaimer(queue.ReclaimOptions{Interval: 10 * time.Millisecond, MinIdle: time.Minute, MaxDeliveries: 1})
+
+    deadline := time.Now().Add(5 * time.Second)
+    for e.jobStatus(t, "job").Status == "processing" && time.Now().Before(deadline) {
+        msgID, data, err := e.q.DequeueResult(ctx, "orchestrator", 20*time.Millisecond)
+        if err != nil { t.Fatalf("DequeueResult: %v", err) }
+        if data != nil { e.o.handleResult(msgID, data) }
+    }
+    st := e.jobStatus(t, "job")
+    if st.Status != "success" { t.Fatalf("job = %s %q, want it finalized", st.Status, st.Message) }
+    if done, err := e.status.PageCompleted(ctx, "job", 2); !done { t.Errorf("page 2 not counted: %v", err) }
+}
@@ -0,0 +1,110 @@
+package orchestrator
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "time"
+
+    fitz "github.com/gen2brain/go-fitz"
+    "github.com/local/aidispatcher/internal/store"
+    "github.com/rs/zerolog/log"
+)
+
+// defaultLocalWorkers bounds concurrent MuPDF extractions when MUPDF_WORKERS is unset.
+const defaultLocalWorkers = 2
+
+// extractLocal completes the MuPDF-selected pages of a job in the background. At most
+// LocalWorkers documents are extracted at once so large text-only batches cannot starve
+// the API; Stop waits for running and queued extractions.
+func (o *Orchestrator) extractLocal(jobID, ref, password string, pages []int, infos []PageInfo) {
+    if len(pages) == 0 { return }
+    // keep only the text layers we need; AI pages are read by the workers
+    texts := make(map[int]string, len(pages))
+    for _, p := range infos { texts[p.Page] = p.Text }
+    keep := make(map[int]string, len(pages))
+    for _, p := range pages {
+        if t, ok := texts[p]; ok { keep[p] = t }
+    }
+    o.wg.Add(1)
+    go func() {
+        defer o.wg.Done()
+        o.local <- struct{}{}
+        defer func() { <-o.local }()
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+        defer cancel()
+        o.completeMuPDFPages(ctx, jobID, ref, password, pages, keep)
+    }()
+}
+
+// completeMuPDFPages stores the text layer of each page with source "mupdf" and records it
+// through the same CompletePage accounting as AI results, so whichever page completes last
+// (MuPDF or AI) finalizes the job. texts holds text already read during selection; other
+// pages are extracted from the document, which is opened at most once.
+func (o *Orchestrator) completeMuPDFPages(ctx context.Context, jobID, ref, password string, pages []int, texts map[int]string) {
+    var (
+        doc     *fitz.Document
+        tmp     string
+        openErr error
+        opened  bool
+    )
+    defer func() {
+        if doc != nil { doc.Close() }
+        if tmp != "" { os.Remove(tmp) }
+    }()
+    pageText := func(p int) (string, error) {
+        if t, ok := texts[p]; ok { return t, nil }
+        if !opened {
+            opened = true
+            var localPath string
+            localPath, tmp, openErr = ensureLocalPDF(ctx, ref, password)
+            if openErr == nil {
+                d, err := fitz.New(localPath)
+                if err != nil { openErr = fmt.Errorf("open pdf: %w", err) } else { doc = d }
+            }
+        }
+        if openErr != nil { return "", openErr }
+        if p < 1 || p > doc.NumPage() { return "", fmt.Errorf("page %d out of range", p) }
+        return doc.Text(p - 1)
+    }
+
+    start := time.Now()
+    done, failed := 0, 0
+    for _, p := range pages {
+        if o.jobCancelled(ctx, jobID) {
+            log.Info().Str("job_id", jobID).Int("pages_left", len(pages)-done-failed).Msg("job cancelled; stopping MuPDF extraction")
+            return
+        }
+        if completed, err := o.deps.Status.PageCompleted(ctx, jobID, p); err == nil && completed { continue }
+        txt, err := pageText(p)
+        msg := fmt.Sprintf("page %d extracted (MuPDF)", p)
+        if err != nil {
+            // still count the page so the job can finish; an empty page beats a stuck job
+            log.Warn().Err(err).Str("job_id", jobID).Int("page_id", p).Msg("MuPDF page text extraction failed")
+            msg = fmt.Sprintf("page %d failed (MuPDF)", p)
+        }
+        rec := store.PageRecord{Text: txt, Source: "mupdf"}
+        if err != nil { rec.Warning = "MuPDF text extraction failed: " + err.Error() }
+        if err := o.deps.Pages.SavePage(ctx, jobID, p, rec); err != nil {
+            log.Error().Err(err).Str("job_id", jobID).Int("page_id", p).Msg("save MuPDF page failed")
+            continue
+        }
+        prog, cerr := o.deps.Status.CompletePage(ctx, jobID, p, err != nil, msg)
+        if cerr != nil {
+            log.Error().Err(cerr).Str("job_id", jobID).Int("page_id", p).Msg("record MuPDF page failed")
+            continue
+        }
+        if err != nil { failed++ } else { done++ }
+        if prog.Finalize {
+            // a failed finalize leaves the claim released; the next completion takes it again
+            if err := o.finalize(ctx, jobID, prog); err != nil { log.Error().Err(err).Str("job_id", jobID).Msg("job not finalized") }
+        }
+    }
+    log.Info().Str("job_id", jobID).Int("pages_done", done).Int("pages_failed", failed).Dur("took", time.Since(start)).Msg("MuPDF pages extracted")
+}
+
+// jobCancelled reports whether the job was cancelled; lookup errors count as not cancelled.
+func (o *Orchestrator) jobCancelled(ctx context.Context, jobID string) bool {
+    st, ok, err := o.deps.Status.Get(ctx, jobID)
+    return err == nil && ok && st.Status == "cancelled"
+}
@@ -7,6 +7,17 @@ import (
     "path/filepath"
 )
 
+// SaveResultDocumentToLocal stores the JSON result document next to the text result and
+// returns its path.
+func SaveResultDocumentToLocal(ctx context.Context, jobID string, doc []byte) (string, error) {
+    dir := os.Getenv("RESULT_DIR")
+    if dir == "" { dir = filepath.Join("uploads", "results") }
+    if err := os.MkdirAll(dir, 0o755); err != nil { return "", err }
+    p := filepath.Join(dir, fmt.Sprintf("%s_result.json", jobID))
+    if err := os.WriteFile(p, doc, 0o644); err != nil { return "", err }
+    return p, nil
+}
+
 // SaveAggregatedTextToLocal stores aggregated text to a local results directory and
 // returns the local filesystem path. Directory defaults to ./uploads/results unless RESULT_DIR is set.
 func SaveAggregatedTextToLocal(ctx context.Context, jobID, text string) (string, error) {
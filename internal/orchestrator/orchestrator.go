@@ -10,18 +10,29 @@ import (
     "path/filepath"
     "strconv"
     "strings"
+    "sync"
     "time"
 
     "github.com/google/uuid"
+    "github.com/local/aidispatcher/internal/auth"
     "github.com/local/aidispatcher/internal/converter"
     "github.com/local/aidispatcher/internal/filetype"
     "github.com/local/aidispatcher/internal/mupdf"
+    "github.com/local/aidispatcher/internal/prompt"
+    "github.com/local/aidispatcher/internal/queue"
+    "github.com/local/aidispatcher/internal/result"
+    "github.com/local/aidispatcher/internal/store"
     "github.com/rs/zerolog/log"
 )
 
 type Queue interface {
     EnqueueAI(ctx context.Context, payload []byte) error
     CancelJob(ctx context.Context, jobID string) error
+    IsCancelled(ctx context.Context, jobID string) (bool, error)
+    // page outcomes from workers
+    DequeueResult(ctx context.Context, consumer string, timeout time.Duration) (string, []byte, error)
+    AckResult(ctx context.Context, msgID string) error
+    AddResultDLQ(ctx context.Context, payload []byte, reason string) error
 }
 
 type Status struct {
@@ -33,45 +44,92 @@ type Status struct {
     Metadata map[string]any
 }
 
+// PageProgress is the job state after a page completion was recorded atomically.
+type PageProgress struct {
+    Recorded bool // false for duplicate callbacks of an already completed page
+    Done     int
+    Failed   int
+    Total    int
+    Progress int
+    Finalize bool // the caller took the finalize claim and must finish the job
+}
+
 type StatusStore interface {
     Set(ctx context.Context, jobID string, st Status) error
     Get(ctx context.Context, jobID string) (Status, bool, error)
     SetFileJobMapping(ctx context.Context, fileID, jobID string) error
     GetJobByFileID(ctx context.Context, fileID string) (string, error)
+    // page accounting (atomic counters + per-page bitmap)
+    InitPages(ctx context.Context, jobID string, total int) error
+    CompletePage(ctx context.Context, jobID string, page int, failed bool, message string) (PageProgress, error)
+    PageCompleted(ctx context.Context, jobID string, page int) (bool, error)
+    // ClaimFinalize takes the job's single terminal transition (finalize or cancel);
+    // ReleaseFinalize gives it up when the owner could not write a terminal status
+    ClaimFinalize(ctx context.Context, jobID string) (bool, error)
+    ReleaseFinalize(ctx context.Context, jobID string) error
 }
 
 type Dependencies struct {
-    Queue     Queue
-    Status    StatusStore
-    Pages     PageStore
-    Converter *converter.LibreOffice
-    FileType  *filetype.Detector
+    Queue        Queue
+    Status       StatusStore
+    Pages        PageStore
+    Converter    *converter.LibreOffice
+    FileType     *filetype.Detector
+    Prompts      *prompt.Library
+    Secrets      SecretStore
+    Auth         *auth.Authenticator // nil disables API key checks
+    Selection    SelectionConfig
+    Context      ContextConfig
+    ResultFormat string              // default result_format: text|json|both
+    Breakers     BreakerAdmin        // nil disables /admin/breakers
+    Usage        UsageStore          // nil disables per-client usage counters and /admin/usage
+    DLQ          DLQAdmin            // nil disables /admin/dlq
 }
 
 type Orchestrator struct {
-    deps Dependencies
+    deps  Dependencies
+    stop  chan struct{}
+    wg    sync.WaitGroup
+    local chan struct{} // MuPDF extraction slots
 }
 
 func New(deps Dependencies) *Orchestrator {
-    return &Orchestrator{deps: deps}
+    if deps.Auth == nil { deps.Auth, _ = auth.New(false, "") }
+    workers := deps.Selection.LocalWorkers
+    if workers <= 0 { workers = defaultLocalWorkers }
+    return &Orchestrator{deps: deps, stop: make(chan struct{}), local: make(chan struct{}, workers)}
 }
 
 type PageStore interface {
     SavePageText(ctx context.Context, jobID string, page int, text, source, provider, model string) error
+    SavePage(ctx context.Context, jobID string, page int, rec store.PageRecord) error
     GetPageText(ctx context.Context, jobID string, page int) (string, error)
-    AggregateText(ctx context.Context, jobID string, total int) (string, error)
+    AggregateText(ctx context.Context, jobID string, total int, stitched bool) (string, error)
+    JobUsage(ctx context.Context, jobID string, total int) (store.Usage, error)
+    PageRecords(ctx context.Context, jobID string, total int) ([]store.PageRecord, error)
+}
+
+// UsageStore accumulates monthly provider usage per API client and user.
+type UsageStore interface {
+    Add(ctx context.Context, at time.Time, clientID, user string, u store.Usage) error
+    Report(ctx context.Context, month string) (store.UsageReport, error)
 }
 
 func (o *Orchestrator) RegisterRoutes(mux *http.ServeMux) {
     mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request){ w.WriteHeader(http.StatusOK); _,_ = w.Write([]byte("ok")) })
-    mux.HandleFunc("/process_file_junior_call", o.handleProcess)
-    mux.HandleFunc("/process_file_upload", o.handleProcessUpload)
-    mux.HandleFunc("/progress_spec/", o.handleProgress)
-    mux.HandleFunc("/download_result/", o.handleDownloadResult)
-    mux.HandleFunc("/internal/job_done", o.handleJobDone)
-    mux.HandleFunc("/webhook/cancel_job", o.handleCancelJob)
-    mux.HandleFunc("/internal/page_done", o.handlePageDone)
-    mux.HandleFunc("/internal/page_failed", o.handlePageFailed)
+    a := o.deps.Auth
+    mux.HandleFunc("/process_file_junior_call", a.Require(auth.ScopeSubmit, o.handleProcess))
+    mux.HandleFunc("/process_file_upload", a.Require(auth.ScopeSubmit, o.handleProcessUpload))
+    mux.HandleFunc("/progress_spec/", a.Require(auth.ScopeRead, o.handleProgress))
+    mux.HandleFunc("/download_result/", a.Require(auth.ScopeRead, o.handleDownloadResult))
+    mux.HandleFunc("/webhook/cancel_job", a.Require(auth.ScopeCancel, o.handleCancelJob))
+    mux.HandleFunc("/admin/breakers", a.Require(auth.ScopeAdmin, o.handleBreakers))
+    mux.HandleFunc("/admin/usage", a.Require(auth.ScopeAdmin, o.handleUsage))
+    mux.HandleFunc("/admin/dlq", a.Require(auth.ScopeAdmin, o.handleDLQList))
+    mux.HandleFunc("/admin/dlq/", a.Require(auth.ScopeAdmin, o.handleDLQ))
+    // /internal/* is for in-host callers only, never exposed
+    mux.HandleFunc("/internal/job_done", auth.LocalOnly(o.handleJobDone))
+    mux.HandleFunc("/internal/", auth.LocalOnly(http.NotFound))
 }
 
 type processReq struct {
@@ -81,9 +139,19 @@ type processReq struct {
     UserID     string                 `json:"user_id"`
     Password   string                 `json:"password"`
     AIPrompt   string                 `json:"ai_prompt"`
+    PromptTemplate string             `json:"prompt_template"`
+    PromptVersion  int                `json:"prompt_version"`
+    Language       string             `json:"language"`
+    TargetLanguage string             `json:"target_language"`
     AIEngine   string                 `json:"ai_engine"`
     TextOnly   bool                   `json:"text_only"`
     FastUpload bool                   `json:"fast_upload"`
+    PageSelection       string               `json:"page_selection"` // auto|ai|mupdf
+    SelectionThresholds *SelectionThresholds `json:"selection_thresholds"`
+    BatchPages          int                  `json:"batch_pages"` // pages per AI request; 0 = AI_BATCH_PAGES
+    CarryContext        *bool                `json:"carry_context"`  // previous page's tail in each prompt; nil = CONTEXT_CARRY
+    ContextTokens       int                  `json:"context_tokens"` // token budget of that tail; 0 = CONTEXT_CARRY_TOKENS
+    ResultFormat        string               `json:"result_format"`  // text|json|both; "" = RESULT_FORMAT
     Options    map[string]interface{} `json:"options"`
     Source     string                 `json:"source"`
 }
@@ -138,6 +206,18 @@ func (o *Orchestrator) handleProcess(w http.ResponseWriter, r *http.Request) {
     if filePath == "" || user == "" {
         http.Error(w, "missing file_path/file_url or user_name/user_id", http.StatusBadRequest); return
     }
+    ps := promptSpec{Custom: req.AIPrompt, Template: req.PromptTemplate, Version: req.PromptVersion, Language: req.Language, TargetLanguage: req.TargetLanguage,
+        ContextTokens: o.contextTokens(req.CarryContext, req.ContextTokens)}
+    if err := ps.resolve(o.deps.Prompts); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest); return
+    }
+    if err := validSelectionMode(req.PageSelection); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest); return
+    }
+    if err := result.ValidFormat(req.ResultFormat); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest); return
+    }
+    format := o.resultFormat(req.ResultFormat)
     if !strings.HasPrefix(filePath, "s3://") && !strings.HasPrefix(filePath, "http://") && !strings.HasPrefix(filePath, "https://") {
         bucket := os.Getenv("AWS_S3_BUCKET")
         if bucket == "" { bucket = "junior-files-dev" }
@@ -145,10 +225,25 @@ func (o *Orchestrator) handleProcess(w http.ResponseWriter, r *http.Request) {
     }
 
     jobID := uuid.NewString()
-    log.Info().Str("job_id", jobID).Str("file", filePath).Str("user", user).Msg("job created")
+    clientID := requestClientID(r)
+    log.Info().Str("job_id", jobID).Str("client_id", clientID).Str("file", filePath).Str("user", user).Str("prompt", ps.describe()).Int("ai_prompt_len", len(req.AIPrompt)).Bool("has_password", req.Password != "").Msg("job created")
+    // Text-only jobs run in this process and keep the password in memory; AI jobs hand it to
+    // workers and the finalizer through the secret store
+    secretRef := ""
+    if !req.TextOnly && !req.FastUpload {
+        ref, err := o.stashPassword(r.Context(), req.Password)
+        if err != nil {
+            log.Error().Err(err).Str("job_id", jobID).Msg("failed to store document password")
+            http.Error(w, "secret store unavailable", http.StatusServiceUnavailable)
+            return
+        }
+        secretRef = ref
+    }
     start := time.Now()
-    _ = o.deps.Status.Set(r.Context(), jobID, Status{Status: "queued", Progress: 0, Message: "queued", Start: &start,
-        Metadata: map[string]any{"file_path": filePath, "user": user}})
+    meta := map[string]any{"file_path": filePath, "user": user, "prompt": ps.describe(), "client_id": clientID, "result_format": format}
+    if ps.ContextTokens > 0 { meta["carry_context"] = true }
+    if secretRef != "" { meta[secretRefKey], meta[passwordProtectedKey] = secretRef, true }
+    _ = o.deps.Status.Set(r.Context(), jobID, Status{Status: "queued", Progress: 0, Message: "queued", Start: &start, Metadata: meta})
 
     // Extract file_id from S3 path and create file-to-job mapping
     // Ghost Server uses file_id (with or without _original suffix) to check progress
@@ -177,7 +272,7 @@ func (o *Orchestrator) handleProcess(w http.ResponseWriter, r *http.Request) {
         log.Info().Str("job_id", jobID).Str("file", processedPath).Bool("text_only", req.TextOnly).Bool("fast_upload", req.FastUpload).Msg("Processing with MuPDF text-only mode (S3)")
 
         // Download file from S3, convert if needed, then process with MuPDF
-        go o.processMuPDFOnlyFromS3(context.Background(), jobID, processedPath, user, req.Password)
+        go o.processMuPDFOnlyFromS3(context.Background(), jobID, processedPath, user, clientID, req.Password, format)
 
         w.Header().Set("Content-Type", "application/json")
         w.WriteHeader(http.StatusCreated)
@@ -185,17 +280,24 @@ func (o *Orchestrator) handleProcess(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // Odredi broj stranica (pdfcpu) i napravi selekciju
-    pages, err := DetermineTotalPages(r.Context(), processedPath)
-    if err != nil {
-        log.Warn().Err(err).Str("file", filePath).Msg("page count failed; defaulting to 4")
-        pages = 4
+    // Analiza stranica (MuPDF) i selekcija MuPDF vs AI
+    pages, sel, infos := o.planPages(r.Context(), jobID, processedPath, req.Password, req.PageSelection, req.SelectionThresholds, req.TextOnly, 4)
+    log.Info().Str("job_id", jobID).Str("file", filePath).Int("total_pages", pages).Int("ai_pages", len(sel.AIPages)).
+        Int("mupdf_pages", len(sel.MuPDFPages)).Interface("reasons", sel.Reasons).Msg("orchestrator allocated pages")
+    // brojači i status moraju postojati prije enqueuea – workeri mogu završiti stranice odmah
+    if err := o.deps.Status.InitPages(r.Context(), jobID, pages); err != nil {
+        log.Error().Err(err).Str("job_id", jobID).Msg("init page counters failed")
+        http.Error(w, "status store unavailable", http.StatusServiceUnavailable)
+        return
     }
-    log.Info().Str("job_id", jobID).Str("file", filePath).Int("total_pages", pages).Msg("orchestrator detected page count")
-    sel := SelectPages(SelectionOptions{TextOnly: req.TextOnly, TotalPages: pages})
-    log.Info().Str("job_id", jobID).Int("ai_pages", len(sel.AIPages)).Int("mupdf_pages", len(sel.MuPDFPages)).Msg("orchestrator allocated pages")
+    meta["total_pages"] = pages
+    meta["ai_pages"] = len(sel.AIPages)
+    meta["mupdf_pages"] = len(sel.MuPDFPages)
+    meta["selection"] = sel.Reasons
+    _ = o.deps.Status.Set(r.Context(), jobID, Status{Status: "processing", Progress: 0, Message: "enqueued AI pages", Start: &start, Metadata: meta})
     // enqueue AI stranice
-    for _, p := range sel.AIPages {
+    for _, g := range groupPages(sel.AIPages, o.batchSize(req.BatchPages)) {
+        p := g[0]
         payload := map[string]any{
             "job_id": jobID,
             "file_path": filePath,
@@ -204,9 +306,12 @@ func (o *Orchestrator) handleProcess(w http.ResponseWriter, r *http.Request) {
             "user": user,
             "ai_engine": req.AIEngine,
             "text_only": req.TextOnly,
-            "idempotency_key": fmt.Sprintf("doc:%s:page:%d", jobID, p),
+            "idempotency_key": idempotencyKey(jobID, g),
             "attempt": 1,
         }
+        if len(g) > 1 { payload["pages"] = g }
+        ps.apply(payload, pages)
+        if secretRef != "" { payload[secretRefKey] = secretRef }
         if req.Source != "" { payload["source"] = req.Source } else { payload["source"] = "api" }
         data, _ := json.Marshal(payload)
         if err := o.deps.Queue.EnqueueAI(r.Context(), data); err != nil {
@@ -214,11 +319,11 @@ func (o *Orchestrator) handleProcess(w http.ResponseWriter, r *http.Request) {
             http.Error(w, "queue unavailable", http.StatusServiceUnavailable)
             return
         }
-        log.Info().Str("job_id", jobID).Int("page_id", p).Str("ai_engine", req.AIEngine).Msg("enqueued page for AI")
+        log.Info().Str("job_id", jobID).Int("page_id", p).Ints("pages", g).Str("ai_engine", req.AIEngine).Msg("enqueued page for AI")
     }
-    // update status
-    _ = o.deps.Status.Set(r.Context(), jobID, Status{Status: "processing", Progress: 10, Message: "enqueued AI pages",
-        Metadata: map[string]any{"total_pages": pages, "ai_pages": len(sel.AIPages), "mupdf_pages": len(sel.MuPDFPages), "pages_done": 0, "pages_failed": 0}})
+    // MuPDF stranice se obrađuju lokalno u pozadini (finalizacija može uključivati upload na S3)
+    o.extractLocal(jobID, processedPath, req.Password, sel.MuPDFPages, infos)
+    if len(sel.AIPages)+len(sel.MuPDFPages) == 0 { o.finalizeEmptyJob(r.Context(), jobID) }
 
     resp := processResp{
         Status:  "ok",
@@ -247,6 +352,28 @@ func (o *Orchestrator) handleProcessUpload(w http.ResponseWriter, r *http.Reques
     if user == "" { http.Error(w, "missing user_name", http.StatusBadRequest); return }
     aiEngine := r.FormValue("ai_engine")
     textOnly := r.FormValue("text_only") == "on" || r.FormValue("text_only") == "true"
+    promptVersion, _ := strconv.Atoi(r.FormValue("prompt_version"))
+    ps := promptSpec{Custom: strings.TrimSpace(r.FormValue("ai_prompt")), Template: r.FormValue("prompt_template"), Version: promptVersion,
+        Language: r.FormValue("language"), TargetLanguage: r.FormValue("target_language")}
+    if v := r.FormValue("carry_context"); v != "" {
+        carry := v == "on" || v == "true"
+        ps.ContextTokens = o.contextTokens(&carry, 0)
+    } else {
+        ps.ContextTokens = o.contextTokens(nil, 0)
+    }
+    if err := ps.resolve(o.deps.Prompts); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest); return
+    }
+    selMode := r.FormValue("page_selection")
+    batchPages, _ := strconv.Atoi(r.FormValue("batch_pages"))
+    if err := validSelectionMode(selMode); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest); return
+    }
+    if err := result.ValidFormat(r.FormValue("result_format")); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest); return
+    }
+    format := o.resultFormat(r.FormValue("result_format"))
+    clientID := requestClientID(r)
 
     // Persist upload to local storage
     uploadDir := os.Getenv("UPLOAD_DIR")
@@ -265,7 +392,7 @@ func (o *Orchestrator) handleProcessUpload(w http.ResponseWriter, r *http.Reques
     // Initialize status
     start := time.Now()
     _ = o.deps.Status.Set(r.Context(), jobID, Status{Status: "queued", Progress: 0, Message: "queued",
-        Start: &start, Metadata: map[string]any{"file_local": localPath, "user": user, "source": "upload"}})
+        Start: &start, Metadata: map[string]any{"file_local": localPath, "user": user, "client_id": clientID, "source": "upload", "prompt": ps.describe()}})
 
     // Detect file type
     fileInfo, err := o.deps.FileType.Detect(localPath)
@@ -288,7 +415,7 @@ func (o *Orchestrator) handleProcessUpload(w http.ResponseWriter, r *http.Reques
     if fileInfo.MIMEType != "application/pdf" && !fileInfo.IsText {
         log.Info().Str("job_id", jobID).Str("file", localPath).Msg("converting to PDF with LibreOffice")
         _ = o.deps.Status.Set(r.Context(), jobID, Status{Status: "processing", Progress: 5, Message: "converting to PDF",
-            Start: &start, Metadata: map[string]any{"file_local": localPath, "user": user, "source": "upload", "original_mime": fileInfo.MIMEType}})
+            Start: &start, Metadata: map[string]any{"file_local": localPath, "user": user, "client_id": clientID, "source": "upload", "original_mime": fileInfo.MIMEType}})
 
         convertedPath := filepath.Join(filepath.Dir(localPath), fmt.Sprintf("%s_converted.pdf", jobID))
         convJob := converter.Job{
@@ -302,7 +429,7 @@ func (o *Orchestrator) handleProcessUpload(w http.ResponseWriter, r *http.Reques
         if !result.Success {
             log.Error().Str("job_id", jobID).Str("error", result.Error).Msg("conversion failed")
             _ = o.deps.Status.Set(r.Context(), jobID, Status{Status: "failed", Progress: 0, Message: fmt.Sprintf("conversion failed: %s", result.Error),
-                Start: &start, End: &start, Metadata: map[string]any{"file_local": localPath, "user": user, "source": "upload", "error": result.Error}})
+                Start: &start, End: &start, Metadata: map[string]any{"file_local": localPath, "user": user, "client_id": clientID, "source": "upload", "error": result.Error}})
             http.Error(w, fmt.Sprintf("conversion failed: %s", result.Error), http.StatusInternalServerError)
             return
         }
@@ -316,7 +443,7 @@ func (o *Orchestrator) handleProcessUpload(w http.ResponseWriter, r *http.Reques
         log.Info().Str("job_id", jobID).Str("file", pdfPath).Msg("Processing with MuPDF text-only mode")
 
         // Process asynchronously with MuPDF (use background context to avoid cancellation when request ends)
-        go o.processMuPDFOnly(context.Background(), jobID, pdfPath)
+        go o.processMuPDFOnly(context.Background(), jobID, pdfPath, name, clientID, format)
 
         w.Header().Set("Content-Type", "application/json")
         w.WriteHeader(http.StatusCreated)
@@ -326,17 +453,21 @@ func (o *Orchestrator) handleProcessUpload(w http.ResponseWriter, r *http.Reques
 
     // Page count and selection for AI mode
     fileRef := "file://" + pdfPath
-    pages, err := DetermineTotalPages(r.Context(), fileRef)
-    if err != nil {
-        log.Warn().Err(err).Str("file", fileRef).Msg("upload page count failed; defaulting to 1")
-        pages = 1
+    pages, sel, infos := o.planPages(r.Context(), jobID, fileRef, "", selMode, nil, textOnly, 1)
+    log.Info().Str("job_id", jobID).Str("file", fileRef).Int("total_pages", pages).Int("ai_pages", len(sel.AIPages)).
+        Int("mupdf_pages", len(sel.MuPDFPages)).Interface("reasons", sel.Reasons).Msg("orchestrator allocated upload pages")
+    if err := o.deps.Status.InitPages(r.Context(), jobID, pages); err != nil {
+        log.Error().Err(err).Str("job_id", jobID).Msg("init page counters failed")
+        http.Error(w, "status store unavailable", http.StatusServiceUnavailable)
+        return
     }
-    log.Info().Str("job_id", jobID).Str("file", fileRef).Int("total_pages", pages).Msg("orchestrator detected upload page count")
-    sel := SelectPages(SelectionOptions{TextOnly: textOnly, TotalPages: pages})
-    log.Info().Str("job_id", jobID).Int("ai_pages", len(sel.AIPages)).Int("mupdf_pages", len(sel.MuPDFPages)).Msg("orchestrator allocated upload pages")
+    _ = o.deps.Status.Set(r.Context(), jobID, Status{Status: "processing", Progress: 0, Message: "enqueued AI pages", Start: &start,
+        Metadata: map[string]any{"total_pages": pages, "ai_pages": len(sel.AIPages), "mupdf_pages": len(sel.MuPDFPages), "selection": sel.Reasons, "file_local": localPath, "user": user, "client_id": clientID, "source": "upload", "prompt": ps.describe(),
+            "carry_context": ps.ContextTokens > 0, "file_name": name, "result_format": format}})
 
     // Enqueue AI pages
-    for _, p := range sel.AIPages {
+    for _, g := range groupPages(sel.AIPages, o.batchSize(batchPages)) {
+        p := g[0]
         payload := map[string]any{
             "job_id": jobID,
             "file_path": fileRef,
@@ -346,40 +477,46 @@ func (o *Orchestrator) handleProcessUpload(w http.ResponseWriter, r *http.Reques
             "ai_engine": aiEngine,
             "text_only": textOnly,
             "source": "upload",
-            "idempotency_key": fmt.Sprintf("doc:%s:page:%d", jobID, p),
+            "idempotency_key": idempotencyKey(jobID, g),
             "attempt": 1,
         }
+        if len(g) > 1 { payload["pages"] = g }
+        ps.apply(payload, pages)
         data, _ := json.Marshal(payload)
         if err := o.deps.Queue.EnqueueAI(r.Context(), data); err != nil {
             http.Error(w, "queue unavailable", http.StatusServiceUnavailable); return
         }
-        log.Info().Str("job_id", jobID).Int("page_id", p).Str("ai_engine", aiEngine).Msg("enqueued upload page for AI")
+        log.Info().Str("job_id", jobID).Int("page_id", p).Ints("pages", g).Str("ai_engine", aiEngine).Msg("enqueued upload page for AI")
     }
-
-    _ = o.deps.Status.Set(r.Context(), jobID, Status{Status: "processing", Progress: 10,
-        Message: "enqueued AI pages", Metadata: map[string]any{"total_pages": pages, "ai_pages": len(sel.AIPages), "mupdf_pages": len(sel.MuPDFPages), "pages_done": 0, "pages_failed": 0, "file_local": localPath, "source": "upload"}})
+    o.extractLocal(jobID, fileRef, "", sel.MuPDFPages, infos)
+    if len(sel.AIPages)+len(sel.MuPDFPages) == 0 { o.finalizeEmptyJob(r.Context(), jobID) }
 
     w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(http.StatusCreated)
     _ = json.NewEncoder(w).Encode(processResp{Status: "ok", JobID: jobID, Message: "Upload job created"})
 }
 
-// handleDownloadResult serves the aggregated text for upload-origin jobs as a file download.
+// handleDownloadResult serves the aggregated text for upload-origin jobs as a file download;
+// ?format=json serves the result document of jobs that produced one.
 func (o *Orchestrator) handleDownloadResult(w http.ResponseWriter, r *http.Request) {
     id := strings.TrimPrefix(r.URL.Path, "/download_result/")
     st, ok, err := o.deps.Status.Get(r.Context(), id)
-    if err != nil || !ok { http.Error(w, "not found", http.StatusNotFound); return }
+    if err != nil || !ok || !canAccess(r, st) { http.Error(w, "not found", http.StatusNotFound); return }
     if st.Status != "success" { http.Error(w, "not ready", http.StatusAccepted); return }
     // Only for upload-origin jobs
     if st.Metadata == nil || st.Metadata["source"] != "upload" {
         http.Error(w, "not an upload job", http.StatusBadRequest); return
     }
-    p, _ := st.Metadata["result_local_path"].(string)
+    key, contentType, name := "result_local_path", "text/plain; charset=utf-8", fmt.Sprintf("extracted_text_%s.txt", id)
+    if r.URL.Query().Get("format") == result.FormatJSON {
+        key, contentType, name = "result_json_local_path", "application/json", fmt.Sprintf("result_%s.json", id)
+    }
+    p, _ := st.Metadata[key].(string)
     if p == "" { http.Error(w, "result not available", http.StatusNotFound); return }
     b, err := os.ReadFile(p)
     if err != nil { http.Error(w, "failed to read", 500); return }
-    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=extracted_text_%s.txt", id))
+    w.Header().Set("Content-Type", contentType)
+    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", name))
     w.Write(b)
 }
 
@@ -410,7 +547,7 @@ func (o *Orchestrator) handleProgress(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "error retrieving status", 500)
         return
     }
-    if !ok {
+    if !ok || !canAccess(r, st) {
         http.Error(w, "job or file not found", http.StatusNotFound)
         return
     }
@@ -424,7 +561,7 @@ func (o *Orchestrator) handleProgress(w http.ResponseWriter, r *http.Request) {
         "message":    st.Message,
         "start_time": st.Start,
         "end_time":   st.End,
-        "metadata":   st.Metadata,
+        "metadata":   publicMetadata(st.Metadata),
     })
 }
 
@@ -440,124 +577,184 @@ func (o *Orchestrator) handleJobDone(w http.ResponseWriter, r *http.Request) {
     st.Progress = 100
     st.Message = "completed"
     st.End = &now
+    o.wipeSecret(r.Context(), jobID, &st)
     _ = o.deps.Status.Set(r.Context(), jobID, st)
     log.Info().Str("job_id", jobID).Msg("job marked done via webhook")
     w.WriteHeader(http.StatusNoContent)
 }
 
-func (o *Orchestrator) handlePageDone(w http.ResponseWriter, r *http.Request) {
-    if r.Method != http.MethodPost { w.WriteHeader(http.StatusMethodNotAllowed); return }
-    jobID := r.URL.Query().Get("job_id")
-    pageIDStr := r.URL.Query().Get("page_id")
-    if jobID == "" || pageIDStr == "" { http.Error(w, "missing job_id/page_id", 400); return }
-    // Parse optional body with text/provider/model
-    var body struct{ Text string `json:"text"`; Provider string `json:"provider"`; Model string `json:"model"` }
-    _ = json.NewDecoder(r.Body).Decode(&body)
-    // Save page text if provided
-    pageNum, _ := strconv.Atoi(pageIDStr)
-    if body.Text != "" {
-        _ = o.deps.Pages.SavePageText(r.Context(), jobID, pageNum, body.Text, "ai", body.Provider, body.Model)
-    }
-    st, ok, err := o.deps.Status.Get(r.Context(), jobID)
-    if err != nil || !ok { w.WriteHeader(http.StatusNoContent); return }
-    if st.Metadata == nil { st.Metadata = map[string]any{} }
-    // update metadata counts
-    done := intFromMeta(st.Metadata, "pages_done") + 1
-    failed := intFromMeta(st.Metadata, "pages_failed")
-    total := intFromMeta(st.Metadata, "total_pages")
-    st.Metadata["pages_done"] = done
-    // progress
-    if total > 0 { st.Progress = int(float64(done+failed) / float64(total) * 100) }
-    st.Message = fmt.Sprintf("page %s done", pageIDStr)
-    log.Info().Str("job_id", jobID).Int("page_id", pageNum).Int("pages_done", done).Int("pages_failed", failed).Int("total_pages", total).Str("provider", body.Provider).Str("model", body.Model).Msg("page completed")
-    // If all pages accounted, aggregate and mark success
-    if total > 0 && done+failed >= total {
-        agg, _ := o.deps.Pages.AggregateText(r.Context(), jobID, total)
-        if st.Metadata == nil { st.Metadata = map[string]any{} }
-        st.Metadata["result_text_len"] = len(agg)
-        // Save result depending on source
-        if src, _ := st.Metadata["source"].(string); src == "upload" {
-            if localPath, err := SaveAggregatedTextToLocal(r.Context(), jobID, agg); err == nil {
-                st.Metadata["result_local_path"] = localPath
-                log.Info().Str("job_id", jobID).Str("result_path", localPath).Msg("aggregated result stored locally")
-            }
-        } else {
-            // Save to S3 (encrypted)
-            filePath, _ := st.Metadata["file_path"].(string)
-            password, _ := st.Metadata["password"].(string)
-            if s3url, err := SaveAggregatedTextToS3(r.Context(), filePath, jobID, agg, password); err == nil {
-                st.Metadata["result_s3_url"] = s3url
-                log.Info().Str("job_id", jobID).Str("result_s3_url", s3url).Msg("aggregated result stored to S3")
-            }
+// recordPageDone stores the AI text of a page and records its completion.
+func (o *Orchestrator) recordPageDone(ctx context.Context, res queue.PageResult) error {
+    // Save page text before recording completion so the finalizer always sees it
+    if res.Text != "" {
+        if err := o.deps.Pages.SavePage(ctx, res.JobID, res.PageID, store.PageRecord{Text: res.Text, Source: "ai", Provider: res.Provider, Model: res.Model,
+            PromptTemplate: res.PromptTemplate, PromptVersion: res.PromptVersion, PromptHash: res.PromptHash,
+            TokensIn: res.TokensIn, TokensOut: res.TokensOut, CostUSD: res.CostUSD, Quality: res.Quality, DurationMS: res.DurationMS}); err != nil {
+            return fmt.Errorf("save page: %w", err)
         }
-        st.Status = "success"
-        st.Progress = 100
-        // Cleanup stale temp files older than 1h as part of job completion hygiene
-        CleanupTemps(1 * time.Hour)
-        log.Info().Str("job_id", jobID).Int("pages_done", done).Int("pages_failed", failed).Msg("job completed")
     }
-    _ = o.deps.Status.Set(r.Context(), jobID, st)
-    w.WriteHeader(http.StatusNoContent)
+    prog, err := o.deps.Status.CompletePage(ctx, res.JobID, res.PageID, false, fmt.Sprintf("page %d done", res.PageID))
+    if err != nil { return fmt.Errorf("record page completion: %w", err) }
+    // a repeated completion can still be handed the finalize claim of a finalizer that failed
+    if !prog.Recorded && !prog.Finalize {
+        // a page replayed from the DLQ replaces the MuPDF fallback text of a finished job
+        if res.Replay { return o.applyReplayedPage(ctx, res, prog) }
+        log.Info().Str("job_id", res.JobID).Int("page_id", res.PageID).Msg("duplicate page completion ignored")
+        return nil
+    }
+    if prog.Recorded {
+        log.Info().Str("job_id", res.JobID).Int("page_id", res.PageID).Int("pages_done", prog.Done).Int("pages_failed", prog.Failed).Int("total_pages", prog.Total).Str("provider", res.Provider).Str("model", res.Model).Msg("page completed")
+        o.recordUsage(ctx, res)
+    }
+    if prog.Finalize { return o.finalize(ctx, res.JobID, prog) }
+    return nil
 }
 
-func (o *Orchestrator) handlePageFailed(w http.ResponseWriter, r *http.Request) {
-    if r.Method != http.MethodPost { w.WriteHeader(http.StatusMethodNotAllowed); return }
-    jobID := r.URL.Query().Get("job_id")
-    pageIDStr := r.URL.Query().Get("page_id")
-    if jobID == "" || pageIDStr == "" { http.Error(w, "missing job_id/page_id", 400); return }
-    st, ok, err := o.deps.Status.Get(r.Context(), jobID)
-    if err != nil || !ok { w.WriteHeader(http.StatusNoContent); return }
-    // increment failed
-    if st.Metadata == nil { st.Metadata = map[string]any{} }
-    done := intFromMeta(st.Metadata, "pages_done")
-    failed := intFromMeta(st.Metadata, "pages_failed") + 1
-    total := intFromMeta(st.Metadata, "total_pages")
-    st.Metadata["pages_failed"] = failed
+// recordPageFailed falls back to MuPDF text for a page the AI gave up on.
+func (o *Orchestrator) recordPageFailed(ctx context.Context, res queue.PageResult) error {
+    // A page that already completed (e.g. AI result arrived first) must not be overwritten by
+    // MuPDF text; it only goes through CompletePage again for a finalize claim left behind
+    if completed, err := o.deps.Status.PageCompleted(ctx, res.JobID, res.PageID); err == nil && completed {
+        return o.recordPageFailure(ctx, res)
+    }
+    st, ok, err := o.deps.Status.Get(ctx, res.JobID)
+    if err != nil { return fmt.Errorf("get status: %w", err) }
+    if !ok { return nil }
     // Extract MuPDF text for this page and save
-    pageNum, _ := strconv.Atoi(pageIDStr)
     filePath, _ := st.Metadata["file_path"].(string)
-    if filePath == "" { filePath = jobID }
-    if txt, err := ExtractPageText(r.Context(), filePath, pageNum); err == nil {
-        _ = o.deps.Pages.SavePageText(r.Context(), jobID, pageNum, txt, "mupdf", "", "")
-    }
-    if total > 0 { st.Progress = int(float64(done+failed) / float64(total) * 100) }
-    st.Message = fmt.Sprintf("page %s failed (fallback to MuPDF)", pageIDStr)
-    log.Warn().Str("job_id", jobID).Int("page_id", pageNum).Int("pages_done", done).Int("pages_failed", failed).Int("total_pages", total).Msg("page failed; MuPDF fallback")
-    // If all pages accounted, aggregate and mark success
-    if total > 0 && done+failed >= total {
-        agg, _ := o.deps.Pages.AggregateText(r.Context(), jobID, total)
-        st.Metadata["result_text_len"] = len(agg)
-        // Save result depending on source
-        if src, _ := st.Metadata["source"].(string); src == "upload" {
-            if localPath, err := SaveAggregatedTextToLocal(r.Context(), jobID, agg); err == nil {
-                st.Metadata["result_local_path"] = localPath
-                log.Info().Str("job_id", jobID).Str("result_path", localPath).Msg("job completed with fallback (local)")
-            }
-        } else {
-            filePath, _ := st.Metadata["file_path"].(string)
-            password, _ := st.Metadata["password"].(string)
-            if s3url, err := SaveAggregatedTextToS3(r.Context(), filePath, jobID, agg, password); err == nil {
-                st.Metadata["result_s3_url"] = s3url
-                log.Info().Str("job_id", jobID).Str("result_s3_url", s3url).Msg("job completed with fallback (S3)")
-            }
-        }
-        st.Status = "success"
-        st.Progress = 100
-        log.Info().Str("job_id", jobID).Int("pages_done", done).Int("pages_failed", failed).Msg("job completed after fallback")
+    if filePath == "" { filePath, _ = st.Metadata["file_local"].(string); if filePath != "" { filePath = "file://" + filePath } }
+    if filePath == "" { filePath = res.JobID }
+    // the secret is still there: it is wiped only when the job finalizes
+    password, err := o.jobPassword(ctx, st)
+    if err != nil { log.Warn().Err(err).Str("job_id", res.JobID).Msg("document password unavailable for MuPDF fallback") }
+    if txt, err := ExtractPageText(ctx, filePath, password, res.PageID); err == nil {
+        warning := aiFailedWarning
+        if res.Error != "" { warning += ": " + res.Error }
+        _ = o.deps.Pages.SavePage(ctx, res.JobID, res.PageID, store.PageRecord{Text: txt, Source: "mupdf", Warning: warning})
+    } else {
+        log.Warn().Err(err).Str("job_id", res.JobID).Int("page_id", res.PageID).Msg("MuPDF fallback extraction failed")
     }
-    _ = o.deps.Status.Set(r.Context(), jobID, st)
-    w.WriteHeader(http.StatusNoContent)
+    return o.recordPageFailure(ctx, res)
+}
+
+// recordPageFailure counts a page the AI gave up on and finalizes the job when it holds the claim.
+func (o *Orchestrator) recordPageFailure(ctx context.Context, res queue.PageResult) error {
+    prog, err := o.deps.Status.CompletePage(ctx, res.JobID, res.PageID, true, fmt.Sprintf("page %d failed (fallback to MuPDF)", res.PageID))
+    if err != nil { return fmt.Errorf("record page failure: %w", err) }
+    if prog.Recorded {
+        log.Warn().Str("job_id", res.JobID).Int("page_id", res.PageID).Int("pages_done", prog.Done).Int("pages_failed", prog.Failed).Int("total_pages", prog.Total).Str("error", res.Error).Msg("page failed; MuPDF fallback")
+    } else if !prog.Finalize {
+        log.Info().Str("job_id", res.JobID).Int("page_id", res.PageID).Msg("duplicate page failure ignored")
+    }
+    if prog.Finalize { return o.finalize(ctx, res.JobID, prog) }
+    return nil
 }
 
-func intFromMeta(m map[string]any, key string) int {
-    if m == nil { return 0 }
-    if v, ok := m[key]; ok {
-        switch t := v.(type) {
-        case float64: return int(t)
-        case int: return t
+// finalizeEmptyJob finishes a job without pages to process (an empty document): no page
+// completion would ever finalize it.
+func (o *Orchestrator) finalizeEmptyJob(ctx context.Context, jobID string) {
+    if ok, err := o.deps.Status.ClaimFinalize(ctx, jobID); err != nil || !ok {
+        if err != nil { log.Error().Err(err).Str("job_id", jobID).Msg("finalize: claim failed") }
+        return
+    }
+    log.Info().Str("job_id", jobID).Msg("job has no pages to process; finalizing")
+    if err := o.finalize(ctx, jobID, PageProgress{}); err != nil { log.Error().Err(err).Str("job_id", jobID).Msg("empty job not finalized") }
+}
+
+// finalize runs finalizeJob for the holder of the job's finalize claim. When it fails the
+// claim is released, so the same page result, delivered again, can finalize the job.
+func (o *Orchestrator) finalize(ctx context.Context, jobID string, prog PageProgress) error {
+    err := o.finalizeJob(ctx, jobID, prog)
+    if err == nil { return nil }
+    if rerr := o.deps.Status.ReleaseFinalize(ctx, jobID); rerr != nil {
+        log.Error().Err(rerr).Str("job_id", jobID).Msg("finalize: claim not released; it expires with its lease")
+    }
+    return fmt.Errorf("finalize job: %w", err)
+}
+
+// finalizeJob aggregates page texts, stores the result and marks the job successful, or
+// failed when the result could not be stored, so a job never reports a result it has not. Only
+// the holder of the finalize claim (see CompletePage and ClaimFinalize) calls it, so a job
+// cancelled first never gets here. It returns an error when the job status could not be
+// read or written; the job then has no terminal status yet.
+func (o *Orchestrator) finalizeJob(ctx context.Context, jobID string, prog PageProgress) error {
+    st, ok, err := o.deps.Status.Get(ctx, jobID)
+    if err != nil { return fmt.Errorf("get status: %w", err) }
+    if !ok { return fmt.Errorf("job status missing") }
+    if st.Metadata == nil { st.Metadata = map[string]any{} }
+    // jobs with cross-page context get their page breaks stitched
+    stitched, _ := st.Metadata["carry_context"].(bool)
+    agg, err := o.deps.Pages.AggregateText(ctx, jobID, prog.Total, stitched)
+    if err != nil { return fmt.Errorf("aggregate page texts: %w", err) }
+    st.Metadata["result_text_len"] = len(agg)
+    u, err := o.deps.Pages.JobUsage(ctx, jobID, prog.Total)
+    if err != nil {
+        log.Warn().Err(err).Str("job_id", jobID).Msg("finalize: job usage unavailable")
+    } else if u.Pages > 0 {
+        st.Metadata["usage"] = u
+    }
+    now := time.Now()
+    format, _ := st.Metadata["result_format"].(string)
+    var doc *result.Document
+    if result.WantsDocument(format) {
+        if recs, err := o.deps.Pages.PageRecords(ctx, jobID, prog.Total); err != nil {
+            // the plain text still gets stored, at the usual location
+            log.Error().Err(err).Str("job_id", jobID).Msg("finalize: page records unavailable; storing plain text only")
+            format = result.FormatText
+        } else {
+            d := buildResultDocument(jobID, st, recs, agg, u, now)
+            doc = &d
+        }
+    }
+    // Save result depending on source; uploads always keep the text for /download_result
+    upload := st.Metadata["source"] == "upload"
+    filePath, _ := st.Metadata["file_path"].(string)
+    password := ""
+    var storeErr error // the result the client asked for is (partly) missing
+    if upload {
+        if localPath, err := SaveAggregatedTextToLocal(ctx, jobID, agg); err != nil {
+            storeErr = fmt.Errorf("save result locally: %w", err)
+        } else {
+            st.Metadata["result_local_path"] = localPath
+            log.Info().Str("job_id", jobID).Str("result_path", localPath).Msg("aggregated result stored locally")
+        }
+    } else if password, err = o.jobPassword(ctx, st); err != nil {
+        // the result is encrypted with the document password; never store it without
+        storeErr = fmt.Errorf("document password unavailable: %w", err)
+    } else if result.WantsText(format) {
+        // Save to S3 (encrypted)
+        if s3url, err := SaveAggregatedTextToS3(ctx, filePath, jobID, agg, password); err != nil {
+            storeErr = fmt.Errorf("upload result: %w", err)
+        } else {
+            st.Metadata["result_s3_url"] = s3url
+            log.Info().Str("job_id", jobID).Str("result_s3_url", s3url).Msg("aggregated result stored to S3")
+        }
+    }
+    if doc != nil && storeErr == nil {
+        if err := storeResultDocument(ctx, st.Metadata, *doc, upload, filePath, !result.WantsText(format), password); err != nil {
+            storeErr = fmt.Errorf("store result document: %w", err)
         }
     }
-    return 0
+    st.Progress = 100
+    st.End = &now
+    if storeErr != nil {
+        log.Error().Err(storeErr).Str("job_id", jobID).Msg("finalize: result not stored; job failed")
+        st.Status = "failed"
+        st.Message = "Failed to save result"
+        st.Metadata["error"] = storeErr.Error()
+    } else {
+        st.Status = "success"
+        st.Message = "completed"
+    }
+    // the status goes first: once the secret is gone a retry could no longer store the result
+    secretRef, _ := st.Metadata[secretRefKey].(string)
+    delete(st.Metadata, secretRefKey)
+    if err := o.deps.Status.Set(ctx, jobID, st); err != nil { return fmt.Errorf("set status: %w", err) }
+    o.deleteSecret(ctx, jobID, secretRef)
+    // Cleanup stale temp files older than 1h as part of job completion hygiene
+    CleanupTemps(1 * time.Hour)
+    log.Info().Str("job_id", jobID).Str("status", st.Status).Int("pages_done", prog.Done).Int("pages_failed", prog.Failed).Msg("job completed")
+    return nil
 }
 
 type cancelReq struct {
@@ -570,22 +767,36 @@ func (o *Orchestrator) handleCancelJob(w http.ResponseWriter, r *http.Request) {
     var req cancelReq
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "invalid json", 400); return }
     if req.JobID == "" { http.Error(w, "missing job_id", 400); return }
+    st, ok, _ := o.deps.Status.Get(r.Context(), req.JobID)
+    if ok && !canAccess(r, st) { http.Error(w, "job not found", http.StatusNotFound); return }
+    if !ok { st = Status{} }
+    // the finalize claim decides between cancel and a concurrent last page
+    claimed, err := o.deps.Status.ClaimFinalize(r.Context(), req.JobID)
+    if err != nil { http.Error(w, "cancel failed", 500); return }
+    if !claimed { http.Error(w, "job already completed or completing", http.StatusConflict); return }
     // mark cancelled in queue store
     if err := o.deps.Queue.CancelJob(r.Context(), req.JobID); err != nil {
+        _ = o.deps.Status.ReleaseFinalize(r.Context(), req.JobID)
         http.Error(w, "cancel failed", 500); return
     }
-    st, ok, _ := o.deps.Status.Get(r.Context(), req.JobID)
-    if !ok { st = Status{} }
     st.Status = "cancelled"
     st.Progress = 0
     if req.Reason != "" { st.Message = fmt.Sprintf("Cancelled: %s", req.Reason) } else { st.Message = "Cancelled" }
     now := time.Now(); st.End = &now
-    _ = o.deps.Status.Set(r.Context(), req.JobID, st)
+    if st.Metadata == nil { st.Metadata = map[string]any{} }
+    secretRef, _ := st.Metadata[secretRefKey].(string)
+    delete(st.Metadata, secretRefKey)
+    if err := o.deps.Status.Set(r.Context(), req.JobID, st); err != nil {
+        // the claim runs out with its lease; cancelling again then completes the job's status
+        log.Error().Err(err).Str("job_id", req.JobID).Msg("cancel: status not stored")
+        http.Error(w, "cancel failed", 500); return
+    }
+    o.deleteSecret(r.Context(), req.JobID, secretRef)
     _ = json.NewEncoder(w).Encode(map[string]any{"success": true, "job_id": req.JobID, "status": "cancelled"})
 }
 
 // processMuPDFOnly handles text-only extraction using MuPDF without AI
-func (o *Orchestrator) processMuPDFOnly(ctx context.Context, jobID, pdfPath string) {
+func (o *Orchestrator) processMuPDFOnly(ctx context.Context, jobID, pdfPath, fileName, clientID, format string) {
     startTime := time.Now()
 
     // Update status to processing
@@ -594,7 +805,7 @@ func (o *Orchestrator) processMuPDFOnly(ctx context.Context, jobID, pdfPath stri
         Progress: 5,
         Message:  "Starting text extraction",
         Start:    &startTime,
-        Metadata: map[string]any{"file_local": pdfPath, "source": "upload", "mode": "text_only"},
+        Metadata: map[string]any{"file_local": pdfPath, "client_id": clientID, "source": "upload", "mode": "text_only"},
     })
 
     // Try go-fitz first, fallback to mutool if needed
@@ -643,6 +854,7 @@ func (o *Orchestrator) processMuPDFOnly(ctx context.Context, jobID, pdfPath stri
         Start:    &startTime,
         Metadata: map[string]any{
             "file_local":  pdfPath,
+            "client_id":   clientID,
             "source":      "upload",
             "mode":        "text_only",
             "total_pages": pageCount,
@@ -652,13 +864,17 @@ func (o *Orchestrator) processMuPDFOnly(ctx context.Context, jobID, pdfPath stri
     // Extract text from all pages
     var allText strings.Builder
     extractedChars := 0
+    recs := make([]store.PageRecord, 0, pageCount)
 
     for i := 1; i <= pageCount; i++ {
         pageText, err := extractor.ExtractTextByPage(pdfPath, i)
+        rec := store.PageRecord{Text: pageText, Source: result.SourceMuPDF, DoneAt: time.Now()}
         if err != nil {
             log.Warn().Err(err).Int("page", i).Msg("Failed to extract text from page")
             pageText = fmt.Sprintf("[Page %d extraction failed]\n", i)
+            rec.Warning = "MuPDF text extraction failed: " + err.Error()
         }
+        recs = append(recs, rec)
 
         // Add page separator
         if i > 1 {
@@ -677,6 +893,7 @@ func (o *Orchestrator) processMuPDFOnly(ctx context.Context, jobID, pdfPath stri
             Start:    &startTime,
             Metadata: map[string]any{
                 "file_local":       pdfPath,
+                "client_id":        clientID,
                 "source":           "upload",
                 "mode":             "text_only",
                 "total_pages":      pageCount,
@@ -731,6 +948,23 @@ func (o *Orchestrator) processMuPDFOnly(ctx context.Context, jobID, pdfPath stri
     // Mark as successful
     endTime := time.Now()
     duration := endTime.Sub(startTime).Seconds()
+    meta := map[string]any{
+        "file_local":        pdfPath,
+        "client_id":         clientID,
+        "source":            "upload",
+        "mode":              "text_only",
+        "total_pages":       pageCount,
+        "chars_extracted":   len(resultText),
+        "result_local_path": resultPath,
+        "processing_time":   duration,
+        "result_format":     format,
+    }
+    if result.WantsDocument(format) {
+        doc := buildResultDocument(jobID, Status{Start: &startTime, Metadata: map[string]any{"mode": "text_only", "file_name": fileName}}, recs, resultText, store.Usage{}, endTime)
+        if err := storeResultDocument(ctx, meta, doc, true, "", false, ""); err != nil {
+            log.Error().Err(err).Str("job_id", jobID).Msg("Failed to save result document")
+        }
+    }
 
     _ = o.deps.Status.Set(ctx, jobID, Status{
         Status:   "success",
@@ -738,15 +972,7 @@ func (o *Orchestrator) processMuPDFOnly(ctx context.Context, jobID, pdfPath stri
         Message:  fmt.Sprintf("Text extraction completed in %.1f seconds", duration),
         Start:    &startTime,
         End:      &endTime,
-        Metadata: map[string]any{
-            "file_local":        pdfPath,
-            "source":            "upload",
-            "mode":              "text_only",
-            "total_pages":       pageCount,
-            "chars_extracted":   len(resultText),
-            "result_local_path": resultPath,
-            "processing_time":   duration,
-        },
+        Metadata: meta,
     })
 
     log.Info().
@@ -759,7 +985,7 @@ func (o *Orchestrator) processMuPDFOnly(ctx context.Context, jobID, pdfPath stri
 
 // processMuPDFOnlyFromS3 handles text-only extraction for S3 files using MuPDF without AI
 // Downloads from S3, converts if needed, extracts text with MuPDF, and saves result back to S3
-func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path, user, password string) {
+func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path, user, clientID, password, format string) {
     startTime := time.Now()
 
     // Update status to processing
@@ -768,7 +994,7 @@ func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path
         Progress: 5,
         Message:  "Downloading file from S3",
         Start:    &startTime,
-        Metadata: map[string]any{"file_path": s3Path, "user": user, "source": "api", "mode": "text_only"},
+        Metadata: map[string]any{"file_path": s3Path, "user": user, "client_id": clientID, "source": "api", "mode": "text_only"},
     })
 
     // Download file from S3
@@ -796,7 +1022,7 @@ func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path
         Progress: 15,
         Message:  "Detecting file type",
         Start:    &startTime,
-        Metadata: map[string]any{"file_path": s3Path, "file_local": localPath, "user": user, "source": "api", "mode": "text_only", "password": password},
+        Metadata: map[string]any{"file_path": s3Path, "file_local": localPath, "user": user, "client_id": clientID, "source": "api", "mode": "text_only"},
     })
 
     // Detect file type
@@ -840,7 +1066,7 @@ func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path
             Progress: 20,
             Message:  "Converting to PDF",
             Start:    &startTime,
-            Metadata: map[string]any{"file_path": s3Path, "file_local": localPath, "user": user, "source": "api", "mode": "text_only", "original_mime": fileInfo.MIMEType},
+            Metadata: map[string]any{"file_path": s3Path, "file_local": localPath, "user": user, "client_id": clientID, "source": "api", "mode": "text_only", "original_mime": fileInfo.MIMEType},
         })
 
         convertedPath := filepath.Join(filepath.Dir(localPath), fmt.Sprintf("%s_converted.pdf", jobID))
@@ -877,7 +1103,7 @@ func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path
         Progress: 30,
         Message:  "Starting text extraction",
         Start:    &startTime,
-        Metadata: map[string]any{"file_path": s3Path, "file_local": pdfPath, "user": user, "source": "api", "mode": "text_only"},
+        Metadata: map[string]any{"file_path": s3Path, "file_local": pdfPath, "user": user, "client_id": clientID, "source": "api", "mode": "text_only"},
     })
 
     // Try go-fitz first, fallback to mutool if needed
@@ -944,6 +1170,7 @@ func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path
     // Extract text from all pages
     var allText strings.Builder
     extractedChars := 0
+    recs := make([]store.PageRecord, 0, pageCount)
 
     for i := 1; i <= pageCount; i++ {
         var pageText string
@@ -953,10 +1180,13 @@ func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path
         } else {
             pageText, err = mutoolExtractor.ExtractTextByPage(pdfPath, i)
         }
+        rec := store.PageRecord{Text: pageText, Source: result.SourceMuPDF, DoneAt: time.Now()}
         if err != nil {
             log.Warn().Err(err).Int("page", i).Msg("Failed to extract text from page")
             pageText = fmt.Sprintf("[Page %d extraction failed]\n", i)
+            rec.Warning = "MuPDF text extraction failed: " + err.Error()
         }
+        recs = append(recs, rec)
 
         // Add page separator
         if i > 1 {
@@ -1013,6 +1243,7 @@ func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path
         Metadata: map[string]any{
             "file_path":       s3Path,
             "user":            user,
+            "client_id":       clientID,
             "source":          "api",
             "mode":            "text_only",
             "total_pages":     pageCount,
@@ -1020,8 +1251,25 @@ func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path
         },
     })
 
-    // Save result to S3 (encrypted)
-    s3url, err := SaveAggregatedTextToS3(ctx, s3Path, jobID, resultText, password)
+    // Save result to S3 (encrypted): the plain text, the result document or both
+    meta := map[string]any{
+        "file_path":       s3Path,
+        "user":            user,
+        "client_id":       clientID,
+        "source":          "api",
+        "mode":            "text_only",
+        "total_pages":     pageCount,
+        "chars_extracted": len(resultText),
+        "result_format":   format,
+    }
+    if result.WantsText(format) {
+        var s3url string
+        if s3url, err = SaveAggregatedTextToS3(ctx, s3Path, jobID, resultText, password); err == nil { meta["result_s3_url"] = s3url }
+    }
+    if err == nil && result.WantsDocument(format) {
+        doc := buildResultDocument(jobID, Status{Start: &startTime, Metadata: map[string]any{"mode": "text_only", "file_path": s3Path}}, recs, resultText, store.Usage{}, time.Now())
+        err = storeResultDocument(ctx, meta, doc, false, s3Path, !result.WantsText(format), password)
+    }
     if err != nil {
         log.Error().Err(err).Str("job_id", jobID).Msg("Failed to save result to S3")
         endTime := time.Now()
@@ -1039,6 +1287,7 @@ func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path
     // Mark as successful
     endTime := time.Now()
     duration := endTime.Sub(startTime).Seconds()
+    meta["processing_time"] = duration
 
     _ = o.deps.Status.Set(ctx, jobID, Status{
         Status:   "success",
@@ -1046,18 +1295,10 @@ func (o *Orchestrator) processMuPDFOnlyFromS3(ctx context.Context, jobID, s3Path
         Message:  fmt.Sprintf("Text extraction completed in %.1f seconds", duration),
         Start:    &startTime,
         End:      &endTime,
-        Metadata: map[string]any{
-            "file_path":       s3Path,
-            "user":            user,
-            "source":          "api",
-            "mode":            "text_only",
-            "total_pages":     pageCount,
-            "chars_extracted": len(resultText),
-            "result_s3_url":   s3url,
-            "processing_time": duration,
-        },
+        Metadata: meta,
     })
 
+    s3url, _ := meta["result_s3_url"].(string)
     log.Info().
         Str("job_id", jobID).
         Str("s3_url", s3url).
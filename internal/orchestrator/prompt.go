@@ -0,0 +1,47 @@
+package orchestrator
+
+import (
+    "fmt"
+
+    "github.com/local/aidispatcher/internal/prompt"
+)
+
+// promptSpec is the per-job prompt selection forwarded to workers with every page.
+type promptSpec struct {
+    Custom         string // ai_prompt override; wins over Template
+    Template       string
+    Version        int
+    Language       string
+    TargetLanguage string
+    ContextTokens  int // budget for the previous page's tail in each page prompt; 0 = off
+}
+
+// resolve checks that the selected template exists, so bad requests fail fast instead of
+// every page failing in the worker, and pins its name and version: every page of the job
+// renders the same template even if a newer version is loaded while the job runs.
+func (p *promptSpec) resolve(lib *prompt.Library) error {
+    if p.Custom != "" || lib == nil { return nil }
+    t, err := lib.Get(p.Template, p.Version)
+    if err != nil { return err }
+    p.Template, p.Version = t.Name, t.Version
+    return nil
+}
+
+// apply adds the prompt fields to a page payload.
+func (p promptSpec) apply(payload map[string]any, totalPages int) {
+    if p.Custom != "" { payload["ai_prompt"] = p.Custom }
+    if p.Template != "" { payload["prompt_template"] = p.Template }
+    if p.Version > 0 { payload["prompt_version"] = p.Version }
+    if p.Language != "" { payload["language"] = p.Language }
+    if p.TargetLanguage != "" { payload["target_language"] = p.TargetLanguage }
+    if p.ContextTokens > 0 { payload["context_tokens"] = p.ContextTokens }
+    payload["total_pages"] = totalPages
+}
+
+// describe returns a short label for logs and job metadata (never the prompt text itself).
+func (p promptSpec) describe() string {
+    if p.Custom != "" { return prompt.Custom }
+    if p.Template == "" { return "default" }
+    if p.Version > 0 { return fmt.Sprintf("%s.v%d", p.Template, p.Version) }
+    return p.Template
+}
@@ -0,0 +1,102 @@
+package orchestrator
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "time"
+
+    "github.com/local/aidispatcher/internal/queue"
+    "github.com/rs/zerolog/log"
+)
+
+// resultApplyAttempts bounds in-process retries before a result is parked in the results DLQ.
+const resultApplyAttempts = 5
+
+// resultRetryDelay is the pause after the first failed attempt; it doubles after each one.
+var resultRetryDelay = 500 * time.Millisecond
+
+// StartResultConsumers starts n goroutines that apply page outcomes published by workers.
+func (o *Orchestrator) StartResultConsumers(n int) {
+    if n <= 0 { n = 1 }
+    host, _ := os.Hostname()
+    if host == "" { host = "local" }
+    for i := 0; i < n; i++ {
+        consumer := fmt.Sprintf("%s-%d-r-%d", host, os.Getpid(), i)
+        o.wg.Add(1)
+        go o.consumeResults(consumer)
+    }
+}
+
+// Stop stops result consumers and waits for in-flight results to be applied.
+func (o *Orchestrator) Stop(ctx context.Context) error {
+    close(o.stop)
+    done := make(chan struct{})
+    go func() { o.wg.Wait(); close(done) }()
+    select {
+    case <-done:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+func (o *Orchestrator) consumeResults(consumer string) {
+    defer o.wg.Done()
+    log.Info().Str("consumer", consumer).Msg("result consumer started")
+    for {
+        select {
+        case <-o.stop:
+            log.Info().Str("consumer", consumer).Msg("result consumer stopped")
+            return
+        default:
+        }
+        msgID, data, err := o.deps.Queue.DequeueResult(context.Background(), consumer, 2*time.Second)
+        if err != nil {
+            log.Error().Err(err).Msg("result dequeue error")
+            time.Sleep(500 * time.Millisecond)
+            continue
+        }
+        if data == nil { continue }
+        o.handleResult(msgID, data)
+    }
+}
+
+// handleResult applies one result with retries; results that still fail are moved to the
+// results DLQ instead of being dropped. A crash before Ack leaves the entry pending for the reclaimer.
+func (o *Orchestrator) handleResult(msgID string, data []byte) {
+    var res queue.PageResult
+    if err := json.Unmarshal(data, &res); err != nil || res.JobID == "" || res.PageID <= 0 {
+        log.Error().Err(err).Str("msg_id", msgID).Msg("malformed page result; moving to DLQ")
+        _ = o.deps.Queue.AddResultDLQ(context.Background(), data, "malformed")
+        _ = o.deps.Queue.AckResult(context.Background(), msgID)
+        return
+    }
+    var err error
+    delay := resultRetryDelay
+    for attempt := 1; attempt <= resultApplyAttempts; attempt++ {
+        ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+        if res.Status == queue.ResultFailed {
+            err = o.recordPageFailed(ctx, res)
+        } else {
+            err = o.recordPageDone(ctx, res)
+        }
+        cancel()
+        if err == nil { break }
+        log.Warn().Err(err).Str("job_id", res.JobID).Int("page_id", res.PageID).Int("attempt", attempt).Msg("applying page result failed")
+        if attempt < resultApplyAttempts {
+            time.Sleep(delay)
+            delay *= 2
+        }
+    }
+    if err != nil {
+        if dlqErr := o.deps.Queue.AddResultDLQ(context.Background(), data, err.Error()); dlqErr != nil {
+            // keep it pending; the reclaimer re-delivers it later
+            log.Error().Err(dlqErr).Str("msg_id", msgID).Msg("failed to park page result; leaving pending")
+            return
+        }
+        log.Error().Err(err).Str("job_id", res.JobID).Int("page_id", res.PageID).Msg("page result moved to DLQ")
+    }
+    _ = o.deps.Queue.AckResult(context.Background(), msgID)
+}
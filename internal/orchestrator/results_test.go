@@ -0,0 +1,79 @@
+package orchestrator
+
+import (
+    "context"
+    "encoding/json"
+    "testing"
+    "time"
+
+    "github.com/local/aidispatcher/internal/queue"
+)
+
+// consumeOne publishes payload on the results stream and applies it as a result consumer would.
+func (e *testEnv) consumeOne(t *testing.T, payload []byte) {
+    t.Helper()
+    ctx := context.Background()
+    if err := e.q.PublishResult(ctx, payload); err != nil { t.Fatal(err) }
+    msgID, data, err := e.q.DequeueResult(ctx, "orchestrator", time.Millisecond)
+    if err != nil || data == nil { t.Fatalf("DequeueResult: %v", err) }
+    e.o.handleResult(msgID, data)
+}
+
+// resultsDLQ returns the reasons of the entries in the results DLQ.
+func (e *testEnv) resultsDLQ(t *testing.T) []string {
+    t.Helper()
+    entries, err := e.mr.Stream(e.q.ResultsStream + ":dlq")
+    if err != nil { return nil }
+    var reasons []string
+    for _, en := range entries {
+        for i := 0; i+1 < len(en.Values); i += 2 {
+            if en.Values[i] == "reason" { reasons = append(reasons, en.Values[i+1]) }
+        }
+    }
+    return reasons
+}
+
+// pendingResults reports whether the consumer still holds an unacked result: a read of
+// its own PEL returns it again.
+func (e *testEnv) pendingResults(t *testing.T) bool {
+    t.Helper()
+    _, data, err := e.q.DequeueResult(context.Background(), "orchestrator", time.Millisecond)
+    if err != nil { t.Fatal(err) }
+    return data != nil
+}
+
+func TestHandleResult(t *testing.T) {
+    old := resultRetryDelay
+    resultRetryDelay = time.Millisecond
+    t.Cleanup(func() { resultRetryDelay = old })
+    done, _ := json.Marshal(queue.PageResult{JobID: "job", PageID: 1, Status: queue.ResultDone, Text: "page text"})
+
+    t.Run("a Redis blip is retried", func(t *testing.T) {
+        flaky := &flakyStatus{}
+        e := newTestEnv(t, func(s StatusStore) StatusStore { flaky.StatusStore = s; return flaky })
+        e.startJob(t, "job", 1, nil)
+        flaky.failPages = resultApplyAttempts - 1
+        e.consumeOne(t, done)
+        if st := e.jobStatus(t, "job"); st.Status != "success" { t.Errorf("job = %s, want the result applied", st.Status) }
+        if dlq := e.resultsDLQ(t); len(dlq) != 0 { t.Errorf("results DLQ = %v", dlq) }
+        if e.pendingResults(t) { t.Errorf("result left unacked") }
+    })
+
+    t.Run("a result that keeps failing is parked", func(t *testing.T) {
+        flaky := &flakyStatus{}
+        e := newTestEnv(t, func(s StatusStore) StatusStore { flaky.StatusStore = s; return flaky })
+        e.startJob(t, "job", 1, nil)
+        flaky.failPages = resultApplyAttempts
+        e.consumeOne(t, done)
+        if st := e.jobStatus(t, "job"); st.Status != "processing" { t.Errorf("job = %s", st.Status) }
+        if dlq := e.resultsDLQ(t); len(dlq) != 1 || dlq[0] == "" { t.Errorf("results DLQ = %v, want the result with its error", dlq) }
+        if e.pendingResults(t) { t.Errorf("result left unacked") }
+    })
+
+    t.Run("a malformed result is parked at once", func(t *testing.T) {
+        e := newTestEnv(t, nil)
+        e.consumeOne(t, []byte(`{"job_id":"job","page_id":0}`))
+        if dlq := e.resultsDLQ(t); len(dlq) != 1 || dlq[0] != "malformed" { t.Errorf("results DLQ = %v", dlq) }
+        if e.pendingResults(t) { t.Errorf("result left unacked") }
+    })
+}
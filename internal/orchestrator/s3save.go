@@ -16,6 +16,21 @@ import (
 // NOTE: File is encrypted using the same password used to download the original file.
 // NOTE: Preserves original metadata from source file for Ghost Server compatibility.
 func SaveAggregatedTextToS3(ctx context.Context, originalRef, jobID, text, password string) (string, error) {
+    return uploadResult(ctx, originalRef, jobID, []byte(text), len(text), "plain_text", "extracted_text.txt", "", password)
+}
+
+// SaveResultDocumentToS3 uploads the JSON result document (encrypted like the text). As the
+// primary result it goes where the text would; otherwise it is stored next to the text with
+// a ".json" suffix. textLen is the length of the document's text, for body_tokens.
+func SaveResultDocumentToS3(ctx context.Context, originalRef, jobID string, doc []byte, textLen int, primary bool, password string) (string, error) {
+    suffix := ".json"
+    if primary { suffix = "" }
+    return uploadResult(ctx, originalRef, jobID, doc, textLen, "result_json_v1", "result.json", suffix, password)
+}
+
+// uploadResult stores data at the Ghost Server location of the original (plus suffix), or at
+// results/{jobID}/{fallbackName} when the original key has no _original suffix.
+func uploadResult(ctx context.Context, originalRef, jobID string, data []byte, textLen int, format, fallbackName, suffix, password string) (string, error) {
     bucket := os.Getenv("AWS_S3_BUCKET")
     if bucket == "" {
         bucket = "junior-files-dev" // default bucket
@@ -37,10 +52,13 @@ func SaveAggregatedTextToS3(ctx context.Context, originalRef, jobID, text, passw
     key := strings.TrimSuffix(originalKey, "_original")
     if key == "" || key == originalKey {
         // Fallback if no _original suffix found
-        key = fmt.Sprintf("results/%s/extracted_text.txt", jobID)
+        key = fmt.Sprintf("results/%s/%s", jobID, fallbackName)
+        suffix = ""
         log.Warn().Str("original_key", originalKey).Str("fallback_key", key).Msg("original key missing _original suffix, using fallback")
     }
 
+    key += suffix
+
     // Create S3 client with encryption support
     s3Client, err := storage.NewS3Client(ctx, bucket)
     if err != nil {
@@ -60,15 +78,17 @@ func SaveAggregatedTextToS3(ctx context.Context, originalRef, jobID, text, passw
 
     // Prepare metadata with Ghost Server compatibility
     // Start with original filename from source metadata if available
-    originalName := "extracted_text.txt"
+    originalName := fallbackName
     if originalMetadata != nil && originalMetadata.OriginalName != "" {
         originalName = originalMetadata.OriginalName
     }
 
+    contentType := "application/json"
+    if format == "plain_text" { contentType = "text/plain; charset=utf-8" }
     metadata := &storage.FileMetadata{
         OriginalName: originalName,
-        ContentType:  "application/json", // Ghost Server expects application/json
-        Size:         int64(len(text)),
+        ContentType:  contentType,
+        Size:         int64(len(data)),
         Metadata:     make(map[string]string),
     }
 
@@ -82,18 +102,17 @@ func SaveAggregatedTextToS3(ctx context.Context, originalRef, jobID, text, passw
     // Add/override processing-specific fields
     metadata.Metadata["job_id"] = jobID
     metadata.Metadata["source"] = "mupdf_extraction"
-    metadata.Metadata["format"] = "plain_text"
+    metadata.Metadata["format"] = format
     metadata.Metadata["created"] = time.Now().UTC().Format(time.RFC3339)
-    metadata.Metadata["body_tokens"] = fmt.Sprintf("%d", len(text)/4)
+    metadata.Metadata["body_tokens"] = fmt.Sprintf("%d", textLen/4)
 
     // Upload encrypted file to S3
-    data := []byte(text)
     if err := s3Client.UploadFile(ctx, key, data, password, metadata); err != nil {
         return "", fmt.Errorf("failed to upload to S3: %w", err)
     }
 
     s3URL := fmt.Sprintf("s3://%s/%s", bucket, key)
-    log.Info().Str("s3_url", s3URL).Int("size", len(text)).Msg("uploaded encrypted text result to S3 with Ghost Server metadata")
+    log.Info().Str("s3_url", s3URL).Str("format", format).Int("size", len(data)).Msg("uploaded encrypted result to S3 with Ghost Server metadata")
 
     return s3URL, nil
 }
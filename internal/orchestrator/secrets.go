@@ -0,0 +1,74 @@
+package orchestrator
+
+import (
+    "context"
+    "errors"
+
+    "github.com/rs/zerolog/log"
+)
+
+// SecretStore keeps document passwords outside of job status and queue payloads.
+// Jobs only carry the opaque reference returned by Put (metadata "secret_ref").
+type SecretStore interface {
+    Put(ctx context.Context, value string) (string, error)
+    Get(ctx context.Context, ref string) (string, error)
+    Delete(ctx context.Context, ref string) error
+}
+
+const secretRefKey = "secret_ref"
+
+// passwordProtectedKey marks jobs that had a password; unlike secret_ref it survives the wipe.
+const passwordProtectedKey = "password_protected"
+
+var (
+    errNoSecretStore = errors.New("secret store not configured")
+    errSecretWiped   = errors.New("document password already wiped")
+)
+
+// stashPassword stores password in the secret store and returns its reference ("" for no password).
+func (o *Orchestrator) stashPassword(ctx context.Context, password string) (string, error) {
+    if password == "" { return "", nil }
+    if o.deps.Secrets == nil { return "", errNoSecretStore }
+    return o.deps.Secrets.Put(ctx, password)
+}
+
+// jobPassword resolves the password referenced by the job status ("" when the job has none).
+// A job whose password was wiped gets errSecretWiped, never "".
+func (o *Orchestrator) jobPassword(ctx context.Context, st Status) (string, error) {
+    ref, _ := st.Metadata[secretRefKey].(string)
+    if ref == "" {
+        if protected, _ := st.Metadata[passwordProtectedKey].(bool); protected { return "", errSecretWiped }
+        return "", nil
+    }
+    if o.deps.Secrets == nil { return "", errNoSecretStore }
+    return o.deps.Secrets.Get(ctx, ref)
+}
+
+// wipeSecret deletes the job's secret and drops the reference from st.Metadata.
+// Called when a job completes or is cancelled; the TTL covers jobs that never get there.
+func (o *Orchestrator) wipeSecret(ctx context.Context, jobID string, st *Status) {
+    ref, _ := st.Metadata[secretRefKey].(string)
+    if ref == "" { return }
+    delete(st.Metadata, secretRefKey)
+    o.deleteSecret(ctx, jobID, ref)
+}
+
+// deleteSecret removes a secret whose reference was already dropped from the job metadata.
+func (o *Orchestrator) deleteSecret(ctx context.Context, jobID, ref string) {
+    if ref == "" || o.deps.Secrets == nil { return }
+    if err := o.deps.Secrets.Delete(ctx, ref); err != nil {
+        log.Warn().Err(err).Str("job_id", jobID).Msg("failed to wipe job secret; it will expire")
+    }
+}
+
+// publicMetadata returns job metadata safe to expose over the API.
+func publicMetadata(meta map[string]any) map[string]any {
+    if meta == nil { return nil }
+    out := make(map[string]any, len(meta))
+    for k, v := range meta {
+        // "password" may still be present on jobs created before the secret store existed
+        if k == secretRefKey || k == "password" { continue }
+        out[k] = v
+    }
+    return out
+}
@@ -0,0 +1,78 @@
+package orchestrator
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/local/aidispatcher/internal/queue"
+)
+
+// memSecrets is an in-memory SecretStore.
+type memSecrets map[string]string
+
+func (m memSecrets) Put(_ context.Context, v string) (string, error) {
+    ref := "sec_" + v
+    m[ref] = v
+    return ref, nil
+}
+
+func (m memSecrets) Get(_ context.Context, ref string) (string, error) {
+    v, ok := m[ref]
+    if !ok { return "", errSecretWiped }
+    return v, nil
+}
+
+func (m memSecrets) Delete(_ context.Context, ref string) error {
+    delete(m, ref)
+    return nil
+}
+
+func TestFinalizeWithoutStoredResult(t *testing.T) {
+    tests := []struct {
+        name    string
+        meta    map[string]any
+        badDir  bool // RESULT_DIR is not writable
+        status  string
+        message string
+    }{
+        {name: "upload stored", meta: map[string]any{secretRefKey: "sec_pw", passwordProtectedKey: true}, status: "success", message: "completed"},
+        {name: "upload not stored", meta: map[string]any{secretRefKey: "sec_pw", passwordProtectedKey: true}, badDir: true,
+            status: "failed", message: "Failed to save result"},
+        {name: "password wiped", meta: map[string]any{"source": "api", "file_path": "s3://bucket/doc.pdf", passwordProtectedKey: true},
+            status: "failed", message: "Failed to save result"},
+        {name: "password expired", meta: map[string]any{"source": "api", "file_path": "s3://bucket/doc.pdf", secretRefKey: "sec_gone", passwordProtectedKey: true},
+            status: "failed", message: "Failed to save result"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx := context.Background()
+            e := newTestEnv(t, nil)
+            secrets := memSecrets{"sec_pw": "pw"}
+            e.o.deps.Secrets = secrets
+            if tt.badDir {
+                f := filepath.Join(t.TempDir(), "file")
+                if err := os.WriteFile(f, nil, 0o644); err != nil { t.Fatal(err) }
+                t.Setenv("RESULT_DIR", f)
+            }
+            e.startJob(t, "job", 1, tt.meta)
+            if err := e.o.recordPageDone(ctx, queue.PageResult{JobID: "job", PageID: 1, Status: queue.ResultDone, Text: "text"}); err != nil {
+                t.Fatalf("recordPageDone: %v", err)
+            }
+            st := e.jobStatus(t, "job")
+            if st.Status != tt.status || st.Message != tt.message { t.Errorf("job = %s %q, want %s %q", st.Status, st.Message, tt.status, tt.message) }
+            if tt.status == "failed" {
+                if st.Metadata["error"] == nil { t.Errorf("failed job has no error") }
+                for _, k := range []string{"result_local_path", "result_s3_url"} {
+                    if st.Metadata[k] != nil { t.Errorf("failed job reports %s", k) }
+                }
+            }
+            // finished either way: the secret is gone and the job cannot be finalized again
+            if ref, _ := tt.meta[secretRefKey].(string); secrets[ref] != "" || st.Metadata[secretRefKey] != nil {
+                t.Errorf("secret %q kept: %v", ref, st.Metadata[secretRefKey])
+            }
+            if ok, _ := e.status.ClaimFinalize(ctx, "job"); ok { t.Errorf("finished job could be claimed again") }
+        })
+    }
+}
@@ -1,31 +1,182 @@
 package orchestrator
 
-// NOTE: Ovo je skeleton za MuPDF selekciju stranica. U ovoj fazi ne koristimo
-// stvarni MuPDF SDK; dodajemo strukture i minimalnu heuristiku za PoC.
+import (
+    "context"
+    "fmt"
+    "os"
+
+    "github.com/local/aidispatcher/internal/mupdf"
+    mpkg "github.com/local/aidispatcher/internal/metrics"
+    "github.com/rs/zerolog/log"
+)
+
+// Selekcija stranica: stranice s dobrim tekstualnim slojem čita MuPDF (besplatno),
+// skenirane stranice i dijagrami idu na AI. Ovo je najveća poluga troška.
+
+// Načini selekcije (config SELECTION_MODE, per-request "page_selection").
+const (
+    SelectAuto  = "auto"  // heuristika po stranici
+    SelectAI    = "ai"    // sve stranice na AI (staro ponašanje)
+    SelectMuPDF = "mupdf" // sve stranice iz tekstualnog sloja
+)
 
 type PageInfo struct {
-    Page int
-    HasImages bool
-    TextDensity float64 // 0..1
+    Page          int
+    HasImages     bool
+    TextDensity   float64 // 0..1
+    TextChars     int
+    GarbageRatio  float64
+    ImageCoverage float64
+    VectorDensity float64 // putanje po stranici veličine A4
+    Text          string  // tekstualni sloj, koristi se za MuPDF stranice
+}
+
+// SelectionThresholds određuju kada je tekstualni sloj dovoljno dobar.
+// U zahtjevu ("selection_thresholds") se zadaju samo polja koja se mijenjaju.
+type SelectionThresholds struct {
+    MinTextChars     int     `json:"min_text_chars"`     // manje znakova = sken ili prazna stranica
+    MaxGarbageRatio  float64 `json:"max_garbage_ratio"`  // udio neispravnih glifova (loš ToUnicode)
+    MaxImageCoverage float64 `json:"max_image_coverage"` // udio površine pod slikama
+    MaxVectorDensity float64 `json:"max_vector_density"` // dijagrami, grafovi
+}
+
+// merge vraća t s poljima iz o koja su postavljena.
+func (t SelectionThresholds) merge(o *SelectionThresholds) SelectionThresholds {
+    if o == nil { return t }
+    if o.MinTextChars > 0 { t.MinTextChars = o.MinTextChars }
+    if o.MaxGarbageRatio > 0 { t.MaxGarbageRatio = o.MaxGarbageRatio }
+    if o.MaxImageCoverage > 0 { t.MaxImageCoverage = o.MaxImageCoverage }
+    if o.MaxVectorDensity > 0 { t.MaxVectorDensity = o.MaxVectorDensity }
+    return t
+}
+
+// SelectionConfig su zadane postavke orchestratora.
+type SelectionConfig struct {
+    Mode         string
+    Thresholds   SelectionThresholds
+    LocalWorkers int // koliko dokumenata MuPDF pool obrađuje istovremeno
+    BatchPages   int // koliko susjednih AI stranica ide u jedan zahtjev (1 = bez grupiranja)
 }
 
 type SelectionResult struct {
     MuPDFPages []int
     AIPages    []int
+    Reasons    map[string]int // razlog → broj stranica, za log i metadata
 }
 
 type SelectionOptions struct {
-    TextOnly bool
+    TextOnly   bool
     TotalPages int // ako nije poznato, koristi konservativni broj, npr. 3
+    Mode       string
+    Pages      []PageInfo // analiza; bez nje sve stranice idu na AI
+    Thresholds SelectionThresholds
 }
 
-// SelectPages implementira minimalnu heuristiku:
-// - Privremeno: isključujemo MuPDF obradu i sve stranice šaljemo na AI.
+// SelectPages raspoređuje stranice između MuPDF-a i AI-ja.
 func SelectPages(opts SelectionOptions) SelectionResult {
     if opts.TotalPages <= 0 { opts.TotalPages = 3 }
-    res := SelectionResult{}
-    for i := 1; i <= opts.TotalPages; i++ {
-        res.AIPages = append(res.AIPages, i)
+    res := SelectionResult{Reasons: map[string]int{}}
+    if opts.TextOnly || opts.Mode == SelectMuPDF {
+        for i := 1; i <= opts.TotalPages; i++ { res.MuPDFPages = append(res.MuPDFPages, i) }
+        res.Reasons["forced_mupdf"] = opts.TotalPages
+        return res
+    }
+    if opts.Mode == SelectAI || len(opts.Pages) == 0 {
+        for i := 1; i <= opts.TotalPages; i++ { res.AIPages = append(res.AIPages, i) }
+        if opts.Mode == SelectAI { res.Reasons["forced_ai"] = opts.TotalPages } else { res.Reasons["not_analyzed"] = opts.TotalPages }
+        return res
+    }
+    for _, p := range opts.Pages {
+        toAI, reason := routePage(p, opts.Thresholds)
+        if toAI { res.AIPages = append(res.AIPages, p.Page) } else { res.MuPDFPages = append(res.MuPDFPages, p.Page) }
+        res.Reasons[reason]++
     }
     return res
 }
+
+// routePage odlučuje za jednu stranicu; vraća true za AI i razlog odluke.
+func routePage(p PageInfo, t SelectionThresholds) (bool, string) {
+    switch {
+    case p.GarbageRatio > t.MaxGarbageRatio:
+        return true, "garbled_text"
+    case p.ImageCoverage > t.MaxImageCoverage:
+        return true, "images"
+    case p.VectorDensity > t.MaxVectorDensity:
+        return true, "diagram"
+    case p.TextChars < t.MinTextChars:
+        // bez teksta, slika i crteža stranica je prazna – AI nema što pročitati
+        if p.ImageCoverage < 0.02 && p.VectorDensity < t.MaxVectorDensity/10 { return false, "blank" }
+        return true, "little_text"
+    default:
+        return false, "text_layer"
+    }
+}
+
+// validSelectionMode provjerava per-request vrijednost; prazno znači zadani način.
+func validSelectionMode(mode string) error {
+    switch mode {
+    case "", SelectAuto, SelectAI, SelectMuPDF:
+        return nil
+    }
+    return fmt.Errorf("invalid page_selection %q (auto|ai|mupdf)", mode)
+}
+
+// planPages analizira dokument i radi selekciju. Ako analiza ne uspije, broj stranica
+// se određuje kao prije, a sve stranice idu na AI.
+func (o *Orchestrator) planPages(ctx context.Context, jobID, ref, password, mode string, th *SelectionThresholds, textOnly bool, fallbackPages int) (int, SelectionResult, []PageInfo) {
+    if mode == "" { mode = o.deps.Selection.Mode }
+    if mode == "" { mode = SelectAuto }
+    var infos []PageInfo
+    pages := 0
+    if mode != SelectAI && !textOnly {
+        var err error
+        if infos, err = analyzeDocument(ctx, ref, password); err != nil {
+            log.Warn().Err(err).Str("job_id", jobID).Str("file", ref).Msg("page analysis failed; sending all pages to AI")
+            infos = nil
+            if mode == SelectAuto { mode = SelectAI }
+        }
+        pages = len(infos)
+    }
+    if pages == 0 {
+        n, err := DetermineTotalPages(ctx, ref)
+        if err != nil {
+            log.Warn().Err(err).Str("file", ref).Int("default", fallbackPages).Msg("page count failed; using default")
+            n = fallbackPages
+        }
+        pages = n
+    }
+    sel := SelectPages(SelectionOptions{TextOnly: textOnly, TotalPages: pages, Mode: mode, Pages: infos,
+        Thresholds: o.deps.Selection.Thresholds.merge(th)})
+    for reason, n := range sel.Reasons {
+        route := "ai"
+        if reason == "text_layer" || reason == "blank" || reason == "forced_mupdf" { route = "mupdf" }
+        mpkg.AddPagesRouted(route, reason, n)
+    }
+    return pages, sel, infos
+}
+
+// analyzeDocument preuzima dokument (ako treba) i analizira svaku stranicu.
+func analyzeDocument(ctx context.Context, ref, password string) ([]PageInfo, error) {
+    localPath, tmp, err := ensureLocalPDF(ctx, ref, password)
+    if err != nil { return nil, err }
+    if tmp != "" { defer os.Remove(tmp) }
+
+    stats, err := mupdf.NewGoFitzExtractor().AnalyzeDocument(localPath)
+    if err != nil { return nil, err }
+    infos := make([]PageInfo, 0, len(stats))
+    for _, s := range stats {
+        density := float64(s.TextChars) / 3000 // ~puna A4 stranica teksta
+        if density > 1 { density = 1 }
+        infos = append(infos, PageInfo{
+            Page:          s.Page,
+            HasImages:     s.ImageCoverage > 0,
+            TextDensity:   density,
+            TextChars:     s.TextChars,
+            GarbageRatio:  s.GarbageRatio,
+            ImageCoverage: s.ImageCoverage,
+            VectorDensity: s.VectorDensity(),
+            Text:          s.Text,
+        })
+    }
+    return infos, nil
+}
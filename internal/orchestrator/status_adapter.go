@@ -42,3 +42,25 @@ func (a *redisStatusAdapter) SetFileJobMapping(ctx context.Context, fileID, jobI
 func (a *redisStatusAdapter) GetJobByFileID(ctx context.Context, fileID string) (string, error) {
     return a.s.GetJobByFileID(ctx, fileID)
 }
+
+func (a *redisStatusAdapter) InitPages(ctx context.Context, jobID string, total int) error {
+    return a.s.InitPages(ctx, jobID, total)
+}
+
+func (a *redisStatusAdapter) CompletePage(ctx context.Context, jobID string, page int, failed bool, message string) (PageProgress, error) {
+    p, err := a.s.CompletePage(ctx, jobID, page, failed, message)
+    if err != nil { return PageProgress{}, err }
+    return PageProgress{Recorded: p.Recorded, Done: p.Done, Failed: p.Failed, Total: p.Total, Progress: p.Progress, Finalize: p.Finalize}, nil
+}
+
+func (a *redisStatusAdapter) ClaimFinalize(ctx context.Context, jobID string) (bool, error) {
+    return a.s.ClaimFinalize(ctx, jobID)
+}
+
+func (a *redisStatusAdapter) ReleaseFinalize(ctx context.Context, jobID string) error {
+    return a.s.ReleaseFinalize(ctx, jobID)
+}
+
+func (a *redisStatusAdapter) PageCompleted(ctx context.Context, jobID string, page int) (bool, error) {
+    return a.s.PageCompleted(ctx, jobID, page)
+}
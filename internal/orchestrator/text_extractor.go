@@ -10,9 +10,10 @@ import (
 )
 
 // ExtractPageText uses go-fitz (MuPDF) to extract text for a given page (1-based page index).
-func ExtractPageText(ctx context.Context, fileRef string, page int) (string, error) {
+// password decrypts documents stored encrypted on S3 ("" for none).
+func ExtractPageText(ctx context.Context, fileRef, password string, page int) (string, error) {
     // ensure local PDF path
-    localPath, tmp, err := ensureLocalPDF(ctx, fileRef)
+    localPath, tmp, err := ensureLocalPDF(ctx, fileRef, password)
     if err != nil { return "", err }
     if tmp != "" { defer os.Remove(tmp) }
 
@@ -29,7 +30,8 @@ func ExtractPageText(ctx context.Context, fileRef string, page int) (string, err
 }
 
 // ensureLocalPDF returns a local file path for a PDF referenced by fileRef and an optional temp path to remove.
-func ensureLocalPDF(ctx context.Context, ref string) (string, string, error) {
+// password decrypts S3 objects that were stored encrypted.
+func ensureLocalPDF(ctx context.Context, ref, password string) (string, string, error) {
     if i := strings.Index(ref, "#"); i >= 0 { ref = ref[:i] }
     switch {
     case strings.HasPrefix(ref, "file://"):
@@ -38,7 +40,7 @@ func ensureLocalPDF(ctx context.Context, ref string) (string, string, error) {
         p, err := downloadHTTPToTemp(ctx, ref)
         return p, p, err
     case strings.HasPrefix(ref, "s3://"):
-        p, err := downloadS3ToTemp(ctx, ref, "") // TODO: pass password if needed
+        p, err := downloadS3ToTemp(ctx, ref, password)
         return p, p, err
     default:
         return ref, "", nil
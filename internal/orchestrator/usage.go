@@ -0,0 +1,52 @@
+package orchestrator
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "regexp"
+    "time"
+
+    mpkg "github.com/local/aidispatcher/internal/metrics"
+    "github.com/local/aidispatcher/internal/queue"
+    "github.com/local/aidispatcher/internal/store"
+    "github.com/rs/zerolog/log"
+)
+
+// recordUsage charges the usage of a newly recorded page to the job's client and user.
+// It runs once per page (after CompletePage recorded it), so redelivered results are not
+// counted twice.
+func (o *Orchestrator) recordUsage(ctx context.Context, res queue.PageResult) {
+    if res.TokensIn == 0 && res.TokensOut == 0 { return }
+    st, ok, err := o.deps.Status.Get(ctx, res.JobID)
+    if err != nil || !ok { return }
+    clientID, _ := st.Metadata["client_id"].(string)
+    user, _ := st.Metadata["user"].(string)
+    if clientID == "" { clientID = "anonymous" }
+    mpkg.AddClientUsage(clientID, res.TokensIn, res.TokensOut, res.CostUSD)
+    if o.deps.Usage == nil { return }
+    u := store.Usage{Pages: 1, TokensIn: res.TokensIn, TokensOut: res.TokensOut, CostUSD: res.CostUSD}
+    if err := o.deps.Usage.Add(ctx, time.Now(), clientID, user, u); err != nil {
+        log.Warn().Err(err).Str("job_id", res.JobID).Int("page_id", res.PageID).Str("client_id", clientID).Msg("usage counters not updated")
+    }
+}
+
+var monthRe = regexp.MustCompile(`^\d{4}-(0[1-9]|1[0-2])$`)
+
+// handleUsage returns usage and cost per API client and user for ?month=YYYY-MM
+// (default: the current month, UTC).
+func (o *Orchestrator) handleUsage(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet { w.WriteHeader(http.StatusMethodNotAllowed); return }
+    if o.deps.Usage == nil { http.Error(w, "usage unavailable", http.StatusServiceUnavailable); return }
+    month := r.URL.Query().Get("month")
+    if month == "" { month = store.UsageMonth(time.Now()) }
+    if !monthRe.MatchString(month) { http.Error(w, "month must be YYYY-MM", http.StatusBadRequest); return }
+    rep, err := o.deps.Usage.Report(r.Context(), month)
+    if err != nil {
+        log.Error().Err(err).Str("month", month).Msg("usage report failed")
+        http.Error(w, "error retrieving usage", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(rep)
+}
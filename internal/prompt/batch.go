@@ -0,0 +1,47 @@
+package prompt
+
+import (
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// pageMarkerRe matches the delimiter line BatchText asks the model to put before each page.
+var pageMarkerRe = regexp.MustCompile(`(?mi)^[ \t]*(?:#+[ \t]*)?=+[ \t]*PAGE[ \t]+(\d+)[ \t]*=+[ \t]*$`)
+
+// PageMarker is the delimiter line that starts the answer for page n in a batch.
+func PageMarker(n int) string { return fmt.Sprintf("=== PAGE %d ===", n) }
+
+// BatchText wraps a rendered single-page prompt for a request carrying several page
+// images, in the order of pages, and asks for one delimited section per page.
+func BatchText(single string, pages []int) string {
+    nums := make([]string, len(pages))
+    for i, p := range pages { nums[i] = strconv.Itoa(p) }
+    var b strings.Builder
+    fmt.Fprintf(&b, "The %d images are pages %s of the document, in this order.\n", len(pages), strings.Join(nums, ", "))
+    fmt.Fprintf(&b, "Handle every page separately. Start the output of each page with a line containing only its marker, e.g. %q, "+
+        "and write nothing before the first marker. Output every page, even an empty one.\n\n", PageMarker(pages[0]))
+    b.WriteString("Instructions for each page:\n")
+    b.WriteString(single)
+    return b.String()
+}
+
+// SplitPages splits a batch answer at the page markers. It returns the text of every
+// expected page it found; a page may be missing if the model stopped early. An answer
+// with text before the first marker, unknown or repeated pages is not trusted at all.
+func SplitPages(answer string, pages []int) map[int]string {
+    want := make(map[int]bool, len(pages))
+    for _, p := range pages { want[p] = true }
+    locs := pageMarkerRe.FindAllStringSubmatchIndex(answer, -1)
+    if len(locs) == 0 || strings.TrimSpace(answer[:locs[0][0]]) != "" { return nil }
+    out := make(map[int]string, len(locs))
+    for i, loc := range locs {
+        n, _ := strconv.Atoi(answer[loc[2]:loc[3]])
+        if _, dup := out[n]; dup || !want[n] { return nil }
+        end := len(answer)
+        if i+1 < len(locs) { end = locs[i+1][0] }
+        out[n] = strings.TrimSpace(answer[loc[1]:end])
+    }
+    return out
+}
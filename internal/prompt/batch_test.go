@@ -0,0 +1,51 @@
+package prompt
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestSplitPages(t *testing.T) {
+    pages := []int{3, 4, 5}
+    tests := []struct {
+        name   string
+        answer string
+        want   map[int]string
+    }{
+        {name: "all pages", answer: "=== PAGE 3 ===\nthree\n\n=== PAGE 4 ===\nfour\n=== PAGE 5 ===\n\nfive\n",
+            want: map[int]string{3: "three", 4: "four", 5: "five"}},
+        {name: "marker variants", answer: "  ## == page 3 ==\nthree\n=====PAGE 4=====  \nfour\n### === Page 5 ===\nfive",
+            want: map[int]string{3: "three", 4: "four", 5: "five"}},
+        {name: "empty page", answer: "=== PAGE 3 ===\nthree\n=== PAGE 4 ===\n=== PAGE 5 ===\nfive",
+            want: map[int]string{3: "three", 4: "", 5: "five"}},
+        {name: "model stopped early", answer: "\n=== PAGE 3 ===\nthree\n=== PAGE 4 ===\nfou",
+            want: map[int]string{3: "three", 4: "fou"}},
+        {name: "marker inside a line is text", answer: "=== PAGE 3 ===\nsee === PAGE 4 === below\n=== PAGE 4 ===\nfour",
+            want: map[int]string{3: "see === PAGE 4 === below", 4: "four"}},
+        {name: "text before the first marker", answer: "Here are the pages:\n=== PAGE 3 ===\nthree"},
+        {name: "no markers", answer: "three four five"},
+        {name: "unknown page", answer: "=== PAGE 3 ===\nthree\n=== PAGE 9 ===\nnine"},
+        {name: "repeated page", answer: "=== PAGE 3 ===\nthree\n=== PAGE 3 ===\nthree again"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := SplitPages(tt.answer, pages); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("SplitPages = %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestBatchTextRoundTrip(t *testing.T) {
+    pages := []int{7, 8}
+    text := BatchText("Transcribe the page.", pages)
+    if !strings.Contains(text, "pages 7, 8") || !strings.HasSuffix(text, "Transcribe the page.") {
+        t.Fatalf("BatchText = %q", text)
+    }
+    // an answer that follows the instructions to the letter must split cleanly
+    answer := PageMarker(7) + "\nseven\n" + PageMarker(8) + "\neight"
+    if got := SplitPages(answer, pages); !reflect.DeepEqual(got, map[int]string{7: "seven", 8: "eight"}) {
+        t.Errorf("SplitPages(PageMarker answer) = %q", got)
+    }
+}
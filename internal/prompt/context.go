@@ -0,0 +1,38 @@
+package prompt
+
+import (
+    "fmt"
+    "strings"
+    "unicode"
+)
+
+// charsPerToken is the rough text density also used for rate budget estimates.
+const charsPerToken = 4
+
+// Tail returns the end of text that fits in about tokens tokens, starting at a word
+// boundary, so it can be shown to the model as context for the next page.
+func Tail(text string, tokens int) string {
+    text = strings.TrimSpace(text)
+    if tokens <= 0 || text == "" { return "" }
+    rs := []rune(text)
+    max := tokens * charsPerToken
+    if len(rs) <= max { return text }
+    rs = rs[len(rs)-max:]
+    // drop the partial word (or line) the cut landed in
+    for i, r := range rs {
+        if unicode.IsSpace(r) { return strings.TrimSpace(string(rs[i:])) }
+    }
+    return string(rs)
+}
+
+// WithContext adds the end of the page before page to a rendered page prompt. The text is
+// context for sentences and tables continuing across the page break; it must not be
+// transcribed again.
+func WithContext(single, previous string, page int) string {
+    var b strings.Builder
+    b.WriteString(single)
+    fmt.Fprintf(&b, "\n\nFor context only, the previous page (page %d) ends with the text between <<< and >>>. "+
+        "Do not repeat it. If page %d continues a sentence or a table from it, start with the continuation as it "+
+        "appears on page %d and keep the table's columns.\n<<<\n%s\n>>>", page-1, page, page, previous)
+    return b.String()
+}
@@ -0,0 +1,25 @@
+package prompt
+
+import "testing"
+
+func TestTail(t *testing.T) {
+    tests := []struct {
+        name   string
+        text   string
+        tokens int
+        want   string
+    }{
+        {name: "disabled", text: "some text", tokens: 0, want: ""},
+        {name: "empty", text: "  \n", tokens: 10, want: ""},
+        {name: "fits", text: " short page \n", tokens: 10, want: "short page"},
+        {name: "cut at a word boundary", text: "first second third fourth", tokens: 4, want: "third fourth"},
+        {name: "cut at a line boundary", text: "| a | b |\n| 1 | 2 |\nlast line", tokens: 5, want: "| 1 | 2 |\nlast line"},
+        {name: "counts runes", text: "čćžšđ čćžšđ čćžšđ", tokens: 3, want: "čćžšđ čćžšđ"},
+        {name: "no boundary", text: "abcdefghijklmnop", tokens: 2, want: "ijklmnop"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := Tail(tt.text, tt.tokens); got != tt.want { t.Errorf("Tail(%q, %d) = %q, want %q", tt.text, tt.tokens, got, tt.want) }
+        })
+    }
+}
@@ -0,0 +1,229 @@
+package prompt
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "text/template"
+    "time"
+)
+
+// Built-in template names.
+const (
+    OCR       = "ocr"
+    OCRTables = "ocr_tables"
+    Translate = "translate"
+    Summarize = "summarize"
+    // Custom is recorded when a request supplies its own ai_prompt.
+    Custom = "custom"
+)
+
+// Vars are the variables available inside templates ({{.Page}}, {{.Language}}, ...).
+type Vars struct {
+    Page           int
+    TotalPages     int
+    Language       string // document language hint
+    TargetLanguage string // translation target
+}
+
+// Template is a named, versioned prompt.
+type Template struct {
+    Name    string
+    Version int
+    Text    string
+    tpl     *template.Template
+}
+
+// Rendered is the final prompt text plus what produced it (stored with each page).
+type Rendered struct {
+    Text    string
+    Name    string
+    Version int
+    Hash    string // short sha256 of the rendered text
+}
+
+var builtins = []struct{ name string; version int; text string }{
+    {OCR, 1, `You are an OCR engine. Extract all text from page {{.Page}}{{if .TotalPages}} of {{.TotalPages}}{{end}} of the document shown in the image.
+Preserve reading order, headings, lists and paragraph breaks.{{if .Language}} The document is written in {{.Language}}.{{end}}
+Do not translate, summarize or add commentary. Return only the extracted text.`},
+    {OCRTables, 1, `You are an OCR engine. Extract all text from page {{.Page}}{{if .TotalPages}} of {{.TotalPages}}{{end}} of the document shown in the image.
+Preserve reading order, headings, lists and paragraph breaks.{{if .Language}} The document is written in {{.Language}}.{{end}}
+Render every table as a GitHub-flavored Markdown table, keeping the original column order and merged header text.
+Do not translate, summarize or add commentary. Return only the extracted content.`},
+    {Translate, 1, `Extract all text from page {{.Page}}{{if .TotalPages}} of {{.TotalPages}}{{end}} of the document shown in the image{{if .Language}} (written in {{.Language}}){{end}} and translate it into {{if .TargetLanguage}}{{.TargetLanguage}}{{else}}English{{end}}.
+Keep the structure (headings, lists, tables as Markdown). Return only the translated text.`},
+    {Summarize, 1, `Summarize the content of page {{.Page}}{{if .TotalPages}} of {{.TotalPages}}{{end}} of the document shown in the image in a few concise bullet points{{if .TargetLanguage}} written in {{.TargetLanguage}}{{else if .Language}} written in {{.Language}}{{end}}.
+Include key figures, dates and names. Return only the summary.`},
+}
+
+// fileNameRe matches override files like "ocr.v2.tmpl".
+var fileNameRe = regexp.MustCompile(`^([a-z0-9_\-]+)\.v([0-9]+)\.tmpl$`)
+
+// Library holds all known templates keyed by name and version.
+type Library struct {
+    mu          sync.RWMutex
+    templates   map[string]map[int]*Template
+    defaultName string
+    dir         string
+    dirStamp    string
+}
+
+// NewLibrary returns a library seeded with the built-in templates.
+func NewLibrary(defaultName string) *Library {
+    if defaultName == "" { defaultName = OCR }
+    templates, err := builtinTemplates()
+    if err != nil { panic(err.Error()) }
+    return &Library{templates: templates, defaultName: defaultName}
+}
+
+func builtinTemplates() (map[string]map[int]*Template, error) {
+    templates := map[string]map[int]*Template{}
+    for _, b := range builtins {
+        if err := addTemplate(templates, b.name, b.version, b.text); err != nil {
+            return nil, fmt.Errorf("builtin prompt %s v%d: %w", b.name, b.version, err)
+        }
+    }
+    return templates, nil
+}
+
+func addTemplate(templates map[string]map[int]*Template, name string, version int, text string) error {
+    t, err := template.New(fmt.Sprintf("%s.v%d", name, version)).Option("missingkey=zero").Parse(text)
+    if err != nil { return err }
+    if templates[name] == nil { templates[name] = map[int]*Template{} }
+    templates[name][version] = &Template{Name: name, Version: version, Text: text, tpl: t}
+    return nil
+}
+
+// LoadDir (re)builds the library from the built-ins and the "<name>.v<version>.tmpl" files
+// in dir. Files override built-ins with the same name and version, and new versions become
+// the default for that name; templates whose files were deleted disappear. A missing
+// directory leaves only the built-ins. A file that fails to load aborts the reload and
+// keeps the current templates.
+func (l *Library) LoadDir(dir string) (int, error) {
+    if dir == "" { return 0, nil }
+    entries, err := os.ReadDir(dir)
+    if err != nil && !os.IsNotExist(err) { return 0, err }
+    templates, err := builtinTemplates()
+    if err != nil { return 0, err }
+    loaded := 0
+    var stamp []string
+    for _, e := range entries {
+        if e.IsDir() { continue }
+        m := fileNameRe.FindStringSubmatch(e.Name())
+        if m == nil { continue }
+        version, _ := strconv.Atoi(m[2])
+        b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+        if err != nil { return 0, err }
+        if err := addTemplate(templates, m[1], version, strings.TrimSpace(string(b))); err != nil {
+            return 0, fmt.Errorf("%s: %w", e.Name(), err)
+        }
+        if info, err := e.Info(); err == nil { stamp = append(stamp, e.Name()+"@"+info.ModTime().String()) }
+        loaded++
+    }
+    l.mu.Lock()
+    l.templates = templates
+    l.dir = dir
+    l.dirStamp = strings.Join(stamp, "|")
+    l.mu.Unlock()
+    return loaded, nil
+}
+
+// changed reports whether the override directory content differs from the last load.
+func (l *Library) changed() bool {
+    l.mu.RLock()
+    dir, prev := l.dir, l.dirStamp
+    l.mu.RUnlock()
+    if dir == "" { return false }
+    entries, err := os.ReadDir(dir)
+    if err != nil && !os.IsNotExist(err) { return false }
+    var stamp []string
+    for _, e := range entries {
+        if e.IsDir() || !fileNameRe.MatchString(e.Name()) { continue }
+        if info, err := e.Info(); err == nil { stamp = append(stamp, e.Name()+"@"+info.ModTime().String()) }
+    }
+    return strings.Join(stamp, "|") != prev
+}
+
+// Watch reloads the override directory every interval until stop is closed,
+// so templates can be tuned without redeploying.
+func (l *Library) Watch(interval time.Duration, stop <-chan struct{}, onErr func(error)) {
+    if interval <= 0 { return }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-stop:
+            return
+        case <-ticker.C:
+            if !l.changed() { continue }
+            l.mu.RLock()
+            dir := l.dir
+            l.mu.RUnlock()
+            if _, err := l.LoadDir(dir); err != nil && onErr != nil { onErr(err) }
+        }
+    }
+}
+
+// Get returns the template by name; version 0 selects the latest version.
+func (l *Library) Get(name string, version int) (*Template, error) {
+    if name == "" { name = l.defaultName }
+    l.mu.RLock()
+    defer l.mu.RUnlock()
+    versions, ok := l.templates[name]
+    if !ok || len(versions) == 0 { return nil, fmt.Errorf("unknown prompt template %q", name) }
+    if version > 0 {
+        t, ok := versions[version]
+        if !ok { return nil, fmt.Errorf("unknown version %d of prompt template %q", version, name) }
+        return t, nil
+    }
+    latest := 0
+    for v := range versions { if v > latest { latest = v } }
+    return versions[latest], nil
+}
+
+// Names returns the available template names with their versions, sorted.
+func (l *Library) Names() map[string][]int {
+    l.mu.RLock()
+    defer l.mu.RUnlock()
+    out := make(map[string][]int, len(l.templates))
+    for name, versions := range l.templates {
+        for v := range versions { out[name] = append(out[name], v) }
+        sort.Ints(out[name])
+    }
+    return out
+}
+
+// Render resolves a template and executes it with vars.
+func (l *Library) Render(name string, version int, vars Vars) (Rendered, error) {
+    t, err := l.Get(name, version)
+    if err != nil { return Rendered{}, err }
+    var buf bytes.Buffer
+    if err := t.tpl.Execute(&buf, vars); err != nil { return Rendered{}, fmt.Errorf("render %s v%d: %w", t.Name, t.Version, err) }
+    text := buf.String()
+    return Rendered{Text: text, Name: t.Name, Version: t.Version, Hash: shortHash(text)}, nil
+}
+
+// RenderCustom renders a per-request ai_prompt override. The override is client input and
+// is never executed as a template: only the placeholders {{.Page}}, {{.TotalPages}},
+// {{.Language}} and {{.TargetLanguage}} are replaced, everything else is used verbatim.
+func RenderCustom(text string, vars Vars) Rendered {
+    values := map[string]string{"Page": strconv.Itoa(vars.Page), "TotalPages": "", "Language": vars.Language, "TargetLanguage": vars.TargetLanguage}
+    if vars.TotalPages > 0 { values["TotalPages"] = strconv.Itoa(vars.TotalPages) }
+    pairs := make([]string, 0, 4*len(values))
+    for name, v := range values { pairs = append(pairs, "{{."+name+"}}", v, "{{ ."+name+" }}", v) }
+    out := strings.NewReplacer(pairs...).Replace(text)
+    return Rendered{Text: out, Name: Custom, Version: 0, Hash: shortHash(text)}
+}
+
+func shortHash(s string) string {
+    sum := sha256.Sum256([]byte(s))
+    return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,116 @@
+// Package quality checks AI transcriptions against the page's PDF text layer before they
+// are accepted, so refusals, empty answers and hallucinated pages fail over to another model.
+package quality
+
+import (
+    "regexp"
+    "strings"
+    "unicode"
+)
+
+// Reasons an answer is rejected.
+const (
+    ReasonEmpty         = "empty"
+    ReasonRefusal       = "refusal"
+    ReasonLowSimilarity = "low_similarity"
+)
+
+// Options controls when the text layer is compared and how close the answer must be.
+type Options struct {
+    MinSimilarity   float64 // share of the text layer's n-grams the answer must contain
+    MinTextChars    int     // shorter text layers (scans, near-empty pages) are not compared
+    MaxGarbageRatio float64 // text layers with more broken glyphs are not trusted
+}
+
+// Result is the verdict for one answer.
+type Result struct {
+    Score    float64 // similarity to the text layer, 0..1; only set when Compared
+    Compared bool    // the text layer was usable and the answer was compared with it
+    Reason   string  // why the answer was rejected; "" when accepted
+}
+
+// OK reports whether the answer was accepted.
+func (r Result) OK() bool { return r.Reason == "" }
+
+// ngram is the n-gram length of the similarity; trigrams tolerate OCR-level differences
+// (ligatures, hyphenation, markdown) while a different page shares few of them.
+const ngram = 3
+
+// Check validates answer, the model's text for a page whose text layer is layer ("" when
+// the page has none). Similarity is only checked when the answer is a transcription of
+// the page; pass layer "" for translations and custom prompts.
+func Check(answer, layer string, o Options) Result {
+    if strings.TrimSpace(answer) == "" { return Result{Reason: ReasonEmpty} }
+    if Refusal(answer, layer) { return Result{Reason: ReasonRefusal} }
+    if !usableLayer(layer, o) { return Result{} }
+    r := Result{Score: Similarity(answer, layer), Compared: true}
+    if r.Score < o.MinSimilarity { r.Reason = ReasonLowSimilarity }
+    return r
+}
+
+// Similarity is the share of layer's character n-grams (case, punctuation and whitespace
+// ignored) that also occur in answer. It measures how much of the page the answer covers,
+// so extra content such as image descriptions or table markup costs nothing.
+func Similarity(answer, layer string) float64 {
+    want := ngrams(normalize(layer))
+    if len(want) == 0 { return 0 }
+    have := ngrams(normalize(answer))
+    hit := 0
+    for g := range want {
+        if have[g] { hit++ }
+    }
+    return float64(hit) / float64(len(want))
+}
+
+// refusalRe matches the opening of a model refusing or apologising instead of transcribing.
+var refusalRe = regexp.MustCompile(`(?i)^\W*(i['’]?m sorry|i am sorry|sorry,|i cannot|i can['’]?t|i can not|i['’]?m unable|i am unable|` +
+    `i['’]?m not able|i am not able|unfortunately,? i|as an ai|žao mi je|nažalost,? ne mogu)`)
+
+// maxRefusalLen bounds refusals; a long answer that happens to start like one is a transcription.
+const maxRefusalLen = 600
+
+// Refusal reports whether answer reads as a refusal. An opening that is also found in the
+// text layer is the page's own text.
+func Refusal(answer, layer string) bool {
+    answer = strings.TrimSpace(answer)
+    if len([]rune(answer)) > maxRefusalLen { return false }
+    m := refusalRe.FindString(answer)
+    if m == "" { return false }
+    return layer == "" || !strings.Contains(normalize(layer), normalize(m))
+}
+
+func usableLayer(layer string, o Options) bool {
+    chars, garbage := 0, 0
+    for _, r := range layer {
+        if unicode.IsSpace(r) { continue }
+        chars++
+        if r == unicode.ReplacementChar || unicode.Is(unicode.Co, r) || unicode.IsControl(r) { garbage++ }
+    }
+    if chars == 0 || chars < o.MinTextChars { return false }
+    return o.MaxGarbageRatio <= 0 || float64(garbage)/float64(chars) <= o.MaxGarbageRatio
+}
+
+// normalize lower-cases s and keeps letters and digits, with single spaces between words.
+func normalize(s string) string {
+    var b strings.Builder
+    space := false
+    for _, r := range s {
+        if unicode.IsLetter(r) || unicode.IsDigit(r) {
+            if space && b.Len() > 0 { b.WriteByte(' ') }
+            b.WriteRune(unicode.ToLower(r))
+            space = false
+        } else {
+            space = true
+        }
+    }
+    return b.String()
+}
+
+func ngrams(s string) map[string]bool {
+    rs := []rune(s)
+    out := make(map[string]bool, len(rs))
+    for i := 0; i+ngram <= len(rs); i++ {
+        out[string(rs[i:i+ngram])] = true
+    }
+    return out
+}
@@ -0,0 +1,63 @@
+package quality
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestCheck(t *testing.T) {
+    layer := "Ugovor o zakupu poslovnog prostora sklopljen je između zakupodavca i zakupnika na rok od pet godina."
+    opts := Options{MinSimilarity: 0.6, MinTextChars: 20, MaxGarbageRatio: 0.2}
+    tests := []struct {
+        name     string
+        answer   string
+        layer    string
+        reason   string
+        compared bool
+    }{
+        {name: "empty", answer: " \n\t", layer: layer, reason: ReasonEmpty},
+        {name: "refusal", answer: "I'm sorry, but I can't help with that.", layer: layer, reason: ReasonRefusal},
+        {name: "refusal in Croatian", answer: "Nažalost, ne mogu pročitati ovu stranicu.", reason: ReasonRefusal},
+        {name: "refusal quoted from the page", answer: "Sorry, we are closed on Sundays and public holidays.",
+            layer: "Notice to customers. Sorry, we are closed on Sundays and public holidays.", compared: true},
+        {name: "long answer is not a refusal", answer: "I cannot stress this enough: " + strings.Repeat("rok plaćanja ", 60)},
+        {name: "transcription", answer: "# Ugovor o zakupu\n\nUgovor o **zakupu** poslovnog prostora sklopljen je između zakupodavca " +
+            "i zakup-\nnika na rok od pet godina.\n\n![potpis](sig.png)", layer: layer, compared: true},
+        {name: "different page", answer: "Račun broj 42 za isporučenu robu, rok plaćanja trideset dana.", layer: layer,
+            reason: ReasonLowSimilarity, compared: true},
+        {name: "no text layer", answer: "anything at all", layer: ""},
+        {name: "short text layer", answer: "anything at all", layer: "Str. 4"},
+        {name: "garbled text layer", answer: "anything at all", layer: "���������� broken glyphs here"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := Check(tt.answer, tt.layer, opts)
+            if got.Reason != tt.reason || got.Compared != tt.compared {
+                t.Errorf("Check = %+v, want reason %q compared %v", got, tt.reason, tt.compared)
+            }
+            if got.OK() != (tt.reason == "") { t.Errorf("OK() = %v with reason %q", got.OK(), got.Reason) }
+        })
+    }
+}
+
+func TestSimilarity(t *testing.T) {
+    tests := []struct {
+        name          string
+        answer, layer string
+        min, max      float64
+    }{
+        {name: "identical", answer: "Članak 5. Zakupnina", layer: "Članak 5. Zakupnina", min: 1, max: 1},
+        {name: "case, punctuation and markdown ignored", answer: "**ČLANAK 5** — zakupnina!", layer: "Članak 5. Zakupnina", min: 1, max: 1},
+        {name: "extra content costs nothing", answer: "Opis slike: logo. Članak 5. Zakupnina. Tablica.", layer: "Članak 5. Zakupnina", min: 1, max: 1},
+        {name: "half the page", answer: "prvi dio stranice", layer: "prvi dio stranice drugi dio ostaje", min: 0.4, max: 0.6},
+        {name: "unrelated", answer: "xyz qwv", layer: "Članak 5. Zakupnina", min: 0, max: 0},
+        {name: "empty layer", answer: "anything", layer: "", min: 0, max: 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := Similarity(tt.answer, tt.layer); got < tt.min || got > tt.max {
+                t.Errorf("Similarity = %.2f, want %.2f..%.2f", got, tt.min, tt.max)
+            }
+        })
+    }
+}
@@ -0,0 +1,168 @@
+package queue
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    redis "github.com/redis/go-redis/v9"
+)
+
+// DLQEntry is a page the workers gave up on, as stored in the pages DLQ stream.
+type DLQEntry struct {
+    ID        string          `json:"id"` // stream entry ID
+    At        time.Time       `json:"at"` // when the page was dead-lettered
+    Reason    string          `json:"reason"`
+    Error     string          `json:"error,omitempty"` // last error; empty for entries from before it was recorded
+    JobID     string          `json:"job_id"`
+    PageID    int             `json:"page_id"`
+    Attempt   int             `json:"attempt"`
+    AIEngine  string          `json:"ai_engine,omitempty"`
+    ForceFast bool            `json:"force_fast,omitempty"`
+    Payload   json.RawMessage `json:"payload,omitempty"` // the queue message; only set by GetDLQ
+    raw       []byte
+}
+
+// DLQFilter selects DLQ entries. Empty fields match everything; IDs, when set, are looked
+// up directly and the other fields still apply.
+type DLQFilter struct {
+    IDs    []string
+    JobID  string
+    Reason string
+    Since  time.Time
+    Until  time.Time
+    Limit  int // 0 = no limit
+}
+
+// Empty reports whether f selects every entry.
+func (f DLQFilter) Empty() bool {
+    return len(f.IDs) == 0 && f.JobID == "" && f.Reason == "" && f.Since.IsZero() && f.Until.IsZero()
+}
+
+func (f DLQFilter) match(e DLQEntry) bool {
+    if f.JobID != "" && e.JobID != f.JobID { return false }
+    if f.Reason != "" && e.Reason != f.Reason { return false }
+    // same millisecond bounds as the XRANGE scan, for entries looked up by ID
+    if !f.Since.IsZero() && e.At.UnixMilli() < f.Since.UnixMilli() { return false }
+    if !f.Until.IsZero() && e.At.UnixMilli() > f.Until.UnixMilli() { return false }
+    return true
+}
+
+// ReplayOptions override fields of replayed pages.
+type ReplayOptions struct {
+    AIEngine  string // "" keeps the page's engine
+    ForceFast *bool  // nil keeps the page's setting
+}
+
+// dlqScanBatch is the XRANGE page size used when scanning the DLQ.
+const dlqScanBatch = 500
+
+// ListDLQ returns the DLQ entries matching f, oldest first.
+func (q *RedisQueue) ListDLQ(ctx context.Context, f DLQFilter) ([]DLQEntry, error) {
+    var out []DLQEntry
+    if len(f.IDs) > 0 {
+        for _, id := range f.IDs {
+            msgs, err := q.client.XRange(ctx, q.DLQStream, id, id).Result()
+            if err != nil { return out, err }
+            for _, m := range msgs {
+                if e := parseDLQEntry(m); f.match(e) { out = append(out, e) }
+            }
+            if f.Limit > 0 && len(out) >= f.Limit { return out[:f.Limit], nil }
+        }
+        return out, nil
+    }
+    start, end := "-", "+"
+    if !f.Since.IsZero() { start = strconv.FormatInt(f.Since.UnixMilli(), 10) }
+    if !f.Until.IsZero() { end = strconv.FormatInt(f.Until.UnixMilli(), 10) }
+    for {
+        msgs, err := q.client.XRangeN(ctx, q.DLQStream, start, end, dlqScanBatch).Result()
+        if err != nil { return out, err }
+        for _, m := range msgs {
+            if e := parseDLQEntry(m); f.match(e) { out = append(out, e) }
+            if f.Limit > 0 && len(out) >= f.Limit { return out, nil }
+        }
+        if len(msgs) < dlqScanBatch { return out, nil }
+        start = "(" + msgs[len(msgs)-1].ID
+    }
+}
+
+// GetDLQ returns one entry with its queue message.
+func (q *RedisQueue) GetDLQ(ctx context.Context, id string) (DLQEntry, bool, error) {
+    msgs, err := q.client.XRange(ctx, q.DLQStream, id, id).Result()
+    if err != nil || len(msgs) == 0 { return DLQEntry{}, false, err }
+    e := parseDLQEntry(msgs[0])
+    if json.Valid(e.raw) { e.Payload = json.RawMessage(e.raw) }
+    return e, true, nil
+}
+
+// ReplayDLQ puts the matching entries back on the pages stream as fresh messages: attempt
+// starts again at 1, the idempotency key is cleared and "replay" is set so the orchestrator
+// refreshes the result of a job that already finished. Replayed entries leave the DLQ.
+func (q *RedisQueue) ReplayDLQ(ctx context.Context, f DLQFilter, o ReplayOptions) ([]DLQEntry, error) {
+    entries, err := q.ListDLQ(ctx, f)
+    if err != nil { return nil, err }
+    var done []DLQEntry
+    for _, e := range entries {
+        var payload map[string]any
+        if err := json.Unmarshal(e.raw, &payload); err != nil { return done, fmt.Errorf("entry %s: malformed payload: %w", e.ID, err) }
+        payload["attempt"] = 1
+        payload["replay"] = true
+        if o.AIEngine != "" { payload["ai_engine"] = o.AIEngine }
+        if o.ForceFast != nil { payload["force_fast"] = *o.ForceFast }
+        b, _ := json.Marshal(payload)
+        pipe := q.client.TxPipeline()
+        if key, _ := payload["idempotency_key"].(string); key != "" { pipe.Del(ctx, q.IdemDoneKey+key) }
+        pipe.XAdd(ctx, &redis.XAddArgs{Stream: q.Stream, Values: map[string]any{"data": string(b)}})
+        pipe.XDel(ctx, q.DLQStream, e.ID)
+        if _, err := pipe.Exec(ctx); err != nil { return done, fmt.Errorf("entry %s: %w", e.ID, err) }
+        done = append(done, e)
+    }
+    return done, nil
+}
+
+// PurgeDLQ deletes the matching entries and returns how many were removed.
+func (q *RedisQueue) PurgeDLQ(ctx context.Context, f DLQFilter) (int, error) {
+    entries, err := q.ListDLQ(ctx, f)
+    if err != nil || len(entries) == 0 { return 0, err }
+    ids := make([]string, len(entries))
+    for i, e := range entries { ids[i] = e.ID }
+    n, err := q.client.XDel(ctx, q.DLQStream, ids...).Result()
+    return int(n), err
+}
+
+func parseDLQEntry(m redis.XMessage) DLQEntry {
+    e := DLQEntry{ID: m.ID}
+    if ms, err := strconv.ParseInt(strings.SplitN(m.ID, "-", 2)[0], 10, 64); err == nil { e.At = time.UnixMilli(ms).UTC() }
+    e.Reason, _ = m.Values["reason"].(string)
+    e.Error, _ = m.Values["error"].(string)
+    data, _ := m.Values["data"].(string)
+    e.raw = []byte(data)
+    var p struct {
+        JobID     string `json:"job_id"`
+        PageID    any    `json:"page_id"`
+        Attempt   any    `json:"attempt"`
+        AIEngine  string `json:"ai_engine"`
+        ForceFast any    `json:"force_fast"`
+    }
+    if json.Unmarshal(e.raw, &p) == nil {
+        e.JobID, e.AIEngine = p.JobID, p.AIEngine
+        e.PageID, e.Attempt = anyInt(p.PageID), anyInt(p.Attempt)
+        e.ForceFast = p.ForceFast == true || p.ForceFast == "true"
+    }
+    return e
+}
+
+// anyInt reads numbers that producers wrote as JSON numbers or strings.
+func anyInt(v any) int {
+    switch t := v.(type) {
+    case float64:
+        return int(t)
+    case string:
+        n, _ := strconv.Atoi(t)
+        return n
+    }
+    return 0
+}
@@ -0,0 +1,99 @@
+package queue
+
+import (
+    "context"
+    "encoding/json"
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/alicebob/miniredis/v2"
+    redis "github.com/redis/go-redis/v9"
+)
+
+// newTestDLQ returns a queue whose DLQ holds one entry per second from 1s to 4s after the
+// epoch: pages 1 and 2 of job a, page 1 of job b, page 3 of job a.
+func newTestDLQ(t *testing.T) *RedisQueue {
+    t.Helper()
+    mr := miniredis.RunT(t)
+    q, err := NewRedisQueue("redis://"+mr.Addr(), "jobs:ai:pages", "workers", time.Hour)
+    if err != nil { t.Fatalf("NewRedisQueue: %v", err) }
+    t.Cleanup(func() { q.Close() })
+    entries := []struct {
+        id, job string
+        page    int
+        reason  string
+    }{
+        {"1000-0", "a", 1, "max_attempts"},
+        {"2000-0", "a", 2, "fatal"},
+        {"3000-0", "b", 1, "max_attempts"},
+        {"4000-0", "a", 3, "max_attempts"},
+    }
+    for _, e := range entries {
+        b, _ := json.Marshal(map[string]any{"job_id": e.job, "page_id": e.page, "attempt": 5, "idempotency_key": e.id})
+        args := &redis.XAddArgs{Stream: q.DLQStream, ID: e.id, Values: map[string]any{"data": string(b), "reason": e.reason, "error": "boom"}}
+        if err := q.client.XAdd(context.Background(), args).Err(); err != nil { t.Fatalf("XAdd: %v", err) }
+    }
+    return q
+}
+
+func TestListDLQ(t *testing.T) {
+    at := func(ms int64) time.Time { return time.UnixMilli(ms) }
+    tests := []struct {
+        name string
+        f    DLQFilter
+        want []string
+    }{
+        {name: "all", want: []string{"1000-0", "2000-0", "3000-0", "4000-0"}},
+        {name: "job", f: DLQFilter{JobID: "a"}, want: []string{"1000-0", "2000-0", "4000-0"}},
+        {name: "reason", f: DLQFilter{Reason: "fatal"}, want: []string{"2000-0"}},
+        {name: "job and reason", f: DLQFilter{JobID: "a", Reason: "max_attempts"}, want: []string{"1000-0", "4000-0"}},
+        {name: "since and until are inclusive", f: DLQFilter{Since: at(2000), Until: at(3000)}, want: []string{"2000-0", "3000-0"}},
+        {name: "sub-millisecond bounds", f: DLQFilter{Since: at(2000).Add(500 * time.Microsecond), Until: at(3000).Add(500 * time.Microsecond)},
+            want: []string{"2000-0", "3000-0"}},
+        {name: "limit", f: DLQFilter{JobID: "a", Limit: 2}, want: []string{"1000-0", "2000-0"}},
+        {name: "ids", f: DLQFilter{IDs: []string{"4000-0", "1000-0", "9000-0"}}, want: []string{"4000-0", "1000-0"}},
+        {name: "ids with job", f: DLQFilter{IDs: []string{"2000-0", "3000-0"}, JobID: "b"}, want: []string{"3000-0"}},
+        {name: "ids with since", f: DLQFilter{IDs: []string{"1000-0", "3000-0", "4000-0"}, Since: at(3000)}, want: []string{"3000-0", "4000-0"}},
+        {name: "ids with until", f: DLQFilter{IDs: []string{"1000-0", "3000-0"}, Until: at(2000)}, want: []string{"1000-0"}},
+        {name: "ids with limit", f: DLQFilter{IDs: []string{"1000-0", "2000-0", "3000-0"}, Limit: 2}, want: []string{"1000-0", "2000-0"}},
+        {name: "nothing matches", f: DLQFilter{JobID: "c"}},
+    }
+    q := newTestDLQ(t)
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            entries, err := q.ListDLQ(context.Background(), tt.f)
+            if err != nil { t.Fatalf("ListDLQ: %v", err) }
+            var got []string
+            for _, e := range entries { got = append(got, e.ID) }
+            if !reflect.DeepEqual(got, tt.want) { t.Errorf("ListDLQ(%+v) = %v, want %v", tt.f, got, tt.want) }
+        })
+    }
+}
+
+func TestParseDLQEntry(t *testing.T) {
+    q := newTestDLQ(t)
+    e, ok, err := q.GetDLQ(context.Background(), "2000-0")
+    if err != nil || !ok { t.Fatalf("GetDLQ = %v, %v", ok, err) }
+    if e.JobID != "a" || e.PageID != 2 || e.Attempt != 5 || e.Reason != "fatal" || e.Error != "boom" || !e.At.Equal(time.UnixMilli(2000)) {
+        t.Errorf("entry = %+v", e)
+    }
+    if len(e.Payload) == 0 { t.Errorf("GetDLQ did not return the payload") }
+}
+
+func TestReplayDLQ(t *testing.T) {
+    q := newTestDLQ(t)
+    ctx := context.Background()
+    if err := q.MarkIdemDone(ctx, "1000-0", time.Hour); err != nil { t.Fatalf("MarkIdemDone: %v", err) }
+    done, err := q.ReplayDLQ(ctx, DLQFilter{IDs: []string{"1000-0"}}, ReplayOptions{AIEngine: "anthropic"})
+    if err != nil || len(done) != 1 { t.Fatalf("ReplayDLQ = %d entries, %v", len(done), err) }
+    if left, _ := q.ListDLQ(ctx, DLQFilter{}); len(left) != 3 { t.Errorf("%d entries left in the DLQ, want 3", len(left)) }
+    if idem, _ := q.IsIdemDone(ctx, "1000-0"); idem { t.Errorf("idempotency key of the replayed page was kept") }
+    msgs, err := q.client.XRange(ctx, q.Stream, "-", "+").Result()
+    if err != nil || len(msgs) != 1 { t.Fatalf("pages stream = %v, %v", msgs, err) }
+    var p map[string]any
+    if err := json.Unmarshal([]byte(msgs[0].Values["data"].(string)), &p); err != nil { t.Fatalf("payload: %v", err) }
+    if p["attempt"] != float64(1) || p["replay"] != true || p["ai_engine"] != "anthropic" || p["job_id"] != "a" {
+        t.Errorf("replayed payload = %v", p)
+    }
+}
@@ -0,0 +1,168 @@
+package queue
+
+import (
+    "context"
+    "encoding/json"
+    "time"
+
+    redis "github.com/redis/go-redis/v9"
+    "github.com/rs/zerolog/log"
+
+    mpkg "github.com/local/aidispatcher/internal/metrics"
+)
+
+// reclaimerConsumer temporarily owns entries on their way to the DLQ.
+const reclaimerConsumer = "reclaimer"
+
+// ReclaimOptions controls recovery of entries stuck in the consumer group PEL
+// (a worker read them but crashed before Ack).
+type ReclaimOptions struct {
+    Interval         time.Duration // how often the PEL is scanned
+    MinIdle          time.Duration // entries idle longer than this are considered abandoned
+    MaxDeliveries    int64         // entries delivered this many times go to the DLQ
+    DeadConsumerIdle time.Duration // consumers idle this long with nothing pending are removed
+}
+
+// StartReclaimer runs the reclaimer until the queue is closed. Running it in several
+// processes is safe: XCLAIM with MinIdle lets only one of them take an entry.
+func (q *RedisQueue) StartReclaimer(opts ReclaimOptions) {
+    if opts.Interval <= 0 { opts.Interval = 30 * time.Second }
+    if opts.MinIdle <= 0 { opts.MinIdle = 5 * time.Minute }
+    if opts.MaxDeliveries <= 0 { opts.MaxDeliveries = 5 }
+    if opts.DeadConsumerIdle <= 0 { opts.DeadConsumerIdle = time.Hour }
+    go func() {
+        ticker := time.NewTicker(opts.Interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-q.stop:
+                return
+            case <-ticker.C:
+                ctx, cancel := context.WithTimeout(context.Background(), opts.Interval)
+                for _, t := range q.reclaimTargets() {
+                    if err := q.reclaimOnce(ctx, t, opts); err != nil {
+                        log.Warn().Err(err).Str("stream", t.stream).Msg("reclaimer pass failed")
+                    }
+                }
+                cancel()
+            }
+        }
+    }()
+}
+
+// reclaimTarget is a stream/group pair watched by the reclaimer.
+type reclaimTarget struct {
+    stream string
+    group  string
+    dlq    func(ctx context.Context, payload []byte, reason string) error
+}
+
+func (q *RedisQueue) reclaimTargets() []reclaimTarget {
+    return []reclaimTarget{
+        {stream: q.Stream, group: q.Group, dlq: func(ctx context.Context, payload []byte, reason string) error {
+            const lastErr = "delivered too many times without an ack"
+            // the job still waits for these pages: report them failed so it falls back to
+            // MuPDF text and finalizes, as it does for pages the workers give up on
+            for _, res := range abandonedResults(payload, lastErr) {
+                b, _ := json.Marshal(res)
+                if err := q.PublishResult(ctx, b); err != nil { return err }
+            }
+            return q.AddDLQ(ctx, payload, reason, lastErr)
+        }},
+        {stream: q.ResultsStream, group: q.ResultsGroup, dlq: q.AddResultDLQ},
+    }
+}
+
+// reclaimScanBatch is the XPENDING page size of a reclaimer pass.
+const reclaimScanBatch = 100
+
+func (q *RedisQueue) reclaimOnce(ctx context.Context, t reclaimTarget, opts ReclaimOptions) error {
+    // 1) scan the whole PEL for abandoned entries; those over the delivery cap go to the DLQ
+    var exhausted, stale []string
+    start := "-"
+    for {
+        pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+            Stream: t.stream, Group: t.group, Idle: opts.MinIdle, Start: start, End: "+", Count: reclaimScanBatch,
+        }).Result()
+        // an empty PEL may come back as a null reply
+        if err != nil && err != redis.Nil { return err }
+        for _, p := range pending {
+            if p.RetryCount >= opts.MaxDeliveries { exhausted = append(exhausted, p.ID) } else { stale = append(stale, p.ID) }
+        }
+        if len(pending) < reclaimScanBatch { break }
+        start = "(" + pending[len(pending)-1].ID
+    }
+    if len(exhausted) > 0 {
+        if err := q.deadLetter(ctx, t, exhausted, opts.MinIdle); err != nil { return err }
+    }
+
+    // 2) the rest, each checked against the delivery cap above, is handed to live consumers
+    // round-robin; they pick it up from their own PEL
+    consumers, err := q.client.XInfoConsumers(ctx, t.stream, t.group).Result()
+    if err != nil { return err }
+    var live []string
+    for _, c := range consumers {
+        if c.Name != reclaimerConsumer && c.Idle < opts.MinIdle { live = append(live, c.Name) }
+    }
+    if len(stale) > 0 && len(live) == 0 {
+        log.Warn().Str("stream", t.stream).Int("stale", len(stale)).Msg("abandoned entries pending but no live consumers to reclaim them")
+    }
+    for i := 0; len(live) > 0 && i < len(stale); i++ {
+        consumer := live[i%len(live)]
+        // MinIdle lets only one reclaimer take the entry, and skips it if a worker touched it since the scan
+        msgs, err := q.client.XClaim(ctx, &redis.XClaimArgs{
+            Stream: t.stream, Group: t.group, Consumer: consumer, MinIdle: opts.MinIdle, Messages: []string{stale[i]},
+        }).Result()
+        if err != nil { return err }
+        for _, m := range msgs {
+            mpkg.IncReclaimed("redelivered")
+            log.Info().Str("stream", t.stream).Str("msg_id", m.ID).Str("consumer", consumer).Msg("reclaimed abandoned entry")
+        }
+    }
+
+    // 3) forget consumers from dead processes once their PEL is empty
+    for _, c := range consumers {
+        if c.Pending == 0 && c.Idle > opts.DeadConsumerIdle {
+            if err := q.client.XGroupDelConsumer(ctx, t.stream, t.group, c.Name).Err(); err == nil {
+                log.Info().Str("stream", t.stream).Str("consumer", c.Name).Dur("idle", c.Idle).Msg("removed dead consumer")
+            }
+        }
+    }
+    return nil
+}
+
+// deadLetter claims ids for the reclaimer, copies them to the DLQ and acks them.
+func (q *RedisQueue) deadLetter(ctx context.Context, t reclaimTarget, ids []string, minIdle time.Duration) error {
+    msgs, err := q.client.XClaim(ctx, &redis.XClaimArgs{
+        Stream: t.stream, Group: t.group, Consumer: reclaimerConsumer, MinIdle: minIdle, Messages: ids,
+    }).Result()
+    if err != nil { return err }
+    for _, m := range msgs {
+        data, _ := m.Values["data"].(string)
+        if err := t.dlq(ctx, []byte(data), "max_deliveries"); err != nil { return err }
+        if err := q.client.XAck(ctx, t.stream, t.group, m.ID).Err(); err != nil { return err }
+        mpkg.IncReclaimed("dlq")
+        log.Warn().Str("stream", t.stream).Str("msg_id", m.ID).Msg("entry exceeded max deliveries; moved to DLQ")
+    }
+    return nil
+}
+
+// abandonedResults builds a failed result for every page of a pages-stream message: the
+// message's page_id and, for a batch, each entry of "pages".
+func abandonedResults(payload []byte, errMsg string) []PageResult {
+    var p struct {
+        JobID  string `json:"job_id"`
+        PageID any    `json:"page_id"`
+        Pages  []any  `json:"pages"`
+    }
+    if json.Unmarshal(payload, &p) != nil || p.JobID == "" { return nil }
+    pages := []int{anyInt(p.PageID)}
+    for _, v := range p.Pages {
+        if n := anyInt(v); n != pages[0] { pages = append(pages, n) }
+    }
+    var out []PageResult
+    for _, n := range pages {
+        if n > 0 { out = append(out, PageResult{JobID: p.JobID, PageID: n, Status: ResultFailed, Error: errMsg}) }
+    }
+    return out
+}
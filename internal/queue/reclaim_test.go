@@ -0,0 +1,93 @@
+package queue
+
+import (
+    "context"
+    "encoding/json"
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/alicebob/miniredis/v2"
+    redis "github.com/redis/go-redis/v9"
+)
+
+func TestDeadLetterReportsPagesFailed(t *testing.T) {
+    ctx := context.Background()
+    mr := miniredis.RunT(t)
+    now := time.Now()
+    mr.SetTime(now) // stream idle times follow the server clock, which FastForward does not move
+    q, err := NewRedisQueue("redis://"+mr.Addr(), "jobs:ai:pages", "workers", time.Hour)
+    if err != nil { t.Fatalf("NewRedisQueue: %v", err) }
+    defer q.Close()
+
+    batch, _ := json.Marshal(map[string]any{"job_id": "a", "page_id": 3, "pages": []int{3, 4}, "attempt": 1})
+    single, _ := json.Marshal(map[string]any{"job_id": "b", "page_id": 5, "attempt": 1})
+    for i, payload := range [][]byte{batch, single} {
+        if err := q.EnqueueAI(ctx, payload); err != nil { t.Fatal(err) }
+        // each page is read by a worker that dies before acking it
+        if _, data, err := q.DequeueAI(ctx, []string{"w1", "w2"}[i], time.Millisecond); err != nil || data == nil { t.Fatalf("DequeueAI: %v", err) }
+    }
+    mr.SetTime(now.Add(2 * time.Minute))
+    opts := ReclaimOptions{MinIdle: time.Minute, MaxDeliveries: 1, DeadConsumerIdle: time.Hour}
+    if err := q.reclaimOnce(ctx, q.reclaimTargets()[0], opts); err != nil { t.Fatalf("reclaimOnce: %v", err) }
+
+    var got []PageResult
+    msgs, _ := q.client.XRange(ctx, q.ResultsStream, "-", "+").Result()
+    for _, m := range msgs {
+        var r PageResult
+        _ = json.Unmarshal([]byte(m.Values["data"].(string)), &r)
+        got = append(got, r)
+    }
+    const errMsg = "delivered too many times without an ack"
+    want := []PageResult{
+        {JobID: "a", PageID: 3, Status: ResultFailed, Error: errMsg},
+        {JobID: "a", PageID: 4, Status: ResultFailed, Error: errMsg},
+        {JobID: "b", PageID: 5, Status: ResultFailed, Error: errMsg},
+    }
+    if !reflect.DeepEqual(got, want) { t.Errorf("results = %+v, want %+v", got, want) }
+
+    dlq, err := q.ListDLQ(ctx, DLQFilter{Reason: "max_deliveries"})
+    if err != nil || len(dlq) != 2 { t.Fatalf("DLQ = %+v, %v; want both messages", dlq, err) }
+    if pending, _ := q.client.XPending(ctx, q.Stream, q.Group).Result(); pending.Count != 0 { t.Errorf("%d entries still pending", pending.Count) }
+}
+
+func TestAbandonedResults(t *testing.T) {
+    for payload, want := range map[string][]int{
+        `{"job_id":"j","page_id":2}`:                  {2},
+        `{"job_id":"j","page_id":"2","pages":[2,3,4]}`: {2, 3, 4},
+        `{"job_id":"j","page_id":0}`:                  nil,
+        `{"page_id":1}`:                               nil,
+        `not json`:                                    nil,
+    } {
+        var pages []int
+        for _, r := range abandonedResults([]byte(payload), "x") {
+            if r.JobID != "j" || r.Status != ResultFailed || r.Error != "x" { t.Errorf("%s: result %+v", payload, r) }
+            pages = append(pages, r.PageID)
+        }
+        if !reflect.DeepEqual(pages, want) { t.Errorf("%s: pages %v, want %v", payload, pages, want) }
+    }
+}
+
+func TestTouchKeepsEntryFromReclaimer(t *testing.T) {
+    ctx := context.Background()
+    mr := miniredis.RunT(t)
+    now := time.Now()
+    mr.SetTime(now)
+    q, err := NewRedisQueue("redis://"+mr.Addr(), "jobs:ai:pages", "workers", time.Hour)
+    if err != nil { t.Fatalf("NewRedisQueue: %v", err) }
+    defer q.Close()
+    if err := q.EnqueueAI(ctx, []byte(`{"job_id":"a","page_id":1}`)); err != nil { t.Fatal(err) }
+    msgID, _, err := q.DequeueAI(ctx, "w1", time.Millisecond)
+    if err != nil || msgID == "" { t.Fatalf("DequeueAI: %q, %v", msgID, err) }
+
+    // the worker still holds the outcome and touches the entry while it retries the hand-off
+    mr.SetTime(now.Add(2 * time.Minute))
+    if err := q.Touch(ctx, "w1", msgID); err != nil { t.Fatalf("Touch: %v", err) }
+    if err := q.reclaimOnce(ctx, q.reclaimTargets()[0], ReclaimOptions{MinIdle: time.Minute, MaxDeliveries: 1, DeadConsumerIdle: time.Hour}); err != nil {
+        t.Fatalf("reclaimOnce: %v", err)
+    }
+    pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{Stream: q.Stream, Group: q.Group, Start: "-", End: "+", Count: 10}).Result()
+    if err != nil || len(pending) != 1 { t.Fatalf("pending = %+v, %v", pending, err) }
+    if p := pending[0]; p.Consumer != "w1" || p.RetryCount != 1 { t.Errorf("entry = %+v, want it still with w1 after one delivery", p) }
+    if n, _ := q.client.XLen(ctx, q.DLQStream).Result(); n != 0 { t.Errorf("touched entry was dead-lettered") }
+}
@@ -15,6 +15,9 @@ type RedisQueue struct {
     // streams / groups
     Stream       string
     Group        string
+    // page outcomes published by workers, consumed by the orchestrator
+    ResultsStream string
+    ResultsGroup  string
     // keys
     CancelKey    string
     DelayedKey   string
@@ -44,6 +47,8 @@ func NewRedisQueue(redisURL, stream, group string, poll time.Duration) (*RedisQu
         CancelKey:    "jobs:cancelled:set",
         DelayedKey:   stream + ":delayed",
         DLQStream:    stream + ":dlq",
+        ResultsStream: "jobs:ai:results",
+        ResultsGroup:  "orchestrator:results",
         IdemDoneKey:  "idem:done:",
         pollInterval: poll,
         stop:         make(chan struct{}),
@@ -52,6 +57,9 @@ func NewRedisQueue(redisURL, stream, group string, poll time.Duration) (*RedisQu
     if err := c.XGroupCreateMkStream(ctx, stream, group, "$").Err(); err != nil && !isBusyGroupErr(err) {
         return nil, fmt.Errorf("xgroup create: %w", err)
     }
+    if err := c.XGroupCreateMkStream(ctx, q.ResultsStream, q.ResultsGroup, "$").Err(); err != nil && !isBusyGroupErr(err) {
+        return nil, fmt.Errorf("xgroup create results: %w", err)
+    }
     // Start delayed mover
     go q.mover()
     return q, nil
@@ -84,32 +92,60 @@ func (q *RedisQueue) EnqueueDelayed(ctx context.Context, payload []byte, execute
     return q.client.ZAdd(ctx, q.DelayedKey, redis.Z{Score: float64(executeAt.Unix()), Member: string(payload)}).Err()
 }
 
-// DequeueAI reads one message from the consumer group and ACKs it immediately.
-// Note: ack-on-read mimics BRPOP semantics; retry/DLQ will be handled at higher layers.
+// DequeueAI reads one message for consumer. Entries already in the consumer's own PEL
+// (handed over by the reclaimer) are returned first, then new entries. The caller must Ack,
+// and must not call it while it still holds an unacked message: that one would be read again.
 func (q *RedisQueue) DequeueAI(ctx context.Context, consumer string, timeout time.Duration) (string, []byte, error) {
+    return q.dequeue(ctx, q.Stream, q.Group, consumer, timeout)
+}
+
+func (q *RedisQueue) dequeue(ctx context.Context, stream, group, consumer string, timeout time.Duration) (string, []byte, error) {
+    // own PEL first: non-blocking read from ID 0
+    msgID, data, found, err := q.readGroup(ctx, stream, group, consumer, "0", -1)
+    if err != nil || found { return msgID, data, err }
+    msgID, data, _, err = q.readGroup(ctx, stream, group, consumer, ">", timeout)
+    return msgID, data, err
+}
+
+func (q *RedisQueue) readGroup(ctx context.Context, stream, group, consumer, id string, block time.Duration) (string, []byte, bool, error) {
     res, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
-        Group:    q.Group,
+        Group:    group,
         Consumer: consumer,
-        Streams:  []string{q.Stream, ">"},
+        Streams:  []string{stream, id},
         Count:    1,
-        Block:    timeout,
+        Block:    block,
         NoAck:    false,
     }).Result()
     if err != nil {
-        if err == redis.Nil { return "", nil, nil }
-        return "", nil, err
+        if err == redis.Nil { return "", nil, false, nil }
+        return "", nil, false, err
     }
-    if len(res) == 0 || len(res[0].Messages) == 0 { return "", nil, nil }
+    if len(res) == 0 || len(res[0].Messages) == 0 { return "", nil, false, nil }
     msg := res[0].Messages[0]
     if v, ok := msg.Values["data"]; ok {
         switch t := v.(type) {
         case string:
-            return msg.ID, []byte(t), nil
+            return msg.ID, []byte(t), true, nil
         case []byte:
-            return msg.ID, t, nil
+            return msg.ID, t, true, nil
         }
     }
-    return msg.ID, nil, nil
+    // entry without payload (malformed or trimmed from the stream): ack so it does not block the PEL
+    _ = q.client.XAck(ctx, stream, group, msg.ID).Err()
+    return "", nil, true, nil
+}
+
+// Touch resets the idle time of msgID, pending for consumer, without counting a delivery,
+// so the reclaimer does not take a message whose worker still holds its outcome. A message
+// the reclaimer already moved to another consumer is left alone.
+func (q *RedisQueue) Touch(ctx context.Context, consumer, msgID string) error {
+    pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+        Stream: q.Stream, Group: q.Group, Start: msgID, End: msgID, Count: 1, Consumer: consumer,
+    }).Result()
+    if err == redis.Nil || (err == nil && len(pending) == 0) { return nil }
+    if err != nil { return err }
+    // RETRYCOUNT keeps the delivery count as it is
+    return q.client.Do(ctx, "XCLAIM", q.Stream, q.Group, consumer, 0, msgID, "RETRYCOUNT", pending[0].RetryCount, "JUSTID").Err()
 }
 
 // Ack marks a message as processed.
@@ -118,6 +154,30 @@ func (q *RedisQueue) Ack(ctx context.Context, msgID string) error {
     return q.client.XAck(ctx, q.Stream, q.Group, msgID).Err()
 }
 
+// PublishResult appends a page outcome to the results stream.
+func (q *RedisQueue) PublishResult(ctx context.Context, payload []byte) error {
+    return q.client.XAdd(ctx, &redis.XAddArgs{
+        Stream: q.ResultsStream,
+        Values: map[string]any{"data": string(payload)},
+    }).Err()
+}
+
+// DequeueResult reads one page outcome for an orchestrator consumer. The caller must AckResult.
+func (q *RedisQueue) DequeueResult(ctx context.Context, consumer string, timeout time.Duration) (string, []byte, error) {
+    return q.dequeue(ctx, q.ResultsStream, q.ResultsGroup, consumer, timeout)
+}
+
+// AckResult marks a page outcome as handled.
+func (q *RedisQueue) AckResult(ctx context.Context, msgID string) error {
+    if msgID == "" { return nil }
+    return q.client.XAck(ctx, q.ResultsStream, q.ResultsGroup, msgID).Err()
+}
+
+// AddResultDLQ parks a page outcome that could not be applied.
+func (q *RedisQueue) AddResultDLQ(ctx context.Context, payload []byte, reason string) error {
+    return q.client.XAdd(ctx, &redis.XAddArgs{Stream: q.ResultsStream + ":dlq", Values: map[string]any{"data": string(payload), "reason": reason}}).Err()
+}
+
 // CancelJob marks a job as cancelled. Workers should check this before processing.
 func (q *RedisQueue) CancelJob(ctx context.Context, jobID string) error {
     return q.client.SAdd(ctx, q.CancelKey, jobID).Err()
@@ -129,9 +189,9 @@ func (q *RedisQueue) IsCancelled(ctx context.Context, jobID string) (bool, error
     return res, err
 }
 
-// AddDLQ pushes a failed job to DLQ stream with reason.
-func (q *RedisQueue) AddDLQ(ctx context.Context, payload []byte, reason string) error {
-    return q.client.XAdd(ctx, &redis.XAddArgs{Stream: q.DLQStream, Values: map[string]any{"data": string(payload), "reason": reason}}).Err()
+// AddDLQ pushes a failed job to DLQ stream with reason and the last error.
+func (q *RedisQueue) AddDLQ(ctx context.Context, payload []byte, reason, lastErr string) error {
+    return q.client.XAdd(ctx, &redis.XAddArgs{Stream: q.DLQStream, Values: map[string]any{"data": string(payload), "reason": reason, "error": lastErr}}).Err()
 }
 
 // IsIdemDone returns true if idempotency key already marked done.
@@ -181,13 +241,33 @@ func (q *RedisQueue) moveOnce() {
     _, _ = pipe.Exec(ctx)
 }
 
-// Depths returns approximate stream/deferred/dlq lengths for metrics.
-func (q *RedisQueue) Depths(ctx context.Context) (int64, int64, int64, error) {
+// Depths holds approximate queue sizes for health checks and metrics.
+type Depths struct {
+    Stream            int64            `json:"stream_len"`
+    Delayed           int64            `json:"delayed_len"`
+    DLQ               int64            `json:"dlq_len"`
+    Pending           int64            `json:"pending"`
+    PendingByConsumer map[string]int64 `json:"pending_by_consumer"`
+    Results           int64            `json:"results_len"`
+    ResultsPending    int64            `json:"results_pending"`
+}
+
+// Depths returns approximate stream/deferred/dlq lengths and group pending counts.
+func (q *RedisQueue) Depths(ctx context.Context) (Depths, error) {
     pipe := q.client.Pipeline()
     xlen := pipe.XLen(ctx, q.Stream)
     zcard := pipe.ZCard(ctx, q.DelayedKey)
     dxlen := pipe.XLen(ctx, q.DLQStream)
+    xpending := pipe.XPending(ctx, q.Stream, q.Group)
+    rxlen := pipe.XLen(ctx, q.ResultsStream)
+    rpending := pipe.XPending(ctx, q.ResultsStream, q.ResultsGroup)
     _, err := pipe.Exec(ctx)
-    if err != nil { return 0, 0, 0, err }
-    return xlen.Val(), zcard.Val(), dxlen.Val(), nil
+    if err != nil { return Depths{}, err }
+    d := Depths{Stream: xlen.Val(), Delayed: zcard.Val(), DLQ: dxlen.Val(), PendingByConsumer: map[string]int64{}, Results: rxlen.Val()}
+    if p := xpending.Val(); p != nil {
+        d.Pending = p.Count
+        for c, n := range p.Consumers { d.PendingByConsumer[c] = n }
+    }
+    if p := rpending.Val(); p != nil { d.ResultsPending = p.Count }
+    return d, nil
 }
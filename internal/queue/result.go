@@ -0,0 +1,27 @@
+package queue
+
+// Page result statuses.
+const (
+    ResultDone   = "done"
+    ResultFailed = "failed" // AI gave up; orchestrator falls back to MuPDF text
+)
+
+// PageResult is a page outcome published by workers on the results stream.
+type PageResult struct {
+    JobID          string   `json:"job_id"`
+    PageID         int      `json:"page_id"`
+    Status         string   `json:"status"`
+    Text           string   `json:"text,omitempty"`
+    Provider       string   `json:"provider,omitempty"`
+    Model          string   `json:"model,omitempty"`
+    PromptTemplate string   `json:"prompt_template,omitempty"`
+    PromptVersion  int      `json:"prompt_version,omitempty"`
+    PromptHash     string   `json:"prompt_hash,omitempty"`
+    TokensIn       int      `json:"tokens_in,omitempty"`
+    TokensOut      int      `json:"tokens_out,omitempty"`
+    CostUSD        float64  `json:"cost_usd,omitempty"`
+    Quality        *float64 `json:"quality,omitempty"` // similarity to the text layer; nil when not compared
+    DurationMS     int64    `json:"duration_ms,omitempty"` // provider call time; a batch's pages share it
+    Error          string   `json:"error,omitempty"`
+    Replay         bool     `json:"replay,omitempty"` // the page was replayed from the DLQ
+}
@@ -0,0 +1,103 @@
+// Package result defines the JSON document stored as the result of a job, so consumers such
+// as Ghost Server can tell AI pages from MuPDF pages without parsing plain text.
+package result
+
+import (
+    "fmt"
+    "time"
+)
+
+// Schema and Version identify the document format. Fields may be added within a version;
+// renaming, removing or changing the meaning of a field needs a new version.
+const (
+    Schema  = "aidispatcher.result"
+    Version = 1
+)
+
+// Result formats a request can choose (result_format).
+const (
+    FormatText = "text" // aggregated plain text only, as before the document existed
+    FormatJSON = "json" // the document replaces the plain text
+    FormatBoth = "both" // plain text as before, the document stored next to it
+)
+
+// Page sources.
+const (
+    SourceAI    = "ai"
+    SourceMuPDF = "mupdf"
+)
+
+// ValidFormat checks a result_format value; "" selects the configured default.
+func ValidFormat(f string) error {
+    switch f {
+    case "", FormatText, FormatJSON, FormatBoth:
+        return nil
+    }
+    return fmt.Errorf("invalid result_format %q (text|json|both)", f)
+}
+
+// WantsDocument reports whether format includes the JSON document.
+func WantsDocument(format string) bool { return format == FormatJSON || format == FormatBoth }
+
+// WantsText reports whether format includes the plain text.
+func WantsText(format string) bool { return format != FormatJSON }
+
+// Document is the result of one job.
+type Document struct {
+    Schema     string     `json:"schema"`
+    Version    int        `json:"version"`
+    JobID      string     `json:"job_id"`
+    Source     Source     `json:"source"`
+    Mode       string     `json:"mode"`             // "ai" or "text_only"
+    Prompt     string     `json:"prompt,omitempty"` // template label, never the prompt text
+    StartedAt  *time.Time `json:"started_at,omitempty"`
+    FinishedAt time.Time  `json:"finished_at"`
+    DurationMS int64      `json:"duration_ms,omitempty"`
+    Summary    Summary    `json:"summary"`
+    Usage      *Usage     `json:"usage,omitempty"` // provider usage of the AI pages
+    Text       string     `json:"text"`            // all pages joined, as in the plain text result
+    Pages      []Page     `json:"pages"`
+    Warnings   []string   `json:"warnings,omitempty"`
+}
+
+// Source describes the processed document.
+type Source struct {
+    FilePath   string `json:"file_path,omitempty"` // s3:// or http(s):// reference of API jobs
+    FileName   string `json:"file_name,omitempty"` // uploaded file name
+    TotalPages int    `json:"total_pages"`
+}
+
+// Summary counts pages by outcome.
+type Summary struct {
+    AIPages       int            `json:"ai_pages"`
+    MuPDFPages    int            `json:"mupdf_pages"`
+    FallbackPages int            `json:"fallback_pages"` // MuPDF text used after AI processing failed
+    MissingPages  int            `json:"missing_pages"`
+    Selection     map[string]int `json:"selection,omitempty"` // page selection reason -> pages
+}
+
+// Usage is the provider consumption of the job.
+type Usage struct {
+    TokensIn  int     `json:"tokens_in"`
+    TokensOut int     `json:"tokens_out"`
+    CostUSD   float64 `json:"cost_usd"`
+}
+
+// Page is the result of one page. Source is "" for a page without a stored result.
+type Page struct {
+    Page           int        `json:"page"`
+    Source         string     `json:"source"`
+    Provider       string     `json:"provider,omitempty"`
+    Model          string     `json:"model,omitempty"`
+    PromptTemplate string     `json:"prompt_template,omitempty"`
+    PromptVersion  int        `json:"prompt_version,omitempty"`
+    PromptHash     string     `json:"prompt_hash,omitempty"`
+    TokensIn       int        `json:"tokens_in,omitempty"`
+    TokensOut      int        `json:"tokens_out,omitempty"`
+    CostUSD        float64    `json:"cost_usd,omitempty"`
+    Quality        *float64   `json:"quality,omitempty"`     // similarity to the PDF text layer, when compared
+    DurationMS     int64      `json:"duration_ms,omitempty"` // provider call time; shared by the pages of a batch
+    CompletedAt    *time.Time `json:"completed_at,omitempty"`
+    Text           string     `json:"text"`
+    Warnings       []string   `json:"warnings,omitempty"`
+}
@@ -0,0 +1,131 @@
+// Package secrets keeps short-lived per-job secrets (document passwords) out of job
+// status and queue payloads. Values are encrypted with a server key and stored under
+// their own Redis key with a TTL; everything else only carries an opaque reference.
+package secrets
+
+import (
+    "context"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+
+    redis "github.com/redis/go-redis/v9"
+)
+
+// ErrNotFound is returned for unknown, expired or wiped references.
+var ErrNotFound = errors.New("secret not found or expired")
+
+const refPrefix = "sec_"
+
+// Vault stores encrypted secrets in Redis.
+type Vault struct {
+    client *redis.Client
+    aead   cipher.AEAD
+    ttl    time.Duration
+}
+
+// NewVault connects to Redis and prepares AES-256-GCM with key (see ParseKey).
+func NewVault(redisURL string, key []byte, ttl time.Duration) (*Vault, error) {
+    if len(key) != 32 { return nil, fmt.Errorf("secret key must be 32 bytes, got %d", len(key)) }
+    if ttl <= 0 { ttl = 6 * time.Hour }
+    block, err := aes.NewCipher(key)
+    if err != nil { return nil, err }
+    aead, err := cipher.NewGCM(block)
+    if err != nil { return nil, err }
+    opt, err := redis.ParseURL(redisURL)
+    if err != nil { return nil, err }
+    c := redis.NewClient(opt)
+    if err := c.Ping(context.Background()).Err(); err != nil { return nil, err }
+    return &Vault{client: c, aead: aead, ttl: ttl}, nil
+}
+
+var (
+    processKeyOnce sync.Once
+    processKey     []byte
+    processKeyErr  error
+)
+
+// ErrNoKey is returned by ParseKey for an empty SECRET_KEY outside of development mode.
+var ErrNoKey = errors.New("SECRET_KEY is not set (SECRET_KEY_DEV=1 allows a random per-process key for development)")
+
+// minPassphraseLen rejects SECRET_KEY values too short to be a real secret.
+const minPassphraseLen = 16
+
+// ParseKey turns SECRET_KEY into a 32-byte key. 64 hex chars or base64 of 32 bytes are
+// used as-is; a passphrase of at least 16 characters is hashed. An empty value is an error
+// unless dev is set: then it yields a random key shared by all vaults of this process,
+// which only works when the same process both stores and reads secrets (cmd/app).
+func ParseKey(s string, dev bool) (key []byte, ephemeral bool, err error) {
+    s = strings.TrimSpace(s)
+    if s == "" {
+        if !dev { return nil, false, ErrNoKey }
+        processKeyOnce.Do(func() {
+            processKey = make([]byte, 32)
+            _, processKeyErr = rand.Read(processKey)
+        })
+        return processKey, true, processKeyErr
+    }
+    if b, err := hex.DecodeString(s); err == nil && len(b) == 32 { return b, false, nil }
+    if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == 32 { return b, false, nil }
+    if len(s) < minPassphraseLen {
+        return nil, false, fmt.Errorf("SECRET_KEY: expected 32 bytes as hex/base64 or a passphrase of at least %d characters", minPassphraseLen)
+    }
+    sum := sha256.Sum256([]byte(s))
+    return sum[:], false, nil
+}
+
+// Open builds a vault from SECRET_KEY/SECRET_KEY_DEV/SECRET_TTL values.
+func Open(redisURL, secretKey string, dev bool, ttl time.Duration) (*Vault, bool, error) {
+    key, ephemeral, err := ParseKey(secretKey, dev)
+    if err != nil { return nil, false, err }
+    v, err := NewVault(redisURL, key, ttl)
+    return v, ephemeral, err
+}
+
+func (v *Vault) Close() error { return v.client.Close() }
+
+func (v *Vault) key(ref string) string { return "secret:" + ref }
+
+// Put encrypts value and returns a new opaque reference to it.
+func (v *Vault) Put(ctx context.Context, value string) (string, error) {
+    id := make([]byte, 16)
+    if _, err := rand.Read(id); err != nil { return "", err }
+    ref := refPrefix + hex.EncodeToString(id)
+    nonce := make([]byte, v.aead.NonceSize())
+    if _, err := rand.Read(nonce); err != nil { return "", err }
+    // the reference is bound as additional data, so a ciphertext copied under another key fails to open
+    sealed := v.aead.Seal(nonce, nonce, []byte(value), []byte(ref))
+    if err := v.client.Set(ctx, v.key(ref), base64.StdEncoding.EncodeToString(sealed), v.ttl).Err(); err != nil {
+        return "", err
+    }
+    return ref, nil
+}
+
+// Get decrypts the secret behind ref.
+func (v *Vault) Get(ctx context.Context, ref string) (string, error) {
+    if !strings.HasPrefix(ref, refPrefix) { return "", ErrNotFound }
+    enc, err := v.client.Get(ctx, v.key(ref)).Result()
+    if err == redis.Nil { return "", ErrNotFound }
+    if err != nil { return "", err }
+    sealed, err := base64.StdEncoding.DecodeString(enc)
+    if err != nil { return "", fmt.Errorf("corrupt secret: %w", err) }
+    ns := v.aead.NonceSize()
+    if len(sealed) < ns { return "", fmt.Errorf("corrupt secret: too short") }
+    plain, err := v.aead.Open(nil, sealed[:ns], sealed[ns:], []byte(ref))
+    if err != nil { return "", fmt.Errorf("cannot decrypt secret (SECRET_KEY mismatch?): %w", err) }
+    return string(plain), nil
+}
+
+// Delete wipes the secret; deleting an unknown reference is not an error.
+func (v *Vault) Delete(ctx context.Context, ref string) error {
+    if !strings.HasPrefix(ref, refPrefix) { return nil }
+    return v.client.Del(ctx, v.key(ref)).Err()
+}
@@ -0,0 +1,78 @@
+package secrets
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/alicebob/miniredis/v2"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+    ctx := context.Background()
+    mr := miniredis.RunT(t)
+    key := bytes.Repeat([]byte{7}, 32)
+    v, err := NewVault("redis://"+mr.Addr(), key, time.Hour)
+    if err != nil { t.Fatal(err) }
+    defer v.Close()
+
+    ref, err := v.Put(ctx, "hunter2")
+    if err != nil { t.Fatal(err) }
+    if !strings.HasPrefix(ref, refPrefix) { t.Fatalf("ref %q has no %q prefix", ref, refPrefix) }
+    stored, err := mr.Get(v.key(ref))
+    if err != nil { t.Fatal(err) }
+    if strings.Contains(stored, "hunter2") { t.Errorf("plaintext stored in Redis: %q", stored) }
+    if got, err := v.Get(ctx, ref); err != nil || got != "hunter2" { t.Fatalf("Get = %q, %v", got, err) }
+
+    // a ciphertext copied under another reference must not open
+    other, err := v.Put(ctx, "other")
+    if err != nil { t.Fatal(err) }
+    mr.Set(v.key(other), stored)
+    if _, err := v.Get(ctx, other); err == nil { t.Errorf("ciphertext opened under a foreign reference") }
+
+    // another key cannot read it
+    w, err := NewVault("redis://"+mr.Addr(), bytes.Repeat([]byte{8}, 32), time.Hour)
+    if err != nil { t.Fatal(err) }
+    defer w.Close()
+    if _, err := w.Get(ctx, ref); err == nil || errors.Is(err, ErrNotFound) { t.Errorf("Get with the wrong key = %v, want a decrypt error", err) }
+
+    if err := v.Delete(ctx, ref); err != nil { t.Fatal(err) }
+    if _, err := v.Get(ctx, ref); !errors.Is(err, ErrNotFound) { t.Errorf("Get after Delete = %v, want ErrNotFound", err) }
+    if err := v.Delete(ctx, ref); err != nil { t.Errorf("second Delete = %v", err) }
+    if _, err := v.Get(ctx, "job-1"); !errors.Is(err, ErrNotFound) { t.Errorf("Get of a non-reference = %v", err) }
+}
+
+func TestVaultExpiry(t *testing.T) {
+    ctx := context.Background()
+    mr := miniredis.RunT(t)
+    v, err := NewVault("redis://"+mr.Addr(), bytes.Repeat([]byte{1}, 32), time.Minute)
+    if err != nil { t.Fatal(err) }
+    defer v.Close()
+    ref, err := v.Put(ctx, "pw")
+    if err != nil { t.Fatal(err) }
+    mr.FastForward(59 * time.Second)
+    if _, err := v.Get(ctx, ref); err != nil { t.Fatalf("Get before the TTL = %v", err) }
+    mr.FastForward(2 * time.Second)
+    if _, err := v.Get(ctx, ref); !errors.Is(err, ErrNotFound) { t.Errorf("Get after the TTL = %v, want ErrNotFound", err) }
+}
+
+func TestParseKey(t *testing.T) {
+    hexKey := strings.Repeat("ab", 32)
+    if k, eph, err := ParseKey(" "+hexKey+"\n", false); err != nil || eph || k[0] != 0xab || len(k) != 32 {
+        t.Errorf("hex key = %x %v %v", k, eph, err)
+    }
+    if k, _, err := ParseKey("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", false); err != nil || !bytes.Equal(k, make([]byte, 32)) {
+        t.Errorf("base64 key = %x %v", k, err)
+    }
+    a, _, err := ParseKey("correct horse battery staple", false)
+    if err != nil || len(a) != 32 { t.Fatalf("passphrase = %x %v", a, err) }
+    if b, _, _ := ParseKey("correct horse battery staple", false); !bytes.Equal(a, b) { t.Errorf("passphrase key is not stable") }
+    if _, _, err := ParseKey("short", false); err == nil { t.Errorf("short passphrase accepted") }
+    if _, _, err := ParseKey("", false); !errors.Is(err, ErrNoKey) { t.Errorf("empty key = %v, want ErrNoKey", err) }
+    d1, eph, err := ParseKey("", true)
+    if err != nil || !eph || len(d1) != 32 { t.Fatalf("dev key = %x %v %v", d1, eph, err) }
+    if d2, _, _ := ParseKey("", true); !bytes.Equal(d1, d2) { t.Errorf("dev key differs between calls") }
+}
@@ -3,6 +3,8 @@ package store
 import (
     "context"
     "fmt"
+    "strconv"
+    "time"
 
     redis "github.com/redis/go-redis/v9"
 )
@@ -25,24 +27,96 @@ func (s *PageStore) pageKey(jobID string, page int) string {
     return fmt.Sprintf("job:%s:page:%d", jobID, page)
 }
 
-func (s *PageStore) SavePageText(ctx context.Context, jobID string, page int, text, source, provider, model string) error {
-    m := map[string]interface{}{"text": text, "source": source}
-    if provider != "" { m["provider"] = provider }
-    if model != "" { m["model"] = model }
+// PageRecord is everything stored for one processed page.
+type PageRecord struct {
+    Text           string
+    Source         string // "ai"|"mupdf"
+    Provider       string
+    Model          string
+    PromptTemplate string
+    PromptVersion  int
+    PromptHash     string
+    TokensIn       int
+    TokensOut      int
+    CostUSD        float64
+    Quality        *float64  // similarity of the AI text to the text layer, when compared
+    DurationMS     int64     // time the provider call(s) took; shared by the pages of a batch
+    Warning        string    // e.g. why the AI result was replaced by MuPDF text
+    DoneAt         time.Time // set by SavePage
+}
+
+func (s *PageStore) SavePage(ctx context.Context, jobID string, page int, rec PageRecord) error {
+    m := map[string]interface{}{"text": rec.Text, "source": rec.Source}
+    if rec.Provider != "" { m["provider"] = rec.Provider }
+    if rec.Model != "" { m["model"] = rec.Model }
+    if rec.PromptTemplate != "" {
+        m["prompt_template"] = rec.PromptTemplate
+        m["prompt_version"] = rec.PromptVersion
+    }
+    if rec.PromptHash != "" { m["prompt_hash"] = rec.PromptHash }
+    if rec.TokensIn > 0 || rec.TokensOut > 0 {
+        m["tokens_in"] = rec.TokensIn
+        m["tokens_out"] = rec.TokensOut
+        m["cost_usd"] = strconv.FormatFloat(rec.CostUSD, 'f', -1, 64)
+    }
+    if rec.Quality != nil { m["quality"] = strconv.FormatFloat(*rec.Quality, 'f', 4, 64) }
+    if rec.DurationMS > 0 { m["duration_ms"] = rec.DurationMS }
+    if rec.Warning != "" { m["warning"] = rec.Warning }
+    m["done_at"] = time.Now().UTC().Format(time.RFC3339Nano)
     return s.client.HSet(ctx, s.pageKey(jobID, page), m).Err()
 }
 
+// PageRecords loads the records of pages 1..total; pages without a record have an empty Source.
+func (s *PageStore) PageRecords(ctx context.Context, jobID string, total int) ([]PageRecord, error) {
+    out := make([]PageRecord, total)
+    if total <= 0 { return out, nil }
+    pipe := s.client.Pipeline()
+    cmds := make([]*redis.MapStringStringCmd, 0, total)
+    for i := 1; i <= total; i++ {
+        cmds = append(cmds, pipe.HGetAll(ctx, s.pageKey(jobID, i)))
+    }
+    if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil { return out, err }
+    for i, c := range cmds {
+        out[i] = parsePageRecord(c.Val())
+    }
+    return out, nil
+}
+
+func parsePageRecord(h map[string]string) PageRecord {
+    rec := PageRecord{Text: h["text"], Source: h["source"], Provider: h["provider"], Model: h["model"],
+        PromptTemplate: h["prompt_template"], PromptHash: h["prompt_hash"], Warning: h["warning"]}
+    rec.PromptVersion, _ = strconv.Atoi(h["prompt_version"])
+    rec.TokensIn, _ = strconv.Atoi(h["tokens_in"])
+    rec.TokensOut, _ = strconv.Atoi(h["tokens_out"])
+    rec.CostUSD, _ = strconv.ParseFloat(h["cost_usd"], 64)
+    rec.DurationMS, _ = strconv.ParseInt(h["duration_ms"], 10, 64)
+    if q, err := strconv.ParseFloat(h["quality"], 64); err == nil { rec.Quality = &q }
+    rec.DoneAt, _ = time.Parse(time.RFC3339Nano, h["done_at"])
+    return rec
+}
+
+func (s *PageStore) SavePageText(ctx context.Context, jobID string, page int, text, source, provider, model string) error {
+    return s.SavePage(ctx, jobID, page, PageRecord{Text: text, Source: source, Provider: provider, Model: model})
+}
+
 func (s *PageStore) GetPageText(ctx context.Context, jobID string, page int) (string, error) {
     res, err := s.client.HGet(ctx, s.pageKey(jobID, page), "text").Result()
     if err == redis.Nil { return "", nil }
     return res, err
 }
 
-func (s *PageStore) AggregateText(ctx context.Context, jobID string, total int) (string, error) {
+// AggregateText joins the page texts of a job in page order. With stitch, page breaks that
+// split a sentence, a hyphenated word or a table are repaired (see stitch); otherwise pages
+// are separated by a blank line.
+func (s *PageStore) AggregateText(ctx context.Context, jobID string, total int, stitched bool) (string, error) {
     out := ""
     for i := 1; i <= total; i++ {
         t, err := s.GetPageText(ctx, jobID, i)
         if err != nil { return out, err }
+        if stitched {
+            out = stitch(out, t)
+            continue
+        }
         if t != "" {
             if out != "" { out += "\n\n" }
             out += t
@@ -51,3 +125,25 @@ func (s *PageStore) AggregateText(ctx context.Context, jobID string, total int)
     return out, nil
 }
 
+
+// JobUsage sums the usage stored with the pages of a job. Pages without usage
+// (MuPDF text, results from before usage tracking) are not counted.
+func (s *PageStore) JobUsage(ctx context.Context, jobID string, total int) (Usage, error) {
+    var u Usage
+    if total <= 0 { return u, nil }
+    pipe := s.client.Pipeline()
+    cmds := make([]*redis.SliceCmd, 0, total)
+    for i := 1; i <= total; i++ {
+        cmds = append(cmds, pipe.HMGet(ctx, s.pageKey(jobID, i), "tokens_in", "tokens_out", "cost_usd"))
+    }
+    if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil { return u, err }
+    for _, c := range cmds {
+        v := c.Val()
+        if len(v) != 3 || v[0] == nil { continue }
+        in, _ := strconv.Atoi(fmt.Sprint(v[0]))
+        out, _ := strconv.Atoi(fmt.Sprint(v[1]))
+        cost, _ := strconv.ParseFloat(fmt.Sprint(v[2]), 64)
+        u.Add(Usage{Pages: 1, TokensIn: in, TokensOut: out, CostUSD: cost})
+    }
+    return u, nil
+}
@@ -70,9 +70,122 @@ func (s *RedisStatus) Get(ctx context.Context, jobID string) (Status, bool, erro
     if v := res["metadata"]; v != "" {
         _ = json.Unmarshal([]byte(v), &st.Metadata)
     }
+    // page counters live in their own hash fields (see CompletePage); they win over
+    // whatever copy a previous Set wrote into the metadata JSON
+    for _, f := range []string{"total_pages", "pages_done", "pages_failed"} {
+        if v, ok := res[f]; ok && v != "" {
+            var n int
+            fmt.Sscan(v, &n)
+            if st.Metadata == nil { st.Metadata = map[string]interface{}{} }
+            st.Metadata[f] = n
+        }
+    }
     return st, true, nil
 }
 
+func (s *RedisStatus) pagesKey(jobID string) string { return fmt.Sprintf("%s:%s:pages", s.keyNS, jobID) }
+
+// PageProgress is the job state right after a page completion was recorded.
+type PageProgress struct {
+    Recorded bool // false when the page had already been completed (duplicate callback)
+    Done     int
+    Failed   int
+    Total    int
+    Progress int
+    Finalize bool // the caller took the finalize claim: all pages are in and nobody else holds it
+}
+
+// InitPages resets page accounting for a job. Must be called before any page is enqueued.
+func (s *RedisStatus) InitPages(ctx context.Context, jobID string, total int) error {
+    pipe := s.client.TxPipeline()
+    pipe.Del(ctx, s.pagesKey(jobID))
+    pipe.HSet(ctx, s.key(jobID), map[string]interface{}{"total_pages": total, "pages_done": 0, "pages_failed": 0})
+    pipe.Del(ctx, s.finalizingKey(jobID))
+    _, err := pipe.Exec(ctx)
+    return err
+}
+
+func (s *RedisStatus) finalizingKey(jobID string) string { return fmt.Sprintf("%s:%s:finalizing", s.keyNS, jobID) }
+
+// FinalizeLease bounds how long a claim on a job's terminal transition holds. A finalizer
+// that crashed or failed without releasing its claim blocks the job no longer than this; the
+// reclaimer redelivers its result later (after its MinIdle), which then finalizes the job.
+const FinalizeLease = 3 * time.Minute
+
+// claimLua takes the finalize lease unless the job already has a terminal status or someone
+// else holds the lease. KEYS[1]=status hash, KEYS[2]=lease key; ARGV[1]=lease in ms.
+const claimLua = `
+local function claim(status_key, lease_key, lease_ms)
+  local st = redis.call('HGET', status_key, 'status')
+  if st == 'success' or st == 'failed' or st == 'cancelled' then return 0 end
+  if redis.call('SET', lease_key, 1, 'NX', 'PX', lease_ms) then return 1 end
+  return 0
+end
+`
+
+var claimFinalizeScript = redis.NewScript(claimLua + `return claim(KEYS[1], KEYS[2], ARGV[1])`)
+
+// ClaimFinalize takes the job's terminal transition (finalize or cancel) for FinalizeLease.
+// Exactly one caller gets true: cancelling takes the claim so a late last page cannot mark
+// the job successful, and a job already finalizing cannot be cancelled. The claim ends with
+// the terminal status the owner writes, or with ReleaseFinalize when the owner failed.
+func (s *RedisStatus) ClaimFinalize(ctx context.Context, jobID string) (bool, error) {
+    n, err := claimFinalizeScript.Run(ctx, s.client, []string{s.key(jobID), s.finalizingKey(jobID)}, FinalizeLease.Milliseconds()).Int()
+    return n == 1, err
+}
+
+// ReleaseFinalize gives up a claim whose owner could not finish the job, so the next
+// completion of one of its pages (a redelivered result) can take it again.
+func (s *RedisStatus) ReleaseFinalize(ctx context.Context, jobID string) error {
+    return s.client.Del(ctx, s.finalizingKey(jobID)).Err()
+}
+
+// completePageScript marks a page in the bitmap and bumps the matching counter only the
+// first time, updates progress/message, and takes the finalize claim once all pages are
+// in. A repeated completion can take the claim too, when the finalizer that had it
+// released it or its lease ran out before the job got a terminal status.
+// KEYS[1]=status hash, KEYS[2]=page bitmap, KEYS[3]=lease key; ARGV[1]=page, ARGV[2]=counter
+// field, ARGV[3]=message, ARGV[4]=lease in ms
+var completePageScript = redis.NewScript(claimLua + `
+local first = redis.call('SETBIT', KEYS[2], ARGV[1], 1) == 0
+if first then
+  redis.call('HINCRBY', KEYS[1], ARGV[2], 1)
+end
+local done = tonumber(redis.call('HGET', KEYS[1], 'pages_done') or '0')
+local failed = tonumber(redis.call('HGET', KEYS[1], 'pages_failed') or '0')
+local total = tonumber(redis.call('HGET', KEYS[1], 'total_pages') or '0')
+local progress = 0
+if total > 0 then
+  progress = math.floor((done + failed) * 100 / total)
+  if progress > 100 then progress = 100 end
+end
+if first then
+  redis.call('HSET', KEYS[1], 'progress', progress, 'message', ARGV[3])
+end
+local finalize = 0
+if total > 0 and done + failed >= total then
+  finalize = claim(KEYS[1], KEYS[3], ARGV[4])
+end
+return {first and 1 or 0, done, failed, total, progress, finalize}
+`)
+
+// CompletePage atomically records that page finished (failed=true for MuPDF fallback).
+func (s *RedisStatus) CompletePage(ctx context.Context, jobID string, page int, failed bool, message string) (PageProgress, error) {
+    field := "pages_done"
+    if failed { field = "pages_failed" }
+    keys := []string{s.key(jobID), s.pagesKey(jobID), s.finalizingKey(jobID)}
+    res, err := completePageScript.Run(ctx, s.client, keys, page, field, message, FinalizeLease.Milliseconds()).Int64Slice()
+    if err != nil { return PageProgress{}, err }
+    if len(res) != 6 { return PageProgress{}, fmt.Errorf("unexpected complete page reply: %v", res) }
+    return PageProgress{Recorded: res[0] == 1, Done: int(res[1]), Failed: int(res[2]), Total: int(res[3]), Progress: int(res[4]), Finalize: res[5] == 1}, nil
+}
+
+// PageCompleted reports whether page was already recorded for the job.
+func (s *RedisStatus) PageCompleted(ctx context.Context, jobID string, page int) (bool, error) {
+    v, err := s.client.GetBit(ctx, s.pagesKey(jobID), int64(page)).Result()
+    return v == 1, err
+}
+
 func (s *RedisStatus) Close() error { return s.client.Close() }
 
 // SetFileJobMapping creates a mapping from file_id to job_id
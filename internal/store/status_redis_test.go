@@ -0,0 +1,148 @@
+package store
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/alicebob/miniredis/v2"
+)
+
+func newTestStatus(t *testing.T) *RedisStatus {
+    t.Helper()
+    mr := miniredis.RunT(t)
+    s, err := NewRedisStatus("redis://" + mr.Addr())
+    if err != nil { t.Fatalf("NewRedisStatus: %v", err) }
+    t.Cleanup(func() { s.Close() })
+    return s
+}
+
+func TestCompletePage(t *testing.T) {
+    type call struct {
+        page   int
+        failed bool
+        want   PageProgress
+    }
+    tests := []struct {
+        name  string
+        total int
+        calls []call
+    }{
+        {
+            name:  "last page finalizes",
+            total: 3,
+            calls: []call{
+                {page: 1, want: PageProgress{Recorded: true, Done: 1, Total: 3, Progress: 33}},
+                {page: 2, failed: true, want: PageProgress{Recorded: true, Done: 1, Failed: 1, Total: 3, Progress: 66}},
+                {page: 3, want: PageProgress{Recorded: true, Done: 2, Failed: 1, Total: 3, Progress: 100, Finalize: true}},
+            },
+        },
+        {
+            name:  "duplicate is not counted",
+            total: 2,
+            calls: []call{
+                {page: 1, want: PageProgress{Recorded: true, Done: 1, Total: 2, Progress: 50}},
+                {page: 1, want: PageProgress{Done: 1, Total: 2, Progress: 50}},
+                {page: 1, failed: true, want: PageProgress{Done: 1, Total: 2, Progress: 50}},
+            },
+        },
+        {
+            name:  "finalize only once",
+            total: 1,
+            calls: []call{
+                {page: 1, want: PageProgress{Recorded: true, Done: 1, Total: 1, Progress: 100, Finalize: true}},
+                {page: 1, want: PageProgress{Done: 1, Total: 1, Progress: 100}},
+                {page: 1, failed: true, want: PageProgress{Done: 1, Total: 1, Progress: 100}},
+            },
+        },
+        {
+            name:  "no pages never finalizes",
+            total: 0,
+            calls: []call{
+                {page: 1, want: PageProgress{Recorded: true, Done: 1}},
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestStatus(t)
+            ctx := context.Background()
+            if err := s.InitPages(ctx, "job", tt.total); err != nil { t.Fatalf("InitPages: %v", err) }
+            for i, c := range tt.calls {
+                got, err := s.CompletePage(ctx, "job", c.page, c.failed, "msg")
+                if err != nil { t.Fatalf("call %d: %v", i, err) }
+                if got != c.want { t.Errorf("call %d: got %+v, want %+v", i, got, c.want) }
+            }
+        })
+    }
+}
+
+func TestClaimFinalize(t *testing.T) {
+    tests := []struct {
+        name      string
+        completed bool // last page completed before the claim
+        want      bool
+    }{
+        {name: "cancel before the last page wins", want: true},
+        {name: "cancel after the last page loses", completed: true, want: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestStatus(t)
+            ctx := context.Background()
+            if err := s.InitPages(ctx, "job", 1); err != nil { t.Fatalf("InitPages: %v", err) }
+            if tt.completed {
+                if _, err := s.CompletePage(ctx, "job", 1, false, "done"); err != nil { t.Fatalf("CompletePage: %v", err) }
+            }
+            got, err := s.ClaimFinalize(ctx, "job")
+            if err != nil { t.Fatalf("ClaimFinalize: %v", err) }
+            if got != tt.want { t.Errorf("ClaimFinalize = %v, want %v", got, tt.want) }
+            if tt.completed { return }
+            // the page completing after the claim must not finalize the job again
+            p, err := s.CompletePage(ctx, "job", 1, false, "done")
+            if err != nil { t.Fatalf("CompletePage: %v", err) }
+            if p.Finalize { t.Errorf("page after the claim finalized the job") }
+        })
+    }
+}
+
+func TestFinalizeClaimHandOver(t *testing.T) {
+    ctx := context.Background()
+    tests := []struct {
+        name  string
+        after func(t *testing.T, mr *miniredis.Miniredis, s *RedisStatus) // runs once the last page holds the claim
+        want  bool                                                        // a repeated completion takes the claim
+    }{
+        {name: "claim held", after: func(*testing.T, *miniredis.Miniredis, *RedisStatus) {}, want: false},
+        {name: "claim released", after: func(t *testing.T, _ *miniredis.Miniredis, s *RedisStatus) {
+            if err := s.ReleaseFinalize(ctx, "job"); err != nil { t.Fatalf("ReleaseFinalize: %v", err) }
+        }, want: true},
+        {name: "finalizer crashed", after: func(_ *testing.T, mr *miniredis.Miniredis, _ *RedisStatus) {
+            mr.FastForward(FinalizeLease + time.Second)
+        }, want: true},
+        {name: "job finished", after: func(t *testing.T, mr *miniredis.Miniredis, s *RedisStatus) {
+            if err := s.Set(ctx, "job", Status{Status: "success", Progress: 100}); err != nil { t.Fatalf("Set: %v", err) }
+            mr.FastForward(FinalizeLease + time.Second)
+        }, want: false},
+        {name: "job cancelled", after: func(t *testing.T, _ *miniredis.Miniredis, s *RedisStatus) {
+            if err := s.Set(ctx, "job", Status{Status: "cancelled"}); err != nil { t.Fatalf("Set: %v", err) }
+            if err := s.ReleaseFinalize(ctx, "job"); err != nil { t.Fatalf("ReleaseFinalize: %v", err) }
+        }, want: false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            mr := miniredis.RunT(t)
+            s, err := NewRedisStatus("redis://" + mr.Addr())
+            if err != nil { t.Fatalf("NewRedisStatus: %v", err) }
+            defer s.Close()
+            if err := s.InitPages(ctx, "job", 1); err != nil { t.Fatalf("InitPages: %v", err) }
+            if p, err := s.CompletePage(ctx, "job", 1, false, "done"); err != nil || !p.Finalize { t.Fatalf("last page = %+v, %v", p, err) }
+            tt.after(t, mr, s)
+            p, err := s.CompletePage(ctx, "job", 1, false, "done")
+            if err != nil { t.Fatalf("CompletePage: %v", err) }
+            if p.Recorded || p.Finalize != tt.want { t.Errorf("repeated completion = %+v, want Finalize %v", p, tt.want) }
+            // whoever holds the claim now keeps cancel out
+            if ok, err := s.ClaimFinalize(ctx, "job"); err != nil || ok { t.Errorf("ClaimFinalize = %v, %v; want false", ok, err) }
+        })
+    }
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+    "regexp"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// minRepeatLen is the shortest line treated as the previous page's text repeated by the
+// model; shorter lines (page numbers, single words) legitimately recur.
+const minRepeatLen = 20
+
+var tableSeparatorRe = regexp.MustCompile(`^\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?$`)
+
+// stitch appends the text of the next page to the text aggregated so far and repairs what
+// the page break split: a line the model repeated from the previous page's context is
+// dropped, a table continued on the next page (with or without a repeated header) becomes
+// one table, a word hyphenated across the break is rejoined and a sentence continuing in
+// lower case is joined with a space. Anything else is separated by a blank line.
+func stitch(out, next string) string {
+    out = strings.TrimRightFunc(out, unicode.IsSpace)
+    next = strings.TrimSpace(next)
+    if out == "" { return next }
+    if next == "" { return out }
+    last, first := lastLine(out), firstLine(next)
+    if utf8.RuneCountInString(first) >= minRepeatLen && first == last {
+        _, rest, _ := strings.Cut(next, "\n")
+        if next = strings.TrimSpace(rest); next == "" { return out }
+        first = firstLine(next)
+    }
+    switch {
+    case isTableRow(last) && isTableRow(first):
+        rows := strings.SplitN(next, "\n", 3)
+        if len(rows) > 1 && tableSeparatorRe.MatchString(strings.TrimSpace(rows[1])) {
+            // a different header starts a new table
+            if !sameRow(rows[0], tableHeader(out)) { return out + "\n\n" + next }
+            if len(rows) < 3 { return out }
+            next = rows[2]
+        }
+        return out + "\n" + next
+    case hyphenated(last) && startsLower(first):
+        return strings.TrimSuffix(out, "-") + next
+    case openSentence(last) && startsLower(first):
+        return out + " " + next
+    }
+    return out + "\n\n" + next
+}
+
+func lastLine(s string) string {
+    if i := strings.LastIndexByte(s, '\n'); i >= 0 { s = s[i+1:] }
+    return strings.TrimSpace(s)
+}
+
+func firstLine(s string) string {
+    s, _, _ = strings.Cut(s, "\n")
+    return strings.TrimSpace(s)
+}
+
+func isTableRow(line string) bool { return len(line) > 1 && line[0] == '|' }
+
+// tableHeader returns the header row of the table s ends with, "" when it has none.
+func tableHeader(s string) string {
+    lines := strings.Split(s, "\n")
+    i := len(lines) - 1
+    for i > 0 && isTableRow(strings.TrimSpace(lines[i-1])) { i-- }
+    if i+1 < len(lines) && tableSeparatorRe.MatchString(strings.TrimSpace(lines[i+1])) { return strings.TrimSpace(lines[i]) }
+    return ""
+}
+
+// sameRow compares table rows ignoring cell padding.
+func sameRow(a, b string) bool {
+    return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+}
+
+// hyphenated reports a line ending in a word broken with a hyphen ("infor-").
+func hyphenated(line string) bool {
+    if !strings.HasSuffix(line, "-") || strings.HasSuffix(line, "--") { return false }
+    r, _ := utf8.DecodeLastRuneInString(strings.TrimSuffix(line, "-"))
+    return unicode.IsLetter(r)
+}
+
+// openSentence reports a prose line that stops mid-sentence.
+func openSentence(line string) bool {
+    if line == "" || line[0] == '#' || isTableRow(line) { return false }
+    r, _ := utf8.DecodeLastRuneInString(line)
+    return unicode.IsLetter(r) || unicode.IsDigit(r) || r == ','
+}
+
+func startsLower(line string) bool {
+    r, _ := utf8.DecodeRuneInString(line)
+    return unicode.IsLower(r)
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+    "context"
+    "testing"
+
+    "github.com/alicebob/miniredis/v2"
+)
+
+func TestStitch(t *testing.T) {
+    tests := []struct {
+        name      string
+        out, next string
+        want      string
+    }{
+        {name: "first page", out: "", next: "  Page one.\n", want: "Page one."},
+        {name: "empty page", out: "Page one.\n\n", next: " \n", want: "Page one."},
+        {name: "paragraph break", out: "End of page one.", next: "Start of page two.", want: "End of page one.\n\nStart of page two."},
+        {name: "sentence continues", out: "The tenant shall pay the rent", next: "on the first day of the month.",
+            want: "The tenant shall pay the rent on the first day of the month."},
+        {name: "sentence continues after a comma", out: "Zakupnik plaća zakupninu,", next: "režije i pričuvu.",
+            want: "Zakupnik plaća zakupninu, režije i pričuvu."},
+        {name: "capitalised line is a new paragraph", out: "The tenant shall pay the rent", next: "Article 5",
+            want: "The tenant shall pay the rent\n\nArticle 5"},
+        {name: "heading does not continue", out: "## Payment terms", next: "monthly in advance", want: "## Payment terms\n\nmonthly in advance"},
+        {name: "hyphenated word", out: "the agreement is valid for the dura-", next: "tion of the lease.",
+            want: "the agreement is valid for the duration of the lease."},
+        {name: "dash is not a hyphen", out: "Total --", next: "see below", want: "Total --\n\nsee below"},
+        {name: "repeated context line is dropped", out: "Intro.\nThis line was already on page one.",
+            next: "This line was already on page one.\nNew text.", want: "Intro.\nThis line was already on page one.\n\nNew text."},
+        {name: "short repeated line is kept", out: "Page 4", next: "Page 4\nText", want: "Page 4\n\nPage 4\nText"},
+        {name: "page holding only the repeated line", out: "This line was already on page one.",
+            next: "This line was already on page one.\n", want: "This line was already on page one."},
+        {name: "table continues without a header", out: "| a | b |\n|---|---|\n| 1 | 2 |", next: "| 3 | 4 |\n\nAfter.",
+            want: "| a | b |\n|---|---|\n| 1 | 2 |\n| 3 | 4 |\n\nAfter."},
+        {name: "repeated table header is dropped", out: "| a | b |\n|---|---|\n| 1 | 2 |", next: "|  a  |  b  |\n| :--- | ---: |\n| 3 | 4 |",
+            want: "| a | b |\n|---|---|\n| 1 | 2 |\n| 3 | 4 |"},
+        {name: "repeated header only", out: "| a | b |\n|---|---|\n| 1 | 2 |", next: "| a | b |\n|---|---|",
+            want: "| a | b |\n|---|---|\n| 1 | 2 |"},
+        {name: "different header starts a new table", out: "| a | b |\n|---|---|\n| 1 | 2 |", next: "| x | y |\n|---|---|\n| 3 | 4 |",
+            want: "| a | b |\n|---|---|\n| 1 | 2 |\n\n| x | y |\n|---|---|\n| 3 | 4 |"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := stitch(tt.out, tt.next); got != tt.want { t.Errorf("stitch(%q, %q) =\n%q\nwant\n%q", tt.out, tt.next, got, tt.want) }
+        })
+    }
+}
+
+func newTestPages(t *testing.T) *PageStore {
+    t.Helper()
+    mr := miniredis.RunT(t)
+    s, err := NewPageStore("redis://" + mr.Addr())
+    if err != nil { t.Fatalf("NewPageStore: %v", err) }
+    t.Cleanup(func() { s.Close() })
+    return s
+}
+
+func TestAggregateText(t *testing.T) {
+    pages := map[int]string{1: "The rent is due", 2: "monthly.", 4: "Annex."} // page 3 has no text
+    tests := []struct {
+        stitched bool
+        want     string
+    }{
+        {stitched: false, want: "The rent is due\n\nmonthly.\n\nAnnex."},
+        {stitched: true, want: "The rent is due monthly.\n\nAnnex."},
+    }
+    for _, tt := range tests {
+        ctx := context.Background()
+        s := newTestPages(t)
+        for p, text := range pages {
+            if err := s.SavePageText(ctx, "job", p, text, "ai", "openai", "gpt"); err != nil { t.Fatalf("SavePageText: %v", err) }
+        }
+        got, err := s.AggregateText(ctx, "job", 4, tt.stitched)
+        if err != nil { t.Fatalf("AggregateText: %v", err) }
+        if got != tt.want { t.Errorf("AggregateText(stitched=%v) = %q, want %q", tt.stitched, got, tt.want) }
+    }
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+    "context"
+    "strconv"
+    "strings"
+    "time"
+
+    redis "github.com/redis/go-redis/v9"
+)
+
+// Usage is provider consumption of a page, a job or a billing period.
+type Usage struct {
+    Pages     int     `json:"pages"`
+    TokensIn  int     `json:"tokens_in"`
+    TokensOut int     `json:"tokens_out"`
+    CostUSD   float64 `json:"cost_usd"`
+}
+
+// Add accumulates o into u.
+func (u *Usage) Add(o Usage) {
+    u.Pages += o.Pages
+    u.TokensIn += o.TokensIn
+    u.TokensOut += o.TokensOut
+    u.CostUSD += o.CostUSD
+}
+
+// UsageReport is the usage of one calendar month (UTC) by API client and by user.
+type UsageReport struct {
+    Month   string           `json:"month"`
+    Clients map[string]Usage `json:"clients"`
+    Users   map[string]Usage `json:"users"`
+}
+
+// UsageStore keeps monthly usage counters per API client and per user in
+// usage:{yyyy-mm}:client:{id} and usage:{yyyy-mm}:user:{name} hashes.
+type UsageStore struct {
+    client *redis.Client
+}
+
+// usageRetention keeps a month's counters long enough for yearly reconciliation.
+const usageRetention = 400 * 24 * time.Hour
+
+func NewUsageStore(redisURL string) (*UsageStore, error) {
+    opt, err := redis.ParseURL(redisURL)
+    if err != nil { return nil, err }
+    c := redis.NewClient(opt)
+    if err := c.Ping(context.Background()).Err(); err != nil { return nil, err }
+    return &UsageStore{client: c}, nil
+}
+
+func (s *UsageStore) Close() error { return s.client.Close() }
+
+// UsageMonth formats t as the month key used by UsageStore.
+func UsageMonth(t time.Time) string { return t.UTC().Format("2006-01") }
+
+// Add charges u to clientID and user in the month of at. Empty names are skipped.
+func (s *UsageStore) Add(ctx context.Context, at time.Time, clientID, user string, u Usage) error {
+    month := UsageMonth(at)
+    pipe := s.client.TxPipeline()
+    for kind, name := range map[string]string{"client": clientID, "user": user} {
+        if name == "" { continue }
+        key := "usage:" + month + ":" + kind + ":" + name
+        pipe.HIncrBy(ctx, key, "pages", int64(u.Pages))
+        pipe.HIncrBy(ctx, key, "tokens_in", int64(u.TokensIn))
+        pipe.HIncrBy(ctx, key, "tokens_out", int64(u.TokensOut))
+        pipe.HIncrByFloat(ctx, key, "cost_usd", u.CostUSD)
+        pipe.Expire(ctx, key, usageRetention)
+    }
+    _, err := pipe.Exec(ctx)
+    return err
+}
+
+// Report returns the counters of month ("2006-01").
+func (s *UsageStore) Report(ctx context.Context, month string) (UsageReport, error) {
+    rep := UsageReport{Month: month, Clients: map[string]Usage{}, Users: map[string]Usage{}}
+    prefix := "usage:" + month + ":"
+    iter := s.client.Scan(ctx, 0, prefix+"*", 200).Iterator()
+    for iter.Next(ctx) {
+        key := iter.Val()
+        kind, name, ok := strings.Cut(strings.TrimPrefix(key, prefix), ":")
+        if !ok { continue }
+        v, err := s.client.HGetAll(ctx, key).Result()
+        if err != nil { return rep, err }
+        var u Usage
+        u.Pages, _ = strconv.Atoi(v["pages"])
+        u.TokensIn, _ = strconv.Atoi(v["tokens_in"])
+        u.TokensOut, _ = strconv.Atoi(v["tokens_out"])
+        u.CostUSD, _ = strconv.ParseFloat(v["cost_usd"], 64)
+        switch kind {
+        case "client":
+            rep.Clients[name] = u
+        case "user":
+            rep.Users[name] = u
+        }
+    }
+    return rep, iter.Err()
+}
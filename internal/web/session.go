@@ -0,0 +1,140 @@
+package web
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/hex"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    redis "github.com/redis/go-redis/v9"
+)
+
+const (
+    sessionCookie = "session"
+    csrfHeader    = "X-CSRF-Token"
+    csrfField     = "csrf_token"
+)
+
+// session is a logged-in dashboard user. Sessions live in Redis under the SHA-256 of
+// the cookie token, so a leaked Redis dump cannot be replayed as cookies.
+type session struct {
+    User string
+    CSRF string
+}
+
+type sessionStore struct {
+    client *redis.Client
+    ttl    time.Duration
+}
+
+func (s *sessionStore) key(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return "web:session:" + hex.EncodeToString(sum[:])
+}
+
+// create starts a session for user and returns its cookie token.
+func (s *sessionStore) create(ctx context.Context, user string) (string, session, error) {
+    token, err := randomToken()
+    if err != nil { return "", session{}, err }
+    csrf, err := randomToken()
+    if err != nil { return "", session{}, err }
+    sess := session{User: user, CSRF: csrf}
+    k := s.key(token)
+    pipe := s.client.TxPipeline()
+    pipe.HSet(ctx, k, map[string]interface{}{"user": user, "csrf": csrf, "created": time.Now().Format(time.RFC3339)})
+    pipe.Expire(ctx, k, s.ttl)
+    if _, err := pipe.Exec(ctx); err != nil { return "", session{}, err }
+    return token, sess, nil
+}
+
+// get returns the session for token; expired or revoked sessions are not found.
+func (s *sessionStore) get(ctx context.Context, token string) (session, bool, error) {
+    if token == "" { return session{}, false, nil }
+    res, err := s.client.HGetAll(ctx, s.key(token)).Result()
+    if err != nil { return session{}, false, err }
+    if res["user"] == "" { return session{}, false, nil }
+    return session{User: res["user"], CSRF: res["csrf"]}, true, nil
+}
+
+func (s *sessionStore) revoke(ctx context.Context, token string) error {
+    if token == "" { return nil }
+    return s.client.Del(ctx, s.key(token)).Err()
+}
+
+func randomToken() (string, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil { return "", err }
+    return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validCSRF checks the token sent in the X-CSRF-Token header or csrf_token form field.
+func validCSRF(r *http.Request, sess session) bool {
+    got := r.Header.Get(csrfHeader)
+    if got == "" { got = r.FormValue(csrfField) }
+    return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(sess.CSRF)) == 1
+}
+
+// loginLimiter counts failed logins per client IP and per username in fixed windows.
+type loginLimiter struct {
+    client     *redis.Client
+    window     time.Duration
+    maxPerUser int64
+    maxPerIP   int64
+}
+
+func (l *loginLimiter) keys(ip, user string) (string, string) {
+    return "web:login_fail:ip:" + ip, "web:login_fail:user:" + strings.ToLower(user)
+}
+
+// blocked reports whether either counter reached its limit.
+func (l *loginLimiter) blocked(ctx context.Context, ip, user string) (bool, error) {
+    ipKey, userKey := l.keys(ip, user)
+    vals, err := l.client.MGet(ctx, ipKey, userKey).Result()
+    if err != nil { return false, err }
+    return counterAtLeast(vals[0], l.maxPerIP) || counterAtLeast(vals[1], l.maxPerUser), nil
+}
+
+func (l *loginLimiter) fail(ctx context.Context, ip, user string) error {
+    ipKey, userKey := l.keys(ip, user)
+    for _, k := range []string{ipKey, userKey} {
+        n, err := l.client.Incr(ctx, k).Result()
+        if err != nil { return err }
+        // the window starts at the first failure and is not extended by later ones
+        if n == 1 { _ = l.client.Expire(ctx, k, l.window).Err() }
+    }
+    return nil
+}
+
+// reset clears the username counter after a successful login.
+func (l *loginLimiter) reset(ctx context.Context, user string) error {
+    _, userKey := l.keys("", user)
+    return l.client.Del(ctx, userKey).Err()
+}
+
+func counterAtLeast(v interface{}, limit int64) bool {
+    s, _ := v.(string)
+    if s == "" || limit <= 0 { return false }
+    n, err := strconv.ParseInt(s, 10, 64)
+    return err == nil && n >= limit
+}
+
+// clientIP returns the request's client address; X-Forwarded-For is honoured only
+// behind a trusted proxy.
+func clientIP(r *http.Request, trustProxy bool) string {
+    if trustProxy {
+        if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+            ip, _, _ := strings.Cut(xff, ",")
+            return strings.TrimSpace(ip)
+        }
+    }
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil { return r.RemoteAddr }
+    return host
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+    "html/template"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/alicebob/miniredis/v2"
+    redis "github.com/redis/go-redis/v9"
+)
+
+// dashboard is a Web with stub templates behind a test server, and a browser that keeps
+// the session cookie and does not follow redirects.
+type dashboard struct {
+    srv    *httptest.Server
+    mr     *miniredis.Miniredis
+    cookie *http.Cookie
+}
+
+func newDashboard(t *testing.T) *dashboard {
+    t.Helper()
+    mr := miniredis.RunT(t)
+    client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+    t.Cleanup(func() { client.Close() })
+    users, err := newUserDB("", "admin", "correct horse")
+    if err != nil { t.Fatal(err) }
+    tpl := template.Must(template.New("login.html").Parse(`login {{.Error}}`))
+    template.Must(tpl.New("dashboard.html").Parse(`user={{.Username}} csrf={{.CSRFToken}}`))
+    w := &Web{tpl: tpl, users: users, sessions: &sessionStore{client: client, ttl: time.Hour},
+        limiter: &loginLimiter{client: client, window: 15 * time.Minute, maxPerUser: 3, maxPerIP: 12}}
+    mux := http.NewServeMux()
+    w.RegisterRoutes(mux)
+    d := &dashboard{srv: httptest.NewServer(mux), mr: mr}
+    t.Cleanup(d.srv.Close)
+    return d
+}
+
+func (d *dashboard) do(t *testing.T, method, path string, form url.Values, header map[string]string) (*http.Response, string) {
+    t.Helper()
+    var body io.Reader
+    if form != nil { body = strings.NewReader(form.Encode()) }
+    req, _ := http.NewRequest(method, d.srv.URL+path, body)
+    if form != nil { req.Header.Set("Content-Type", "application/x-www-form-urlencoded") }
+    for k, v := range header { req.Header.Set(k, v) }
+    if d.cookie != nil { req.AddCookie(d.cookie) }
+    client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+    resp, err := client.Do(req)
+    if err != nil { t.Fatal(err) }
+    defer resp.Body.Close()
+    b, _ := io.ReadAll(resp.Body)
+    for _, c := range resp.Cookies() {
+        if c.Name == sessionCookie && c.Value != "" { d.cookie = c }
+    }
+    return resp, string(b)
+}
+
+func (d *dashboard) login(t *testing.T, user, password string) string {
+    t.Helper()
+    resp, _ := d.do(t, http.MethodPost, "/web/login", url.Values{"username": {user}, "password": {password}}, nil)
+    return resp.Header.Get("Location")
+}
+
+func TestDashboardSession(t *testing.T) {
+    d := newDashboard(t)
+    if resp, _ := d.do(t, http.MethodGet, "/web/dashboard", nil, nil); resp.Header.Get("Location") != "/web/login" {
+        t.Fatalf("anonymous dashboard request: %d %s", resp.StatusCode, resp.Header.Get("Location"))
+    }
+    if loc := d.login(t, "admin", "wrong"); !strings.Contains(loc, "invalid+credentials") { t.Fatalf("bad password: %s", loc) }
+    if loc := d.login(t, "admin", "correct horse"); loc != "/web/dashboard" { t.Fatalf("login: %s", loc) }
+    if !d.cookie.HttpOnly || d.cookie.SameSite != http.SameSiteLaxMode { t.Errorf("session cookie %+v", d.cookie) }
+
+    resp, body := d.do(t, http.MethodGet, "/web/dashboard", nil, nil)
+    _, csrf, _ := strings.Cut(body, "csrf=")
+    if resp.StatusCode != http.StatusOK || !strings.HasPrefix(body, "user=admin") || csrf == "" { t.Fatalf("dashboard: %d %q", resp.StatusCode, body) }
+
+    // state changes need the session's CSRF token
+    for _, h := range []map[string]string{nil, {csrfHeader: "forged"}} {
+        if resp, _ := d.do(t, http.MethodPost, "/web/logout", nil, h); resp.StatusCode != http.StatusForbidden {
+            t.Errorf("logout with CSRF header %v: %d", h, resp.StatusCode)
+        }
+    }
+    stolen := *d.cookie
+    if resp, _ := d.do(t, http.MethodPost, "/web/logout", url.Values{csrfField: {csrf}}, nil); resp.Header.Get("Location") != "/web/login" {
+        t.Fatalf("logout: %d", resp.StatusCode)
+    }
+    // the session is gone server-side, not just the browser's cookie
+    d.cookie = &stolen
+    if resp, _ := d.do(t, http.MethodGet, "/web/dashboard", nil, nil); resp.Header.Get("Location") != "/web/login" {
+        t.Errorf("revoked session still accepted: %d", resp.StatusCode)
+    }
+
+    // sessions expire with their TTL
+    d.cookie = nil
+    d.login(t, "admin", "correct horse")
+    d.mr.FastForward(time.Hour)
+    if resp, _ := d.do(t, http.MethodGet, "/web/dashboard", nil, nil); resp.Header.Get("Location") != "/web/login" {
+        t.Errorf("expired session still accepted: %d", resp.StatusCode)
+    }
+}
+
+func TestLoginThrottling(t *testing.T) {
+    d := newDashboard(t)
+    for i := 0; i < 3; i++ { d.login(t, "admin", "guess") }
+    // the username is locked for the window, even with the right password
+    if loc := d.login(t, "admin", "correct horse"); !strings.Contains(loc, "too+many+attempts") { t.Fatalf("after 3 failures: %s", loc) }
+    d.mr.FastForward(15 * time.Minute)
+    if loc := d.login(t, "admin", "correct horse"); loc != "/web/dashboard" { t.Fatalf("after the window: %s", loc) }
+
+    // a successful login clears the username counter
+    d.login(t, "admin", "guess")
+    d.login(t, "admin", "guess")
+    d.login(t, "admin", "correct horse")
+    if loc := d.login(t, "admin", "guess"); !strings.Contains(loc, "invalid+credentials") { t.Errorf("counter not reset: %s", loc) }
+
+    // one address spraying many usernames hits the per-IP limit
+    d.mr.FastForward(15 * time.Minute)
+    for i := 0; i < 12; i++ { d.login(t, "user"+string(rune('a'+i)), "guess") }
+    if loc := d.login(t, "admin", "correct horse"); !strings.Contains(loc, "too+many+attempts") { t.Errorf("per-IP limit: %s", loc) }
+}
+
+func TestClientIP(t *testing.T) {
+    r := httptest.NewRequest(http.MethodGet, "/", nil)
+    r.RemoteAddr = "10.0.0.7:5123"
+    r.Header.Set("X-Forwarded-For", "203.0.113.9, 10.0.0.1")
+    if got := clientIP(r, false); got != "10.0.0.7" { t.Errorf("untrusted proxy: %s", got) }
+    if got := clientIP(r, true); got != "203.0.113.9" { t.Errorf("trusted proxy: %s", got) }
+}
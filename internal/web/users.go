@@ -0,0 +1,102 @@
+package web
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/rs/zerolog/log"
+    "golang.org/x/crypto/bcrypt"
+)
+
+// userDB holds bcrypt-hashed dashboard credentials.
+//
+// The users file has one "username:bcrypt-hash" per line (htpasswd -B format; '#' starts a
+// comment) and is re-read when its modification time changes. Without a file the single
+// WEB_USERNAME/WEB_PASSWORD pair is used, hashed in memory at startup.
+type userDB struct {
+    mu      sync.RWMutex
+    path    string
+    modTime time.Time
+    hashes  map[string][]byte
+    dummy   []byte // compared for unknown users so timing does not reveal which names exist
+}
+
+func newUserDB(path, envUser, envPassword string) (*userDB, error) {
+    dummy, _ := bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+    db := &userDB{path: path, hashes: map[string][]byte{}, dummy: dummy}
+    if path != "" {
+        if err := db.reload(); err != nil { return nil, err }
+        return db, nil
+    }
+    if envUser != "" && envPassword != "" {
+        h, err := bcrypt.GenerateFromPassword([]byte(envPassword), bcrypt.DefaultCost)
+        if err != nil { return nil, err }
+        db.hashes[envUser] = h
+    }
+    return db, nil
+}
+
+// reload reads the users file if it changed since the last read.
+func (db *userDB) reload() error {
+    fi, err := os.Stat(db.path)
+    if err != nil { return fmt.Errorf("users file: %w", err) }
+    db.mu.RLock()
+    same := fi.ModTime().Equal(db.modTime)
+    db.mu.RUnlock()
+    if same { return nil }
+
+    f, err := os.Open(db.path)
+    if err != nil { return fmt.Errorf("users file: %w", err) }
+    defer f.Close()
+    hashes := map[string][]byte{}
+    sc := bufio.NewScanner(f)
+    for n := 1; sc.Scan(); n++ {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") { continue }
+        user, hash, ok := strings.Cut(line, ":")
+        if !ok || user == "" {
+            return fmt.Errorf("users file %s:%d: expected username:bcrypt-hash", db.path, n)
+        }
+        if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+            return fmt.Errorf("users file %s:%d: %w", db.path, n, err)
+        }
+        hashes[user] = []byte(hash)
+    }
+    if err := sc.Err(); err != nil { return fmt.Errorf("users file: %w", err) }
+
+    db.mu.Lock()
+    db.hashes = hashes
+    db.modTime = fi.ModTime()
+    db.mu.Unlock()
+    log.Info().Str("file", db.path).Int("users", len(hashes)).Msg("dashboard users loaded")
+    return nil
+}
+
+// empty reports whether no credentials are configured.
+func (db *userDB) empty() bool {
+    db.mu.RLock()
+    defer db.mu.RUnlock()
+    return len(db.hashes) == 0
+}
+
+// authenticate checks username/password against the stored bcrypt hash.
+func (db *userDB) authenticate(username, password string) bool {
+    if db.path != "" {
+        if err := db.reload(); err != nil {
+            // keep serving the last good copy
+            log.Warn().Err(err).Msg("dashboard users reload failed")
+        }
+    }
+    db.mu.RLock()
+    hash, ok := db.hashes[username]
+    db.mu.RUnlock()
+    if !ok {
+        _ = bcrypt.CompareHashAndPassword(db.dummy, []byte(password))
+        return false
+    }
+    return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
@@ -14,36 +14,73 @@ import (
     "strings"
     "time"
 
+    redis "github.com/redis/go-redis/v9"
+    "github.com/rs/zerolog/log"
+
     "github.com/local/aidispatcher/internal/statuscheck"
 )
 
 type Web struct {
-    tpl       *template.Template
-    username  string
-    password  string
-    port      string
-    status    *statuscheck.Checker
+    tpl        *template.Template
+    users      *userDB
+    sessions   *sessionStore
+    limiter    *loginLimiter
+    secure     bool // Secure flag on the session cookie (HTTPS deployments)
+    trustProxy bool
+    apiKey     string // sent by the proxies below to the orchestrator API
+    port       string
+    status     *statuscheck.Checker
+}
+
+// Options configures dashboard authentication.
+type Options struct {
+    Status           *statuscheck.Checker
+    RedisURL         string
+    UsersFile        string // "username:bcrypt-hash" lines; empty = Username/Password below
+    Username         string
+    Password         string
+    SessionTTL       time.Duration
+    CookieSecure     bool
+    LoginWindow      time.Duration
+    LoginMaxAttempts int // failed logins per username per window; per IP it is 4x
+    TrustProxy       bool
+    APIKey           string // key the dashboard proxies use against the API (see auth.IssueKey)
 }
 
-func New(status *statuscheck.Checker) *Web {
+type ctxKey struct{}
+
+func New(opts Options) (*Web, error) {
     // load templates
     tpl := template.Must(template.ParseGlob(filepath.Join("web", "templates", "*.html")))
+    users, err := newUserDB(opts.UsersFile, opts.Username, opts.Password)
+    if err != nil { return nil, err }
+    ropt, err := redis.ParseURL(opts.RedisURL)
+    if err != nil { return nil, err }
+    client := redis.NewClient(ropt)
+    if opts.SessionTTL <= 0 { opts.SessionTTL = 12 * time.Hour }
+    if opts.LoginWindow <= 0 { opts.LoginWindow = 15 * time.Minute }
+    if opts.LoginMaxAttempts <= 0 { opts.LoginMaxAttempts = 5 }
     return &Web{
-        tpl:      tpl,
-        username: os.Getenv("WEB_USERNAME"),
-        password: os.Getenv("WEB_PASSWORD"),
-        port:     getenv("PORT", "8080"),
-        status:   status,
-    }
+        tpl:        tpl,
+        users:      users,
+        sessions:   &sessionStore{client: client, ttl: opts.SessionTTL},
+        limiter:    &loginLimiter{client: client, window: opts.LoginWindow, maxPerUser: int64(opts.LoginMaxAttempts), maxPerIP: int64(opts.LoginMaxAttempts) * 4},
+        secure:     opts.CookieSecure,
+        trustProxy: opts.TrustProxy,
+        apiKey:     opts.APIKey,
+        port:       getenv("PORT", "8080"),
+        status:     opts.Status,
+    }, nil
 }
 
 func (w *Web) RegisterRoutes(mux *http.ServeMux) {
     mux.HandleFunc("/web/login", w.handleLogin)
-    mux.HandleFunc("/web/logout", w.handleLogout)
+    mux.HandleFunc("/web/logout", w.requireAuth(w.requireCSRF(w.handleLogout)))
     mux.HandleFunc("/web/system_status", w.requireAuth(w.handleSystemStatus))
-    mux.HandleFunc("/web/process", w.requireAuth(w.handleProcess))
-    mux.HandleFunc("/web/upload", w.requireAuth(w.handleUpload))
+    mux.HandleFunc("/web/process", w.requireAuth(w.requireCSRF(w.handleProcess)))
+    mux.HandleFunc("/web/upload", w.requireAuth(w.requireCSRF(w.handleUpload)))
     mux.HandleFunc("/web/progress/", w.requireAuth(w.handleProgress))
+    mux.HandleFunc("/web/download_result/", w.requireAuth(w.handleDownloadResult))
     mux.HandleFunc("/web/dashboard", w.requireAuth(w.handleDashboard))
     mux.HandleFunc("/web/", w.requireAuth(w.handleDashboard))
 }
@@ -52,46 +89,103 @@ func (w *Web) render(wr http.ResponseWriter, name string, data any) {
     _ = w.tpl.ExecuteTemplate(wr, name, data)
 }
 
+// requireAuth resolves the session cookie; the session is available via sessionFrom.
 func (w *Web) requireAuth(next http.HandlerFunc) http.HandlerFunc {
     return func(wr http.ResponseWriter, r *http.Request) {
-        if w.username == "" || w.password == "" {
-            http.Error(wr, "WEB_USERNAME/WEB_PASSWORD not set", http.StatusForbidden)
+        if w.users.empty() {
+            http.Error(wr, "no dashboard users configured (WEB_USERS_FILE or WEB_USERNAME/WEB_PASSWORD)", http.StatusForbidden)
+            return
+        }
+        token := ""
+        if c, err := r.Cookie(sessionCookie); err == nil { token = c.Value }
+        sess, ok, err := w.sessions.get(r.Context(), token)
+        if err != nil {
+            log.Error().Err(err).Msg("session lookup failed")
+            http.Error(wr, "session store unavailable", http.StatusServiceUnavailable)
             return
         }
-        c, err := r.Cookie("auth")
-        if err != nil || c.Value != "1" {
+        if !ok {
             http.Redirect(wr, r, "/web/login", http.StatusSeeOther)
             return
         }
+        next(wr, r.WithContext(context.WithValue(r.Context(), ctxKey{}, sess)))
+    }
+}
+
+// requireCSRF rejects state-changing requests without the session's CSRF token.
+func (w *Web) requireCSRF(next http.HandlerFunc) http.HandlerFunc {
+    return func(wr http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost { wr.WriteHeader(http.StatusMethodNotAllowed); return }
+        if !validCSRF(r, sessionFrom(r)) {
+            http.Error(wr, "invalid CSRF token", http.StatusForbidden)
+            return
+        }
         next(wr, r)
     }
 }
 
+func sessionFrom(r *http.Request) session {
+    sess, _ := r.Context().Value(ctxKey{}).(session)
+    return sess
+}
+
 func (w *Web) handleLogin(wr http.ResponseWriter, r *http.Request) {
     switch r.Method {
     case http.MethodGet:
         w.render(wr, "login.html", map[string]any{"Error": r.URL.Query().Get("error")})
     case http.MethodPost:
         if err := r.ParseForm(); err != nil { http.Redirect(wr, r, "/web/login?error=invalid+form", http.StatusSeeOther); return }
-        if r.Form.Get("username") == w.username && r.Form.Get("password") == w.password {
-            http.SetCookie(wr, &http.Cookie{Name: "auth", Value: "1", Path: "/", HttpOnly: true})
-            http.Redirect(wr, r, "/web/dashboard", http.StatusSeeOther)
+        username := r.Form.Get("username")
+        ip := clientIP(r, w.trustProxy)
+        if blocked, err := w.limiter.blocked(r.Context(), ip, username); err != nil {
+            log.Error().Err(err).Msg("login limiter unavailable")
+            http.Redirect(wr, r, "/web/login?error=service+unavailable", http.StatusSeeOther)
+            return
+        } else if blocked {
+            log.Warn().Str("ip", ip).Str("user", username).Msg("dashboard login rate limited")
+            http.Redirect(wr, r, "/web/login?error=too+many+attempts,+try+again+later", http.StatusSeeOther)
             return
         }
-        http.Redirect(wr, r, "/web/login?error=invalid+credentials", http.StatusSeeOther)
+        if !w.users.authenticate(username, r.Form.Get("password")) {
+            _ = w.limiter.fail(r.Context(), ip, username)
+            log.Warn().Str("ip", ip).Str("user", username).Msg("dashboard login failed")
+            http.Redirect(wr, r, "/web/login?error=invalid+credentials", http.StatusSeeOther)
+            return
+        }
+        _ = w.limiter.reset(r.Context(), username)
+        token, _, err := w.sessions.create(r.Context(), username)
+        if err != nil {
+            log.Error().Err(err).Msg("session create failed")
+            http.Redirect(wr, r, "/web/login?error=service+unavailable", http.StatusSeeOther)
+            return
+        }
+        http.SetCookie(wr, &http.Cookie{Name: sessionCookie, Value: token, Path: "/", HttpOnly: true, Secure: w.secure,
+            SameSite: http.SameSiteLaxMode, MaxAge: int(w.sessions.ttl.Seconds())})
+        // clear the cookie of the old unsigned scheme
+        http.SetCookie(wr, &http.Cookie{Name: "auth", Value: "", Path: "/", MaxAge: -1})
+        log.Info().Str("ip", ip).Str("user", username).Msg("dashboard login")
+        http.Redirect(wr, r, "/web/dashboard", http.StatusSeeOther)
     default:
         wr.WriteHeader(http.StatusMethodNotAllowed)
     }
 }
 
+// handleLogout revokes the session server-side, so a copied cookie stops working too.
 func (w *Web) handleLogout(wr http.ResponseWriter, r *http.Request) {
-    http.SetCookie(wr, &http.Cookie{Name: "auth", Value: "", Path: "/", MaxAge: -1})
+    if c, err := r.Cookie(sessionCookie); err == nil {
+        if err := w.sessions.revoke(r.Context(), c.Value); err != nil {
+            log.Error().Err(err).Msg("session revoke failed")
+        }
+    }
+    http.SetCookie(wr, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", HttpOnly: true, Secure: w.secure, MaxAge: -1})
     http.Redirect(wr, r, "/web/login", http.StatusSeeOther)
 }
 
 func (w *Web) handleDashboard(wr http.ResponseWriter, r *http.Request) {
+    sess := sessionFrom(r)
     w.render(wr, "dashboard.html", map[string]any{
-        "Username": w.username,
+        "Username":  sess.User,
+        "CSRFToken": sess.CSRF,
     })
 }
 
@@ -102,9 +196,14 @@ func (w *Web) handleProcess(wr http.ResponseWriter, r *http.Request) {
     aiEngine := r.Form.Get("ai_engine")
     textOnly := r.Form.Get("text_only") == "on"
     body := map[string]any{"file_path": filePath, "user_name": userName, "ai_engine": aiEngine, "text_only": textOnly, "source": "dashboard"}
+    for _, k := range []string{"ai_prompt", "prompt_template", "language", "target_language", "page_selection"} {
+        if v := r.Form.Get(k); v != "" { body[k] = v }
+    }
     b, _ := json.Marshal(body)
     url := fmt.Sprintf("http://127.0.0.1:%s/process_file_junior_call", w.port)
-    resp, err := http.Post(url, "application/json", bytes.NewReader(b))
+    req, _ := http.NewRequestWithContext(r.Context(), http.MethodPost, url, bytes.NewReader(b))
+    req.Header.Set("Content-Type", "application/json")
+    resp, err := w.callAPI(req)
     if err != nil { http.Error(wr, "request failed", 500); return }
     defer resp.Body.Close()
     out, _ := io.ReadAll(resp.Body)
@@ -130,7 +229,7 @@ func (w *Web) handleUpload(wr http.ResponseWriter, r *http.Request) {
     if _, err := io.Copy(fw, file); err != nil { http.Error(wr, "upload error", 500); return }
 
     // Copy other fields
-    for _, k := range []string{"user_name", "ai_engine", "text_only"} {
+    for _, k := range []string{"user_name", "ai_engine", "text_only", "ai_prompt", "prompt_template", "language", "target_language", "page_selection"} {
         if v := r.FormValue(k); v != "" {
             _ = mw.WriteField(k, v)
         }
@@ -138,9 +237,9 @@ func (w *Web) handleUpload(wr http.ResponseWriter, r *http.Request) {
     _ = mw.Close()
 
     url := fmt.Sprintf("http://127.0.0.1:%s/process_file_upload", w.port)
-    req, _ := http.NewRequest(http.MethodPost, url, &b)
+    req, _ := http.NewRequestWithContext(r.Context(), http.MethodPost, url, &b)
     req.Header.Set("Content-Type", mw.FormDataContentType())
-    resp, err := http.DefaultClient.Do(req)
+    resp, err := w.callAPI(req)
     if err != nil { http.Error(wr, "request failed", 500); return }
     defer resp.Body.Close()
     wr.Header().Set("Content-Type", "application/json")
@@ -151,13 +250,36 @@ func (w *Web) handleUpload(wr http.ResponseWriter, r *http.Request) {
 func (w *Web) handleProgress(wr http.ResponseWriter, r *http.Request) {
     jobID := strings.TrimPrefix(r.URL.Path, "/web/progress/")
     url := fmt.Sprintf("http://127.0.0.1:%s/progress_spec/%s", w.port, jobID)
-    resp, err := http.Get(url)
+    req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+    resp, err := w.callAPI(req)
     if err != nil { http.Error(wr, "progress failed", 500); return }
     defer resp.Body.Close()
     wr.Header().Set("Content-Type", "application/json")
+    wr.WriteHeader(resp.StatusCode)
     io.Copy(wr, resp.Body)
 }
 
+// handleDownloadResult proxies /download_result/{job_id} for dashboard uploads.
+func (w *Web) handleDownloadResult(wr http.ResponseWriter, r *http.Request) {
+    jobID := strings.TrimPrefix(r.URL.Path, "/web/download_result/")
+    url := fmt.Sprintf("http://127.0.0.1:%s/download_result/%s", w.port, jobID)
+    req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+    resp, err := w.callAPI(req)
+    if err != nil { http.Error(wr, "download failed", 500); return }
+    defer resp.Body.Close()
+    for _, h := range []string{"Content-Type", "Content-Disposition"} {
+        if v := resp.Header.Get(h); v != "" { wr.Header().Set(h, v) }
+    }
+    wr.WriteHeader(resp.StatusCode)
+    io.Copy(wr, resp.Body)
+}
+
+// callAPI sends req to the local orchestrator API with the dashboard's API key.
+func (w *Web) callAPI(req *http.Request) (*http.Response, error) {
+    if w.apiKey != "" { req.Header.Set("X-API-Key", w.apiKey) }
+    return http.DefaultClient.Do(req)
+}
+
 func (w *Web) handleSystemStatus(wr http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
         wr.WriteHeader(http.StatusMethodNotAllowed)
@@ -4,7 +4,8 @@ set -euo pipefail
 # Simple dev entrypoint that watches for changes in templates and .env,
 # (re)loads environment from /app/.env, and restarts the binary.
 
-APP_BIN="/app/bin/aidispatcher"
+# APP_BIN selects the binary: aidispatcher (all-in-one), orchestrator or dispatcher
+APP_BIN="${APP_BIN:-/app/bin/aidispatcher}"
 ENV_FILE="/app/.env"
 WATCH_DIR="/app/web"
 
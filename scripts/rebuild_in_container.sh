@@ -29,16 +29,23 @@ docker compose exec -T builder bash -lc '
   echo "[builder] Modules downloaded.";
 '
 
-echo "[rebuild] Building linux/amd64 binary..."
+echo "[rebuild] Building linux/amd64 binaries..."
 docker compose exec -T builder bash -lc '
   set -e;
   git config --global --add safe.directory /app;
   export CGO_ENABLED=1 GOOS=linux GOARCH=amd64 GOFLAGS=-mod=mod;
   GO_BIN=${GO_BIN:-/usr/local/go/bin/go};
   "$GO_BIN" build -v -o bin/aidispatcher ./cmd/app;
-  ls -lh bin/aidispatcher;
+  "$GO_BIN" build -v -o bin/orchestrator ./cmd/orchestrator;
+  "$GO_BIN" build -v -o bin/dispatcher ./cmd/dispatcher;
+  "$GO_BIN" build -v -o bin/aidispatcherctl ./cmd/aidispatcherctl;
+  ls -lh bin/aidispatcher bin/orchestrator bin/dispatcher bin/aidispatcherctl;
 '
 
-echo "[rebuild] Restarting app to pick up new binary..."
+echo "[rebuild] Restarting services to pick up new binaries..."
 docker compose restart app
+# split deployment (started with --profile split), if running
+for svc in orchestrator dispatcher; do
+  if [ -n "$(docker compose ps -q "$svc" 2>/dev/null)" ]; then docker compose restart "$svc"; fi
+done
 echo "[rebuild] Done."